@@ -0,0 +1,41 @@
+package sqlite
+
+import "strings"
+
+// Affinity type affinity of a column, it decides how SQLite stores values, https://www.sqlite.org/datatype3.html#type_affinity
+type Affinity string
+
+const (
+	AffinityInteger Affinity = "INTEGER"
+	AffinityText    Affinity = "TEXT"
+	AffinityBlob    Affinity = "BLOB"
+	AffinityReal    Affinity = "REAL"
+	AffinityNumeric Affinity = "NUMERIC"
+)
+
+// TypeAffinity returns the affinity of the declared column type typ by SQLite's rules, e.g. INTEGER for int and bigint,
+// TEXT for varchar(255), REAL for double, https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+func TypeAffinity(typ string) Affinity {
+	if loc := collateRegexp.FindStringIndex(typ); loc != nil {
+		typ = typ[:loc[0]]
+	}
+
+	typ = strings.ToUpper(typ)
+	switch {
+	case strings.Contains(typ, "INT"):
+		return AffinityInteger
+	case strings.Contains(typ, "CHAR"), strings.Contains(typ, "CLOB"), strings.Contains(typ, "TEXT"):
+		return AffinityText
+	case strings.Contains(typ, "BLOB"), strings.TrimSpace(typ) == "":
+		return AffinityBlob
+	case strings.Contains(typ, "REAL"), strings.Contains(typ, "FLOA"), strings.Contains(typ, "DOUB"):
+		return AffinityReal
+	}
+	return AffinityNumeric
+}
+
+// equivalentTypes reports whether the declared column types a and b store values the same way, as they have the same
+// affinity, e.g. INTEGER and int or TEXT and varchar(255)
+func equivalentTypes(a, b string) bool {
+	return TypeAffinity(a) == TypeAffinity(b)
+}
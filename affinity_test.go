@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeAffinity(t *testing.T) {
+	for typ, affinity := range map[string]Affinity{
+		"INTEGER":                   AffinityInteger,
+		"int":                       AffinityInteger,
+		"unsigned big int":          AffinityInteger,
+		"integer PRIMARY KEY":       AffinityInteger,
+		"TEXT":                      AffinityText,
+		"varchar(255)":              AffinityText,
+		"NATIVE CHARACTER(70)":      AffinityText,
+		`text COLLATE "print"`:      AffinityText,
+		"clob":                      AffinityText,
+		"blob":                      AffinityBlob,
+		"":                          AffinityBlob,
+		"REAL":                      AffinityReal,
+		"double":                    AffinityReal,
+		"DOUBLE PRECISION":          AffinityReal,
+		"float":                     AffinityReal,
+		"numeric":                   AffinityNumeric,
+		"decimal(10,5)":             AffinityNumeric,
+		"boolean":                   AffinityNumeric,
+		"datetime":                  AffinityNumeric,
+		"decimal_text":              AffinityText,
+		"floating point":            AffinityInteger,
+		`numeric COLLATE "integer"`: AffinityNumeric,
+	} {
+		assert.Equal(t, affinity, TypeAffinity(typ), "affinity of %v", typ)
+	}
+}
+
+func TestMigrateColumnAffinity(t *testing.T) {
+	type AffinityItem struct {
+		ID    uint
+		Name  string
+		Count int64
+		Price float64
+		Data  []byte
+	}
+
+	db := openTestDB(t, "migrate_column_affinity")
+	createSQL := `CREATE TABLE "affinity_items" ("id" integer PRIMARY KEY,"name" varchar(255),"count" bigint,"price" double,"data" blob)`
+	if err := db.Exec(createSQL).Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	db.Exec(`INSERT INTO "affinity_items" ("name", "count", "price") VALUES ('jinzhu', 1, 1.5)`)
+
+	var sql string
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&AffinityItem{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+		db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "affinity_items").Scan(&sql)
+		assert.Equal(t, createSQL, sql, "equivalent types must not be migrated")
+	}
+	assert.Contains(t, sql, `"name" varchar(255)`)
+
+	// a changed affinity migrates the column
+	type AffinityItemV2 struct {
+		ID    uint
+		Name  string
+		Count string
+		Price float64
+		Data  []byte
+	}
+	if err := db.Table("affinity_items").AutoMigrate(&AffinityItemV2{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "affinity_items").Scan(&sql)
+	assert.Contains(t, sql, `"count" text`)
+
+	var count string
+	db.Raw(`SELECT typeof("count") FROM "affinity_items"`).Scan(&count)
+	assert.Equal(t, "text", count)
+}
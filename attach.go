@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// attachment a database attached to every pooled connection
+type attachment struct {
+	alias string
+	path  string
+}
+
+func (a attachment) query() string {
+	return "ATTACH DATABASE ? AS " + quoteIdentifier(a.alias)
+}
+
+// Attach attaches the database at path as alias to every pooled connection, connections opened later attach it too.
+// Tables of the attached database are addressed with schema qualified names like alias.table, e.g. from a TableName method.
+// Connections in use while Attach runs are not covered, don't use db concurrently while attaching.
+func Attach(db *gorm.DB, path, alias string) error {
+	sqlDB, c, ctx, err := getConnector(db, "Attach")
+	if err != nil {
+		return err
+	}
+
+	for _, a := range c.getAttachments() {
+		if strings.EqualFold(a.alias, alias) {
+			return fmt.Errorf("sqlite: database %v is already attached", alias)
+		}
+	}
+
+	a := attachment{alias: alias, path: path}
+	attach := func(conn *sql.Conn) error {
+		var count int
+		if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM pragma_database_list WHERE name = ?", alias).Scan(&count); err != nil || count > 0 {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, a.query(), path)
+		return err
+	}
+
+	// attach to one connection before registering, so a bad path doesn't break new connections
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = attach(conn)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.attachments = append(c.attachments[:len(c.attachments):len(c.attachments)], a)
+	c.mu.Unlock()
+
+	return forEachIdleConn(ctx, sqlDB, attach)
+}
+
+// Detach detaches the database attached as alias with Attach
+func Detach(db *gorm.DB, alias string) error {
+	sqlDB, c, ctx, err := getConnector(db, "Detach")
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	attachments := make([]attachment, 0, len(c.attachments))
+	for _, a := range c.attachments {
+		if !strings.EqualFold(a.alias, alias) {
+			attachments = append(attachments, a)
+		}
+	}
+	found := len(attachments) != len(c.attachments)
+	c.attachments = attachments
+	c.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("sqlite: database %v is not attached", alias)
+	}
+
+	return forEachIdleConn(ctx, sqlDB, func(conn *sql.Conn) error {
+		var count int
+		if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM pragma_database_list WHERE name = ?", alias).Scan(&count); err != nil || count == 0 {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, "DETACH DATABASE "+quoteIdentifier(alias))
+		return err
+	})
+}
+
+func getConnector(db *gorm.DB, name string) (*sql.DB, *connector, context.Context, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	c, ok := sqlDB.Driver().(*connector)
+	if !ok {
+		return nil, nil, nil, errors.New("sqlite: " + name + " requires a database opened with the dialector's DSN")
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return sqlDB, c, ctx, nil
+}
+
+// forEachIdleConn runs fc with every idle pooled connection, the connections are held until all of them are done,
+// so each one is visited once, fc may also receive newly opened connections and has to be idempotent
+func forEachIdleConn(ctx context.Context, sqlDB *sql.DB, fc func(*sql.Conn) error) error {
+	var conns []*sql.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for i, idle := 0, sqlDB.Stats().Idle; i < idle; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+
+		if err := fc(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaName returns schema, or main for the main database
+func schemaName(schema string) string {
+	if schema == "" {
+		return "main"
+	}
+	return schema
+}
+
+// masterTable returns the schema table of schema, https://www.sqlite.org/schematab.html
+func masterTable(schema string) string {
+	if schema == "" {
+		return "sqlite_master"
+	}
+	return quoteIdentifier(schema) + ".sqlite_master"
+}
+
+// qualifyName quotes name, prefixed with schema when it is not empty
+func qualifyName(schema, name string) string {
+	if schema == "" {
+		return quoteIdentifier(name)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(name)
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type TenantUser struct {
+	ID   uint
+	Name string `gorm:"index"`
+	Age  int
+}
+
+func (TenantUser) TableName() string {
+	return "tenant.users"
+}
+
+func TestAttach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorm-sqlite-attach")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDB(t, "attach")
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(3)
+
+	tenantPath := filepath.Join(dir, "tenant.db")
+	if err := Attach(db, tenantPath, "tenant"); err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	if err := Attach(db, tenantPath, "tenant"); err == nil {
+		t.Errorf("Expected attaching the same alias twice to fail")
+	}
+
+	if err := db.AutoMigrate(&TenantUser{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	assert.True(t, db.Migrator().HasTable(&TenantUser{}))
+	assert.True(t, db.Migrator().HasIndex(&TenantUser{}, "Name"))
+	assert.False(t, db.Migrator().HasTable("users"), "table should be created in the attached database")
+
+	// a second migration must not see any changes
+	if err := db.AutoMigrate(&TenantUser{}); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+
+	if err := db.Create(&TenantUser{Name: "jinzhu", Age: 18}).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	// every pooled connection sees the attached database
+	var txs []*gorm.DB
+	for i := 0; i < 3; i++ {
+		tx := db.Begin()
+		txs = append(txs, tx)
+
+		var count int64
+		if err := tx.Model(&TenantUser{}).Count(&count).Error; err != nil || count != 1 {
+			t.Errorf("Expected 1 tenant user on connection %d, got %v, %v", i, count, err)
+		}
+	}
+	for _, tx := range txs {
+		tx.Rollback()
+	}
+
+	m := db.Migrator().(Migrator)
+	if err := m.AlterColumn(&TenantUser{}, "Age"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	if err := m.RenameIndex(&TenantUser{}, "idx_tenant_users_name", "idx_tenant_users_name2"); err != nil {
+		t.Fatalf("failed to rename index: %v", err)
+	}
+	indexes, err := m.GetIndexes(&TenantUser{})
+	if err != nil {
+		t.Fatalf("failed to get indexes: %v", err)
+	}
+	if assert.Len(t, indexes, 1) {
+		assert.Equal(t, "idx_tenant_users_name2", indexes[0].Name())
+		assert.Equal(t, []string{"name"}, indexes[0].Columns())
+	}
+
+	var user TenantUser
+	if err := db.First(&user).Error; err != nil || user.Name != "jinzhu" {
+		t.Errorf("Expected data to survive the rebuild, got %+v, %v", user, err)
+	}
+
+	if err := Detach(db, "tenant"); err != nil {
+		t.Fatalf("failed to detach: %v", err)
+	}
+	if err := db.First(&TenantUser{}).Error; err == nil {
+		t.Errorf("Expected query on detached database to fail")
+	}
+	if err := Detach(db, "tenant"); err == nil {
+		t.Errorf("Expected detaching an unknown alias to fail")
+	}
+}
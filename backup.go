@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// BackupOptions options of Backup
+type BackupOptions struct {
+	// Schema source database schema, defaults to main
+	Schema string
+	// PagesPerStep pages copied per step, the source is only locked while a step runs, defaults to 100
+	PagesPerStep int
+	// StepInterval pause between steps so writers can make progress
+	StepInterval time.Duration
+	// Progress is called after every step with the remaining and total page count
+	Progress func(remaining, total int)
+}
+
+// Backup copies the live database of db into destPath with the online backup API,
+// https://www.sqlite.org/backup.html, the copy is consistent even if db is written while it runs
+func Backup(ctx context.Context, db *gorm.DB, destPath string, opts ...BackupOptions) error {
+	var options BackupOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Schema == "" {
+		options.Schema = "main"
+	}
+	if options.PagesPerStep <= 0 {
+		options.PagesPerStep = 100
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	srcConn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	// the backup API requires the destination to be encrypted with the key of the source
+	var destDB *sql.DB
+	if c, ok := sqlDB.Driver().(*connector); ok {
+		destDB = c.openFile(destPath)
+	} else {
+		driverName := DriverName
+		if dialector, ok := asDialector(db.Dialector); ok && dialector.DriverName != "" {
+			driverName = dialector.DriverName
+		}
+		if destDB, err = sql.Open(driverName, destPath); err != nil {
+			return err
+		}
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return withSQLiteConn(ctx, srcConn, func(src *sqlite3.SQLiteConn) error {
+		return withSQLiteConn(ctx, destConn, func(dest *sqlite3.SQLiteConn) error {
+			backup, err := dest.Backup("main", src, options.Schema)
+			if err != nil {
+				return err
+			}
+
+			for {
+				done, err := backup.Step(options.PagesPerStep)
+				if err != nil {
+					backup.Close()
+					return err
+				}
+
+				if options.Progress != nil {
+					options.Progress(backup.Remaining(), backup.PageCount())
+				}
+
+				if done {
+					return backup.Finish()
+				}
+
+				select {
+				case <-ctx.Done():
+					backup.Close()
+					return ctx.Err()
+				case <-time.After(options.StepInterval):
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestBackup(t *testing.T) {
+	type Item struct {
+		ID   uint
+		Name string
+	}
+
+	dir, err := ioutil.TempDir("", "gorm-sqlite-backup")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDB(t, "backup")
+	if err := db.AutoMigrate(&Item{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	items := make([]Item, 1000)
+	for idx := range items {
+		items[idx].Name = "item"
+	}
+	db.CreateInBatches(&items, 100)
+
+	var steps int
+	destPath := filepath.Join(dir, "backup.db")
+	err = Backup(context.Background(), db, destPath, BackupOptions{PagesPerStep: 1, Progress: func(remaining, total int) {
+		steps++
+		assert.True(t, remaining <= total)
+	}})
+	if err != nil {
+		t.Fatalf("failed to backup: %v", err)
+	}
+	assert.True(t, steps > 1)
+
+	backupDB, err := gorm.Open(Open(destPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	var count int64
+	backupDB.Model(&Item{}).Count(&count)
+	assert.Equal(t, int64(len(items)), count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, Backup(ctx, db, filepath.Join(dir, "cancelled.db"), BackupOptions{PagesPerStep: 1}))
+}
+
+func TestBackupDriverName(t *testing.T) {
+	dir := tempDir(t)
+	db, err := gorm.Open(&Dialector{DriverName: filesDriverName, DSN: filepath.Join(dir, "source.db")}, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	destPath := filepath.Join(dir, "backup.db")
+	if err := Backup(context.Background(), db, destPath); err != nil {
+		t.Fatalf("failed to backup: %v", err)
+	}
+	assert.True(t, opened(destPath), "the destination is opened with the driver of the dialector")
+}
+
+func TestMaintenance(t *testing.T) {
+	type Item struct {
+		ID   uint
+		Name string `gorm:"index"`
+	}
+
+	dir, err := ioutil.TempDir("", "gorm-sqlite-maintenance")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := gorm.Open(Open(filepath.Join(dir, "maintenance.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.AutoMigrate(&Item{})
+	db.Create(&[]Item{{Name: "a"}, {Name: "b"}})
+
+	result, err := IntegrityCheck(db)
+	assert.NoError(t, err)
+	assert.True(t, result.OK())
+
+	result, err = QuickCheck(db)
+	assert.NoError(t, err)
+	assert.True(t, result.OK(), result.Problems)
+
+	assert.NoError(t, Optimize(db))
+	assert.NoError(t, Vacuum(db))
+
+	intoPath := filepath.Join(dir, "vacuum.db")
+	assert.NoError(t, VacuumInto(db, intoPath))
+	assert.Error(t, VacuumInto(db, intoPath), "existing destination")
+
+	copyDB, err := gorm.Open(Open(intoPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open copy: %v", err)
+	}
+	var count int64
+	copyDB.Model(&Item{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestCheckpoint(t *testing.T) {
+	type Item struct {
+		ID   uint
+		Name string
+	}
+
+	dir, err := ioutil.TempDir("", "gorm-sqlite-checkpoint")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "wal.db")
+	db, err := gorm.Open(New(path+"?_journal_mode=WAL", Config{AutoCheckpoint: -1}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	var autoCheckpoint int
+	db.Raw("PRAGMA wal_autocheckpoint").Scan(&autoCheckpoint)
+	assert.Equal(t, 0, autoCheckpoint)
+
+	db.AutoMigrate(&Item{})
+	for i := 0; i < 10; i++ {
+		db.Create(&Item{Name: "item"})
+	}
+
+	result, err := Checkpoint(db, CheckpointPassive)
+	assert.NoError(t, err)
+	assert.False(t, result.Busy)
+	assert.True(t, result.Log > 0)
+	assert.Equal(t, result.Log, result.Checkpointed)
+
+	result, err = Checkpoint(db, CheckpointTruncate)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Log)
+	if info, err := os.Stat(path + "-wal"); err == nil {
+		assert.Equal(t, int64(0), info.Size())
+	}
+
+	_, err = Checkpoint(db, "NOW")
+	assert.Error(t, err)
+}
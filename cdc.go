@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// ChangeOp operation of a Change
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "INSERT"
+	ChangeUpdate ChangeOp = "UPDATE"
+	ChangeDelete ChangeOp = "DELETE"
+)
+
+// Change a row level change of a committed transaction, captured with Config.CaptureChanges and reported
+// by HookCommit events
+type Change struct {
+	// Table changed table, qualified with its schema for attached databases
+	Table string
+	RowID int64
+	Op    ChangeOp
+	// Old values of updated and deleted rows by column, only drivers with pre-update hooks capture them,
+	// e.g. mattn/go-sqlite3 built with the sqlite_preupdate_hook tag
+	Old map[string]interface{}
+	// New values of inserted and updated rows by column, as the statement making the change left them
+	New map[string]interface{}
+}
+
+// capturedChange a change of the current transaction, its new values are read once the statement making it ended
+type capturedChange struct {
+	Change
+	schema, table string
+	old           []interface{}
+	read          bool
+}
+
+// preUpdate old values of a row reported by a pre-update hook
+type preUpdate struct {
+	op     int
+	db     string
+	table  string
+	rowid  int64
+	values []interface{}
+}
+
+// matches reports whether the update hook reports the change of u
+func (u *preUpdate) matches(op int, db, table string, rowid int64) bool {
+	return u.op == op && u.db == db && u.table == table && u.rowid == rowid
+}
+
+// changeOp returns the ChangeOp of an update hook op
+func changeOp(op int) ChangeOp {
+	switch op {
+	case sqlite3.SQLITE_INSERT:
+		return ChangeInsert
+	case sqlite3.SQLITE_DELETE:
+		return ChangeDelete
+	}
+	return ChangeUpdate
+}
+
+// readNewValues reads the new values of the captured changes by conn, the row may be changed again by later statements
+func readNewValues(ctx context.Context, conn driver.Conn, captured []capturedChange) {
+	for i := range captured {
+		c := &captured[i]
+		if !c.read && c.Op != ChangeDelete {
+			c.New = queryRow(ctx, conn, "SELECT * FROM "+quoteIdentifier(schemaName(c.schema))+"."+quoteIdentifier(c.table)+" WHERE rowid = ?", c.RowID)
+		}
+		c.read = true
+	}
+}
+
+// readChanges returns the changes with their values, unread new values are read by conn after their transaction committed
+func readChanges(ctx context.Context, conn driver.Conn, captured []capturedChange) []Change {
+	readNewValues(ctx, conn, captured)
+	columns := map[string][]string{}
+	changes := make([]Change, len(captured))
+	for i, c := range captured {
+		change := c.Change
+		if c.old != nil {
+			name := qualifyName(c.schema, c.table)
+			if _, ok := columns[name]; !ok {
+				columns[name] = queryColumnNames(ctx, conn, c.schema, c.table)
+			}
+			if names := columns[name]; len(names) == len(c.old) {
+				change.Old = make(map[string]interface{}, len(names))
+				for j, name := range names {
+					change.Old[name] = c.old[j]
+				}
+			}
+		}
+		changes[i] = change
+	}
+	return changes
+}
+
+// queryColumnNames returns the names of the stored columns of table, like the values of pre-update hooks
+func queryColumnNames(ctx context.Context, conn driver.Conn, schema, table string) (names []string) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return nil
+	}
+
+	rows, err := queryer.QueryContext(ctx, "SELECT name FROM pragma_table_xinfo(?, ?) WHERE hidden <> 1 ORDER BY cid",
+		[]driver.NamedValue{{Ordinal: 1, Value: table}, {Ordinal: 2, Value: schemaName(schema)}})
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, 1)
+	for rows.Next(values) == nil {
+		name, _ := values[0].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+// queryRow returns the values of the first row of query by column, nil if there is none
+func queryRow(ctx context.Context, conn driver.Conn, query string, args ...interface{}) map[string]interface{} {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return nil
+	}
+
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	rows, err := queryer.QueryContext(ctx, query, namedArgs)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+	values := make([]driver.Value, len(columns))
+	if err := rows.Next(values); err != nil {
+		return nil
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row
+}
+
+// ChangeRecord a change recorded by RecordChanges
+type ChangeRecord struct {
+	ID        uint64
+	Table     string `gorm:"column:table_name;index"`
+	RowID     int64
+	Op        ChangeOp
+	Old       JSON
+	New       JSON
+	CreatedAt time.Time
+}
+
+// RecordChanges records the changes captured from db into table, e.g. an outbox for syncing or an audit trail, the table
+// is created if needed. Changes are recorded by another goroutine once their transaction committed, until stop is called,
+// so they are lost if the process exits before. Config.Hooks and Config.CaptureChanges are required
+func RecordChanges(db *gorm.DB, table string) (stop func(), err error) {
+	dialector, ok := asDialector(db.Dialector)
+	if !ok || dialector.Hooks == nil || !dialector.CaptureChanges {
+		return nil, errors.New("sqlite: RecordChanges requires Config.Hooks and Config.CaptureChanges")
+	}
+	db = db.Session(&gorm.Session{NewDB: true})
+	if err := db.Table(table).AutoMigrate(&ChangeRecord{}); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		queue   []ChangeRecord
+		stopped bool
+		// queued is signaled when changes are queued, commits never wait for the recording
+		queued = make(chan struct{}, 1)
+		done   = make(chan struct{})
+	)
+	unsubscribe := dialector.Hooks.Subscribe(func(event HookEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, change := range event.Changes {
+			// recording a change is a change of table itself
+			if change.Table != table && !stopped {
+				queue = append(queue, newChangeRecord(change))
+			}
+		}
+		select {
+		case queued <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		defer close(done)
+		for range queued {
+			mu.Lock()
+			batch, last := queue, stopped
+			queue = nil
+			mu.Unlock()
+
+			if len(batch) > 0 {
+				if err := db.Table(table).CreateInBatches(&batch, 100).Error; err != nil {
+					db.Logger.Error(context.Background(), "sqlite: failed to record %d changes: %v", len(batch), err)
+				}
+			}
+			if last {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unsubscribe()
+			mu.Lock()
+			stopped = true
+			mu.Unlock()
+			// the recorder drains the queue before it returns
+			select {
+			case queued <- struct{}{}:
+			default:
+			}
+			<-done
+		})
+	}, nil
+}
+
+func newChangeRecord(change Change) ChangeRecord {
+	record := ChangeRecord{Table: change.Table, RowID: change.RowID, Op: change.Op}
+	if change.Old != nil {
+		record.Old, _ = marshalChangeValues(change.Old)
+	}
+	if change.New != nil {
+		record.New, _ = marshalChangeValues(change.New)
+	}
+	return record
+}
+
+// marshalChangeValues marshals values as a JSON object, pre-update hooks read text as bytes, so valid UTF-8 is
+// kept as text
+func marshalChangeValues(values map[string]interface{}) (JSON, error) {
+	object := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if bytes, ok := value.([]byte); ok && utf8.Valid(bytes) {
+			value = string(bytes)
+		}
+		object[name] = value
+	}
+	data, err := json.Marshal(object)
+	return JSON(data), err
+}
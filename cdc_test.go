@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type CDCAccount struct {
+	ID      uint
+	Name    string
+	Balance int
+}
+
+func TestCaptureChanges(t *testing.T) {
+	hooks := NewHooks()
+	recorder := &hookRecorder{}
+	hooks.Subscribe(recorder.record)
+
+	path := filepath.Join(tempDir(t), "cdc.db")
+	db, err := gorm.Open(New(path, Config{Hooks: hooks, CaptureChanges: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CDCAccount{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	recorder.take(HookCommit)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		account := CDCAccount{Name: "jinzhu", Balance: 10}
+		tx.Create(&account)
+		tx.Model(&account).Update("balance", 20)
+		return tx.Create(&CDCAccount{Name: "deleted"}).Error
+	})
+	assert.NoError(t, err)
+	db.Where("name = ?", "deleted").Delete(&CDCAccount{})
+
+	events := recorder.take(HookCommit)
+	if !assert.Len(t, events, 2) {
+		t.FailNow()
+	}
+
+	// new values are the values each statement left
+	changes := events[0].Changes
+	if assert.Len(t, changes, 3) {
+		assert.Equal(t, Change{Table: "cdc_accounts", RowID: 1, Op: ChangeInsert,
+			New: map[string]interface{}{"id": int64(1), "name": "jinzhu", "balance": int64(10)}}, changes[0])
+		assert.Equal(t, ChangeUpdate, changes[1].Op)
+		assert.Equal(t, int64(1), changes[1].RowID)
+		assert.Equal(t, int64(20), changes[1].New["balance"])
+		assert.Equal(t, ChangeInsert, changes[2].Op)
+		assert.Equal(t, int64(2), changes[2].RowID)
+		if preUpdateHooks {
+			assert.Equal(t, map[string]interface{}{"id": int64(1), "name": []byte("jinzhu"), "balance": int64(10)}, changes[1].Old)
+		} else {
+			assert.Nil(t, changes[1].Old)
+		}
+	}
+
+	changes = events[1].Changes
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, ChangeDelete, changes[0].Op)
+		assert.Equal(t, int64(2), changes[0].RowID)
+		assert.Nil(t, changes[0].New)
+	}
+
+	// rolled back changes aren't reported
+	db.Transaction(func(tx *gorm.DB) error {
+		tx.Create(&CDCAccount{Name: "rolled back"})
+		return gorm.ErrInvalidTransaction
+	})
+	events = recorder.take(HookRollback)
+	if assert.Len(t, events, 1) {
+		assert.Empty(t, events[0].Changes)
+	}
+
+	// changes of nested transactions rolled back to their savepoint aren't reported
+	err = db.Transaction(func(tx *gorm.DB) error {
+		tx.Transaction(func(tx *gorm.DB) error {
+			tx.Create(&CDCAccount{Name: "nested rolled back"})
+			return gorm.ErrInvalidTransaction
+		})
+		tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&CDCAccount{Name: "nested"}).Error
+		})
+		return tx.Create(&CDCAccount{Name: "kept"}).Error
+	})
+	assert.NoError(t, err)
+	events = recorder.take(HookCommit)
+	if assert.Len(t, events, 1) && assert.Len(t, events[0].Changes, 2) {
+		assert.Equal(t, "nested", events[0].Changes[0].New["name"])
+		assert.Equal(t, "kept", events[0].Changes[1].New["name"])
+		assert.NotEqual(t, events[0].Changes[0].RowID, events[0].Changes[1].RowID)
+	}
+}
+
+func TestRecordChanges(t *testing.T) {
+	_, err := RecordChanges(openTestDB(t, "record_changes_disabled"), "changes")
+	assert.Error(t, err)
+
+	path := filepath.Join(tempDir(t), "record.db")
+	db, err := gorm.Open(New(path, Config{Hooks: NewHooks(), CaptureChanges: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CDCAccount{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	stop, err := RecordChanges(db, "account_changes")
+	if err != nil {
+		t.Fatalf("failed to record changes: %v", err)
+	}
+	account := CDCAccount{Name: "jinzhu", Balance: 10}
+	db.Create(&account)
+	db.Model(&account).Update("balance", 20)
+	db.Delete(&account)
+	stop()
+	stop()
+
+	db.Create(&CDCAccount{Name: "unrecorded"})
+	time.Sleep(10 * time.Millisecond)
+
+	var records []ChangeRecord
+	if err := db.Table("account_changes").Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("failed to find records: %v", err)
+	}
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "cdc_accounts", records[0].Table)
+		assert.Equal(t, []ChangeOp{ChangeInsert, ChangeUpdate, ChangeDelete}, []ChangeOp{records[0].Op, records[1].Op, records[2].Op})
+
+		var values map[string]interface{}
+		assert.NoError(t, json.Unmarshal(records[1].New, &values))
+		assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "jinzhu", "balance": float64(20)}, values)
+		assert.Nil(t, records[2].New)
+	}
+}
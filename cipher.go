@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrCipherNotSupported is returned when Config.Key is set but the driver isn't built against SQLCipher or SQLite3 Multiple Ciphers
+var ErrCipherNotSupported = errors.New("sqlite: encryption key configured but the driver is not built with SQLCipher or SQLite3 Multiple Ciphers")
+
+// dsnKeyParams DSN parameters used by cipher enabled drivers to pass the key
+var dsnKeyParams = []string{"_pragma_key", "_key"}
+
+// extractDSNKey removes the encryption key from dsn, so it never shows up in errors or logs
+func extractDSNKey(dsn string) (string, string) {
+	idx := strings.IndexByte(dsn, '?')
+	if idx < 0 {
+		return dsn, ""
+	}
+
+	query, err := url.ParseQuery(dsn[idx+1:])
+	if err != nil {
+		return dsn, ""
+	}
+
+	var key string
+	for _, param := range dsnKeyParams {
+		if value := query.Get(param); value != "" && key == "" {
+			key = value
+		}
+		query.Del(param)
+	}
+
+	if key == "" {
+		return dsn, ""
+	}
+	if encoded := query.Encode(); encoded != "" {
+		return dsn[:idx+1] + encoded, key
+	}
+	return dsn[:idx], key
+}
+
+// applyKey returns a connect hook keying each connection before any other statement runs
+func applyKey(c *connector) connectHook {
+	return func(ctx context.Context, conn driver.Conn) error {
+		if err := execConn(ctx, conn, "PRAGMA key = "+quoteString(c.getKey())); err != nil {
+			return err
+		}
+
+		// plain SQLite silently ignores unknown pragmas, make sure the key was actually applied
+		cipherVersion, _ := queryConn(ctx, conn, "PRAGMA cipher_version")
+		cipher, _ := queryConn(ctx, conn, "PRAGMA cipher")
+		if cipherVersion == "" && cipher == "" {
+			return ErrCipherNotSupported
+		}
+
+		// fail early on a wrong key instead of on the first query
+		_, err := queryConn(ctx, conn, "SELECT count(*) FROM sqlite_master")
+		return err
+	}
+}
+
+// Rekey changes the encryption key of db, connections opened afterwards use the new key.
+// Idle pooled connections still hold the old key and are closed, the pool is reset to
+// database/sql's default of 2 idle connections, don't use db concurrently while rekeying.
+func Rekey(db *gorm.DB, newKey string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	c, ok := sqlDB.Driver().(*connector)
+	if !ok || c.getKey() == "" {
+		return errors.New("sqlite: Rekey requires a database opened with Config.Key")
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	// run on the driver connection directly, statements executed through gorm would log the key
+	err = conn.Raw(func(driverConn interface{}) error {
+		return execConn(ctx, driverConn.(driver.Conn), "PRAGMA rekey = "+quoteString(newKey))
+	})
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	c.setKey(newKey)
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(2)
+	return nil
+}
+
+// queryConn returns the first column of the first row of query
+func queryConn(ctx context.Context, conn driver.Conn, query string) (string, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return "", errors.New("sqlite: driver connection does not support QueryContext")
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	if err := rows.Next(values); err != nil {
+		if err == io.EOF {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if len(values) == 0 || values[0] == nil {
+		return "", nil
+	}
+	switch v := values[0].(type) {
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	}
+	return "", nil
+}
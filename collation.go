@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	collateRegexp        = regexp.MustCompile(fmt.Sprintf("(?i)\\sCOLLATE\\s+(%v)", identifierPattern))
+	defaultCollationName = "BINARY"
+)
+
+// Collation a custom collating sequence registered on every pooled connection, https://www.sqlite.org/datatype3.html#collation,
+// columns use it with the collate tag, e.g. `gorm:"collate:unicode_nocase"`
+type Collation struct {
+	Name string
+	// Compare returns a negative number, zero or a positive number when a sorts before, the same as or after b
+	Compare func(a, b string) int
+}
+
+var (
+	// CollationUnicodeNoCase compares strings case-insensitively for all of unicode, NOCASE only folds ASCII letters
+	CollationUnicodeNoCase = Collation{Name: "unicode_nocase", Compare: compareUnicodeNoCase}
+	// CollationNatural compares runs of digits by their numeric value, so file2 sorts before file10
+	CollationNatural = Collation{Name: "natural", Compare: compareNatural}
+)
+
+// CollationRegisterer is implemented by driver connections that register collations, like mattn/go-sqlite3's
+type CollationRegisterer interface {
+	RegisterCollation(name string, cmp func(string, string) int) error
+}
+
+// registerCollations returns a connect hook registering collations on each new connection
+func registerCollations(collations []Collation) connectHook {
+	return func(ctx context.Context, conn driver.Conn) error {
+		registerer, ok := conn.(CollationRegisterer)
+		if !ok {
+			return fmt.Errorf("sqlite: driver connection %T can't register collations", conn)
+		}
+
+		for _, collation := range collations {
+			if collation.Name == "" || collation.Compare == nil {
+				return errors.New("sqlite: collation requires a name and a compare function")
+			}
+			if err := registerer.RegisterCollation(collation.Name, collation.Compare); err != nil {
+				return fmt.Errorf("sqlite: failed to register collation %v: %w", collation.Name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// collationOf returns the collation of column definition str, BINARY if it has none
+func collationOf(str string) string {
+	if matches := collateRegexp.FindStringSubmatch(str); matches != nil {
+		return unquoteIdentifier(matches[1])
+	}
+	return defaultCollationName
+}
+
+func compareUnicodeNoCase(a, b string) int {
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if la, lb := unicode.ToLower(ra), unicode.ToLower(rb); la != lb {
+			if la < lb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return len(a) - len(b)
+}
+
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numberA, numberB := digitPrefix(a), digitPrefix(b)
+			trimmedA, trimmedB := strings.TrimLeft(numberA, "0"), strings.TrimLeft(numberB, "0")
+			if len(trimmedA) != len(trimmedB) {
+				return len(trimmedA) - len(trimmedB)
+			}
+			if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+			// equal numbers with fewer leading zeros first
+			if len(numberA) != len(numberB) {
+				return len(numberA) - len(numberB)
+			}
+			a, b = a[len(numberA):], b[len(numberB):]
+			continue
+		}
+
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitPrefix returns the leading digits of str
+func digitPrefix(str string) string {
+	i := 0
+	for i < len(str) && isDigit(str[i]) {
+		i++
+	}
+	return str[:i]
+}
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCollations(t *testing.T) {
+	type CollatedFile struct {
+		ID    uint
+		Name  string `gorm:"collate:natural"`
+		Title string
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{Collations: []Collation{CollationNatural, CollationUnicodeNoCase}}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&CollatedFile{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	rawDDL, _ := db.Migrator().(Migrator).getRawDDL("collated_files")
+	assert.Contains(t, rawDDL, "\"name\" text COLLATE \"natural\"")
+
+	for _, name := range []string{"file10", "file2", "File1", "file02"} {
+		db.Create(&CollatedFile{Name: name, Title: name})
+	}
+
+	var names []string
+	db.Model(&CollatedFile{}).Order("name").Pluck("name", &names)
+	assert.Equal(t, []string{"File1", "file2", "file02", "file10"}, names)
+
+	var count int64
+	db.Create(&CollatedFile{Name: "école", Title: "école"})
+	db.Model(&CollatedFile{}).Where("title = ? COLLATE NOCASE", "ÉCOLE").Count(&count)
+	assert.Equal(t, int64(0), count)
+	db.Model(&CollatedFile{}).Where("title = ? COLLATE unicode_nocase", "ÉCOLE").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	columnTypes, err := db.Migrator().ColumnTypes(&CollatedFile{})
+	assert.NoError(t, err)
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "name" {
+			fullDataType, _ := columnType.ColumnType()
+			assert.Equal(t, "text COLLATE \"natural\"", fullDataType)
+			assert.Equal(t, "text", columnType.DatabaseTypeName())
+		}
+	}
+
+	// a changed collate tag rebuilds the table, an unchanged one is left alone
+	type CollatedFile2 struct {
+		ID    uint
+		Name  string
+		Title string `gorm:"collate:unicode_nocase"`
+	}
+	if err := db.Table("collated_files").AutoMigrate(&CollatedFile2{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	rawDDL, _ = db.Migrator().(Migrator).getRawDDL("collated_files")
+	assert.NotContains(t, rawDDL, "natural")
+	assert.Contains(t, rawDDL, "\"title\" text COLLATE \"unicode_nocase\"")
+
+	plan, err := db.Table("collated_files").Migrator().(Migrator).PlanAutoMigrate(&CollatedFile2{})
+	assert.NoError(t, err)
+	assert.Empty(t, plan)
+
+	db.Table("collated_files").Where("title = ?", "ÉCOLE").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestCompareNatural(t *testing.T) {
+	assert.Less(t, compareNatural("a2", "a10"), 0)
+	assert.Less(t, compareNatural("a2b", "a2c"), 0)
+	assert.Less(t, compareNatural("a2", "a02"), 0)
+	assert.Less(t, compareNatural("a", "a1"), 0)
+	assert.Equal(t, 0, compareNatural("x100y", "x100y"))
+	assert.Greater(t, compareNatural("99999999999999999999999", "100"), 0)
+	assert.Less(t, compareUnicodeNoCase("Ärger", "ärgerlich"), 0)
+	assert.Equal(t, 0, compareUnicodeNoCase("ÉCOLE", "école"))
+}
@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// CompactOptions options of CompactInPlace
+type CompactOptions struct {
+	// TempDir directory of the compacted copy, defaults to the directory of the database file, so it is on the same
+	// file system and large enough
+	TempDir string
+	// QuickCheck verifies the copy with PRAGMA quick_check instead of PRAGMA integrity_check
+	QuickCheck bool
+}
+
+// CompactResult result of CompactInPlace
+type CompactResult struct {
+	// SizeBefore and SizeAfter bytes of the database file before and after the compaction
+	SizeBefore, SizeAfter int64
+}
+
+// CompactInPlace compacts the main database of db without closing it, it requires Config.SingleWriter. Writers are
+// paused while VACUUM INTO writes a compacted copy to a temporary file, the copy is verified and copied back over the
+// database with the backup API, then the WAL is checkpointed.
+//
+// The files aren't swapped by renaming the copy over the database, open connections would keep the old file while new
+// ones share its -wal and -shm files. The copy back is a single write transaction instead, in WAL mode readers see
+// either the old or the compacted database, never a partial copy, and aren't blocked, in rollback journal mode they
+// wait for the copy back, which takes about as long as writing the compacted database
+func CompactInPlace(db *gorm.DB, opts ...CompactOptions) (result CompactResult, err error) {
+	var options CompactOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	sqlDB, c, ctx, err := getConnector(db, "CompactInPlace")
+	if err != nil {
+		return result, err
+	}
+	if c.writeLock == nil {
+		return result, errors.New("sqlite: CompactInPlace requires Config.SingleWriter to pause writers")
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	if err := c.writeLock.lock(ctx); err != nil {
+		return result, err
+	}
+	defer c.writeLock.unlock()
+
+	err = withSQLiteConn(ctx, conn, func(dest *sqlite3.SQLiteConn) error {
+		file, err := queryConn(ctx, dest, "SELECT file FROM pragma_database_list WHERE name = 'main'")
+		if err != nil {
+			return err
+		}
+		if file == "" {
+			return errors.New("sqlite: CompactInPlace requires a database file")
+		}
+		if result.SizeBefore, err = databaseSize(ctx, dest); err != nil {
+			return err
+		}
+
+		if options.TempDir == "" {
+			options.TempDir = filepath.Dir(file)
+		}
+		temp, err := ioutil.TempFile(options.TempDir, filepath.Base(file)+".compact-*")
+		if err != nil {
+			return err
+		}
+		temp.Close()
+		defer os.Remove(temp.Name())
+
+		if _, err := dest.ExecContext(ctx, "VACUUM INTO ?", []driver.NamedValue{{Ordinal: 1, Value: temp.Name()}}); err != nil {
+			return err
+		}
+
+		// VACUUM INTO encrypts the copy with the key of the database
+		srcDB := c.openFile(temp.Name())
+		defer srcDB.Close()
+		srcConn, err := srcDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer srcConn.Close()
+
+		return withSQLiteConn(ctx, srcConn, func(src *sqlite3.SQLiteConn) error {
+			pragma := "PRAGMA integrity_check"
+			if options.QuickCheck {
+				pragma = "PRAGMA quick_check"
+			}
+			var problems []string
+			if err := eachRow(ctx, src, pragma, func(values []driver.Value) {
+				if message := fmt.Sprint(values[0]); message != "ok" {
+					problems = append(problems, message)
+				}
+			}); err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("sqlite: compacted copy failed the integrity check: %v", strings.Join(problems, "; "))
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Close()
+				return err
+			}
+			if err := backup.Finish(); err != nil {
+				return err
+			}
+
+			if _, err := dest.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)", nil); err != nil {
+				return err
+			}
+			result.SizeAfter, err = databaseSize(ctx, dest)
+			return err
+		})
+	})
+	return result, err
+}
+
+// databaseSize returns the bytes of the main database of conn
+func databaseSize(ctx context.Context, conn *sqlite3.SQLiteConn) (size int64, err error) {
+	err = eachRow(ctx, conn, "SELECT page_count * page_size FROM pragma_page_count, pragma_page_size", func(values []driver.Value) {
+		size, _ = values[0].(int64)
+	})
+	return
+}
+
+// eachRow runs query on conn and calls fc with the values of each row
+func eachRow(ctx context.Context, conn *sqlite3.SQLiteConn, query string, fc func(values []driver.Value)) error {
+	rows, err := conn.QueryContext(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err := rows.Next(values); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		fc(values)
+	}
+}
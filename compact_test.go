@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// filesDriverName is a driver recording the database files it opens
+const filesDriverName = "sqlite3_opened_files"
+
+var openedFiles struct {
+	sync.Mutex
+	paths []string
+}
+
+func init() {
+	sql.Register(filesDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			rows, err := conn.Query("SELECT file FROM pragma_database_list WHERE name = 'main'", nil)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			values := make([]driver.Value, 1)
+			if err := rows.Next(values); err != nil {
+				return err
+			}
+			openedFiles.Lock()
+			defer openedFiles.Unlock()
+			openedFiles.paths = append(openedFiles.paths, values[0].(string))
+			return nil
+		},
+	})
+}
+
+// opened returns whether the driver of filesDriverName opened a file matching pattern
+func opened(pattern string) bool {
+	openedFiles.Lock()
+	defer openedFiles.Unlock()
+	for _, path := range openedFiles.paths {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type CompactItem struct {
+	ID   uint
+	Name string
+}
+
+func TestCompactInPlace(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "compact.db")
+	db, err := gorm.Open(New("file:"+path+"?_journal_mode=WAL", Config{SingleWriter: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CompactItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	items := make([]CompactItem, 1000)
+	for i := range items {
+		items[i].Name = strings.Repeat("x", 500)
+	}
+	db.CreateInBatches(&items, 100)
+	db.Where("id > ?", 10).Delete(&CompactItem{})
+
+	// a pooled connection stays open during the swap
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxIdleConns(4)
+	var count int64
+	db.Model(&CompactItem{}).Count(&count)
+
+	result, err := CompactInPlace(db)
+	if err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	assert.Less(t, result.SizeAfter, result.SizeBefore)
+
+	info, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, result.SizeAfter, info.Size())
+	}
+	if info, err := os.Stat(path + "-wal"); err == nil {
+		assert.Equal(t, int64(0), info.Size())
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "compact.db.compact-*"))
+	assert.Empty(t, files, "the compacted copy is removed")
+
+	db.Model(&CompactItem{}).Count(&count)
+	assert.Equal(t, int64(10), count)
+	assert.NoError(t, db.Create(&CompactItem{Name: "after"}).Error)
+	var journalMode string
+	db.Raw("PRAGMA journal_mode").Row().Scan(&journalMode)
+	assert.Equal(t, "wal", journalMode)
+	check, err := IntegrityCheck(db)
+	assert.NoError(t, err)
+	assert.True(t, check.OK(), "problems: %v", check.Problems)
+
+	// writers can't be paused without SingleWriter
+	other, err := gorm.Open(New(path, Config{}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	_, err = CompactInPlace(other)
+	assert.Error(t, err)
+
+	memory, err := gorm.Open(OpenInMemory("", Config{SingleWriter: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	_, err = CompactInPlace(memory)
+	assert.Error(t, err)
+}
+
+func TestCompactInPlaceDriverName(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "driver.db")
+	db, err := gorm.Open(&Dialector{DriverName: filesDriverName, DSN: path, Config: Config{SingleWriter: true}}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CompactItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	if _, err := CompactInPlace(db); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	assert.True(t, opened(filepath.Join(dir, "driver.db.compact-*")), "the copy is opened with the driver of the dialector")
+}
+
+func TestCompactInPlaceReaders(t *testing.T) {
+	dir := tempDir(t)
+	db, err := gorm.Open(New("file:"+filepath.Join(dir, "readers.db")+"?_journal_mode=WAL", Config{SingleWriter: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CompactItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	items := make([]CompactItem, 2000)
+	for i := range items {
+		items[i].Name = strings.Repeat("x", 500)
+	}
+	db.CreateInBatches(&items, 100)
+	db.Where("id % 2 = 0").Delete(&CompactItem{})
+
+	type snapshot struct {
+		Count, Total int64
+	}
+	var expected snapshot
+	db.Raw("SELECT count(*) AS count, sum(id) AS total FROM compact_items").Scan(&expected)
+
+	// readers see the whole database before, during and after the copy back
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reads    int
+		problems []string
+		done     = make(chan struct{})
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				var got snapshot
+				err := db.Raw("SELECT count(*) AS count, sum(id) AS total FROM compact_items").Scan(&got).Error
+				mu.Lock()
+				reads++
+				if err != nil {
+					problems = append(problems, err.Error())
+				} else if got != expected {
+					problems = append(problems, fmt.Sprintf("read %+v, expected %+v", got, expected))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := CompactInPlace(db); err != nil {
+			t.Errorf("failed to compact: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	assert.NotZero(t, reads)
+	assert.Empty(t, problems)
+}
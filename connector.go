@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// connectHook runs against every new driver connection before it is handed to the pool
+type connectHook func(ctx context.Context, conn driver.Conn) error
+
+// connector opens driver connections and applies the dialector's connect hooks,
+// so per-connection settings like pragmas survive database/sql pooling
+type connector struct {
+	driver driver.Driver
+	dsn    string
+	hooks  []connectHook
+	// events receive the changes of all connections, see Config.Hooks
+	events         *Hooks
+	captureChanges bool
+	// readOnly rejects write statements, see Config.ReadOnly
+	readOnly bool
+	// maxQueryDuration limits every statement, see Config.MaxQueryDuration
+	maxQueryDuration time.Duration
+	// maxRows and maxSteps abort runaway statements, see Config.MaxRows and Config.MaxSteps
+	maxRows, maxSteps int64
+	// writeLock serializes writes of all connections when set, see Config.SingleWriter
+	writeLock writeLock
+	// timeFormat storage format of time.Time arguments, see Config.TimeFormat
+	timeFormat TimeFormat
+	// stmtCacheSize prepared statements cached per connection, see Config.StatementCacheSize
+	stmtCacheSize  int
+	stmtCacheStats *stmtCacheStats
+
+	mu          sync.RWMutex
+	key         string
+	attachments []attachment
+	pinned      driver.Conn
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range c.hooks {
+		if err := hook(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	for _, a := range c.getAttachments() {
+		if err := execConn(ctx, conn, a.query(), driver.NamedValue{Ordinal: 1, Value: a.path}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	raw := conn
+	if c.events != nil {
+		hookConn, err := newHookConn(conn, c.events, c.captureChanges)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = hookConn
+	}
+	if c.maxQueryDuration > 0 {
+		conn = &interruptConn{Conn: conn, maxDuration: c.maxQueryDuration}
+	}
+	if c.maxRows > 0 || c.maxSteps > 0 {
+		guardConn, err := newGuardConn(conn, raw, c.maxRows, c.maxSteps)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = guardConn
+	}
+	if c.readOnly {
+		conn = &readOnlyConn{Conn: conn}
+	}
+	if c.stmtCacheSize > 0 {
+		conn = newStmtCacheConn(conn, c.stmtCacheSize, c.stmtCacheStats)
+	}
+	if c.writeLock != nil {
+		conn = &writerConn{Conn: conn, lock: c.writeLock}
+	}
+	if c.timeFormat != TimeFormatDefault {
+		conn = &timeConn{Conn: conn, format: c.timeFormat}
+	}
+	return conn, nil
+}
+
+// Driver returns the connector itself, so the connector can be recovered from sql.DB.Driver()
+func (c *connector) Driver() driver.Driver {
+	return c
+}
+
+// Open implements driver.Driver, it ignores name and opens a connection with the connector's DSN
+func (c *connector) Open(name string) (driver.Conn, error) {
+	return c.Connect(context.Background())
+}
+
+// openFile opens the database file path with the driver and key of c, e.g. for copies of its database,
+// the other settings of c don't apply to it
+func (c *connector) openFile(path string) *sql.DB {
+	file := &connector{driver: c.driver, dsn: path, key: c.getKey()}
+	if file.key != "" {
+		file.hooks = append(file.hooks, applyKey(file))
+	}
+	return sql.OpenDB(file)
+}
+
+// pin opens a connection outside of the pool, it keeps shared in-memory databases alive while the pool is idle
+func (c *connector) pin(ctx context.Context) error {
+	conn, err := c.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned = conn
+	return nil
+}
+
+// Close closes the pinned connection, sql.DB.Close calls it since go 1.17
+func (c *connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pinned == nil {
+		return nil
+	}
+
+	err := c.pinned.Close()
+	c.pinned = nil
+	return err
+}
+
+func (c *connector) getKey() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.key
+}
+
+func (c *connector) setKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = key
+}
+
+func (c *connector) getAttachments() []attachment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.attachments
+}
+
+// execPragma returns a connect hook executing query on each new connection
+func execPragma(query string) connectHook {
+	return func(ctx context.Context, conn driver.Conn) error {
+		return execConn(ctx, conn, query)
+	}
+}
+
+func execConn(ctx context.Context, conn driver.Conn, query string, args ...driver.NamedValue) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, args)
+		return err
+	}
+	return errors.New("sqlite: driver connection does not support ExecContext")
+}
+
+// withSQLiteConn runs fc with a driver connection of conn, it requires the mattn/go-sqlite3 driver
+func withSQLiteConn(ctx context.Context, conn *sql.Conn, fc func(*sqlite3.SQLiteConn) error) error {
+	return conn.Raw(func(driverConn interface{}) error {
+		for {
+			wrapped, ok := driverConn.(interface{ unwrap() driver.Conn })
+			if !ok {
+				break
+			}
+			driverConn = wrapped.unwrap()
+		}
+
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("sqlite: unsupported driver connection %T, requires github.com/mattn/go-sqlite3", driverConn)
+		}
+		return fc(sqliteConn)
+	})
+}
@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ForeignKey describes a foreign key constraint as reported by PRAGMA foreign_key_list
+type ForeignKey struct {
+	ID             int
+	Table          string
+	ReferenceTable string
+	Columns        []string
+	References     []string
+	OnUpdate       string
+	OnDelete       string
+}
+
+// matches reports whether the foreign key implements constraint, references omitted in the DDL match any column
+func (fk ForeignKey) matches(constraint *schema.Constraint) bool {
+	if !strings.EqualFold(fk.ReferenceTable, constraint.ReferenceSchema.Table) ||
+		len(fk.Columns) != len(constraint.ForeignKeys) || len(fk.References) != len(constraint.References) {
+		return false
+	}
+
+	for idx, field := range constraint.ForeignKeys {
+		if !strings.EqualFold(fk.Columns[idx], field.DBName) {
+			return false
+		}
+	}
+
+	for idx, field := range constraint.References {
+		if fk.References[idx] != "" && !strings.EqualFold(fk.References[idx], field.DBName) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check a CHECK constraint of a table, see CheckConstrainer
+type Check struct {
+	// Name constraint name, empty for unnamed constraints
+	Name string
+	// Expression checked expression, e.g. price > 0
+	Expression string
+	// Column the column declaring it as column constraint, empty for table constraints
+	Column string
+}
+
+// matches reports whether c is named name, or is unnamed and checks expression when name is empty
+func (c Check) matches(name, expression string) bool {
+	if name != "" {
+		return strings.EqualFold(c.Name, name)
+	}
+	return c.Name == "" && normalizeExpression(c.Expression) == normalizeExpression(expression)
+}
+
+// CheckConstrainer is implemented by models declaring CHECK constraints in code, in addition to the check tags
+// of their fields, e.g. constraints on several columns. CreateTable adds them as table constraints and AutoMigrate
+// adds missing ones and replaces named ones whose expression changed, e.g.
+//
+//	func (Product) CheckConstraints() []sqlite.Check {
+//		return []sqlite.Check{{Name: "chk_products_price", Expression: "price > 0 AND price >= cost"}}
+//	}
+type CheckConstrainer interface {
+	CheckConstraints() []Check
+}
+
+// declaredChecks returns the CHECK constraints of the model of stmt, declared with check tags and CheckConstrainer
+func declaredChecks(stmt *gorm.Statement) (checks []Check) {
+	if stmt.Schema != nil {
+		for _, chk := range stmt.Schema.ParseCheckConstraints() {
+			checks = append(checks, Check{Name: chk.Name, Expression: chk.Constraint})
+		}
+	}
+	if constrainer, ok := checkConstrainer(stmt); ok {
+		for _, chk := range constrainer.CheckConstraints() {
+			checks = append(checks, Check{Name: chk.Name, Expression: chk.Expression})
+		}
+	}
+	return checks
+}
+
+// checkConstrainer returns the model of stmt as CheckConstrainer, if it implements it
+func checkConstrainer(stmt *gorm.Statement) (CheckConstrainer, bool) {
+	if stmt.Schema == nil {
+		return nil, false
+	}
+	constrainer, ok := reflect.New(stmt.Schema.ModelType).Interface().(CheckConstrainer)
+	return constrainer, ok
+}
+
+// checkSQL returns the table constraint of chk
+func checkSQL(chk Check) string {
+	if chk.Name == "" {
+		return "CHECK (" + chk.Expression + ")"
+	}
+	return "CONSTRAINT " + quoteIdentifier(chk.Name) + " CHECK (" + chk.Expression + ")"
+}
+
+// migrateChecks adds the missing CHECK constraints of value's table and replaces named ones whose expression changed,
+// the table is rebuilt once for all of them
+func (m Migrator) migrateChecks(value interface{}) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		checks := declaredChecks(stmt)
+		if len(checks) == 0 {
+			return nil
+		}
+
+		rawDDL, err := m.getRawDDL(statementTable(stmt))
+		if err != nil || rawDDL == "" {
+			return err
+		}
+		createDDL, err := parseDDL(rawDDL)
+		if err != nil {
+			return err
+		}
+
+		var changed []Check
+		for _, chk := range checks {
+			if existing, ok := createDDL.findCheck(chk.Name, chk.Expression); !ok || normalizeExpression(existing.Expression) != normalizeExpression(chk.Expression) {
+				changed = append(changed, chk)
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		return m.recreateTable(value, nil, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
+			createDDL, err := parseDDL(rawDDL)
+			if err != nil {
+				return "", nil, err
+			}
+			for _, chk := range changed {
+				if chk.Name != "" {
+					createDDL.removeCheck(chk.Name, "")
+				}
+				createDDL.fields = append(createDDL.fields, checkSQL(chk))
+			}
+			return createDDL.compile(), nil, nil
+		})
+	})
+}
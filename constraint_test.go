@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type CheckProduct struct {
+	ID    uint
+	Price int `gorm:"check:chk_check_products_price,price > 0"`
+	Cost  int
+}
+
+func (CheckProduct) CheckConstraints() []Check {
+	return []Check{{Name: "chk_check_products_margin", Expression: "price >= cost"}, {Expression: "cost >= 0"}}
+}
+
+type CheckProductV2 struct {
+	ID    uint
+	Price int `gorm:"check:chk_check_products_price,price > 0"`
+	Cost  int
+}
+
+func (CheckProductV2) TableName() string {
+	return "check_products"
+}
+
+func (CheckProductV2) CheckConstraints() []Check {
+	return []Check{{Name: "chk_check_products_margin", Expression: "price >= cost * 2"}, {Expression: "cost >= 0"}}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	db := openTestDB(t, "check_constraints")
+	if err := db.AutoMigrate(&CheckProduct{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	for _, name := range []string{"chk_check_products_price", "chk_check_products_margin"} {
+		assert.True(t, db.Migrator().HasConstraint(&CheckProduct{}, name), "missing constraint %v", name)
+	}
+	assert.Error(t, db.Create(&CheckProduct{Price: 0}).Error)
+	assert.Error(t, db.Create(&CheckProduct{Price: 5, Cost: 10}).Error)
+	assert.Error(t, db.Create(&CheckProduct{Price: 5, Cost: -1}).Error)
+	assert.NoError(t, db.Create(&CheckProduct{Price: 10, Cost: 5}).Error)
+
+	// a changed expression replaces the constraint, unchanged ones don't rebuild the table
+	if err := db.AutoMigrate(&CheckProductV2{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	assert.Error(t, db.Create(&CheckProductV2{Price: 15, Cost: 10}).Error)
+	assert.NoError(t, db.Create(&CheckProductV2{Price: 20, Cost: 10}).Error)
+	var sql string
+	db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "check_products").Scan(&sql)
+	assert.Equal(t, 1, strings.Count(sql, "chk_check_products_margin"))
+
+	if err := db.Migrator().DropConstraint(&CheckProductV2{}, "chk_check_products_margin"); err != nil {
+		t.Fatalf("failed to drop constraint: %v", err)
+	}
+	assert.False(t, db.Migrator().HasConstraint(&CheckProductV2{}, "chk_check_products_margin"))
+	if err := db.Migrator().CreateConstraint(&CheckProductV2{}, "chk_check_products_margin"); err != nil {
+		t.Fatalf("failed to create constraint: %v", err)
+	}
+	assert.True(t, db.Migrator().HasConstraint(&CheckProductV2{}, "chk_check_products_margin"))
+
+	// column constraints of existing tables
+	db.Exec(`CREATE TABLE "check_items" ("id" integer PRIMARY KEY, "qty" integer CONSTRAINT "chk_qty" CHECK (qty > 0) NOT NULL)`)
+	assert.True(t, db.Migrator().HasConstraint("check_items", "chk_qty"))
+	if err := db.Migrator().DropConstraint("check_items", "chk_qty"); err != nil {
+		t.Fatalf("failed to drop column constraint: %v", err)
+	}
+	assert.False(t, db.Migrator().HasConstraint("check_items", "chk_qty"))
+	assert.NoError(t, db.Table("check_items").Create(map[string]interface{}{"qty": 0}).Error)
+	assert.Error(t, db.Table("check_items").Create(map[string]interface{}{"qty": nil}).Error)
+}
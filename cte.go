@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Materialization hint of a common table expression, SQLite 3.35.0 or later, https://www.sqlite.org/lang_with.html#materialization_hints
+type Materialization string
+
+const (
+	Materialized    Materialization = "MATERIALIZED"
+	NotMaterialized Materialization = "NOT MATERIALIZED"
+)
+
+// CommonTableExpression a named query of a WITH clause, Query is a *gorm.DB, a clause.Expression or a SQL string
+type CommonTableExpression struct {
+	Name            string
+	Columns         []string
+	Materialization Materialization
+	Query           interface{}
+}
+
+// With WITH clause, implements clause.Interface, add it with db.Clauses to queries, updates, deletes and creates,
+// common table expressions of several With clauses are merged, https://www.sqlite.org/lang_with.html
+type With struct {
+	Recursive   bool
+	Expressions []CommonTableExpression
+}
+
+// WithRecursive returns a WITH RECURSIVE clause of the common table expression name, whose rows are the rows of anchor
+// and the rows recursive selects from name until it finds no new rows, e.g. a category and all its descendants
+//
+//	db.Clauses(WithRecursive("tree", db.Model(&Category{}).Where("id = ?", id),
+//		db.Model(&Category{}).Select("categories.*").Joins("JOIN tree ON categories.parent_id = tree.id"))).
+//		Table("tree").Find(&categories)
+//
+// the rows are combined with UNION, so duplicated rows of graphs with cycles end the recursion
+func WithRecursive(name string, anchor, recursive interface{}, columns ...string) With {
+	return With{Recursive: true, Expressions: []CommonTableExpression{{
+		Name:    name,
+		Columns: columns,
+		Query:   clause.Expr{SQL: "? UNION ?", Vars: []interface{}{anchor, recursive}},
+	}}}
+}
+
+// Name implements clause.Interface
+func (With) Name() string {
+	return "WITH"
+}
+
+// Build implements clause.Expression
+func (with With) Build(builder clause.Builder) {
+	if with.Recursive {
+		builder.WriteString("RECURSIVE ")
+	}
+
+	for idx, cte := range with.Expressions {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+
+		builder.WriteQuoted(cte.Name)
+		if len(cte.Columns) > 0 {
+			builder.WriteByte('(')
+			for idx, column := range cte.Columns {
+				if idx > 0 {
+					builder.WriteByte(',')
+				}
+				builder.WriteQuoted(column)
+			}
+			builder.WriteByte(')')
+		}
+
+		builder.WriteString(" AS ")
+		if cte.Materialization != "" {
+			builder.WriteString(string(cte.Materialization))
+			builder.WriteByte(' ')
+		}
+		builder.WriteByte('(')
+		switch query := cte.Query.(type) {
+		case string:
+			builder.WriteString(query)
+		case *gorm.DB, clause.Expression:
+			builder.AddVar(builder, query)
+		}
+		builder.WriteByte(')')
+	}
+}
+
+// MergeClause implements clause.Interface, the expressions are appended to the ones of former With clauses
+func (with With) MergeClause(c *clause.Clause) {
+	if former, ok := c.Expression.(With); ok {
+		with.Recursive = with.Recursive || former.Recursive
+		with.Expressions = append(append([]CommonTableExpression{}, former.Expressions...), with.Expressions...)
+	}
+	c.Expression = with
+}
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CTECategory struct {
+	ID       uint
+	Name     string
+	ParentID *uint
+}
+
+func TestWithRecursive(t *testing.T) {
+	db := openTestDB(t, "with_recursive")
+	if err := db.AutoMigrate(&CTECategory{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	root := CTECategory{Name: "root"}
+	db.Create(&root)
+	child := CTECategory{Name: "child", ParentID: &root.ID}
+	db.Create(&child)
+	db.Create(&CTECategory{Name: "grandchild", ParentID: &child.ID})
+	db.Create(&CTECategory{Name: "other"})
+
+	var categories []CTECategory
+	err := db.Clauses(WithRecursive("tree",
+		db.Model(&CTECategory{}).Where("id = ?", root.ID),
+		db.Model(&CTECategory{}).Select("cte_categories.*").Joins("JOIN tree ON cte_categories.parent_id = tree.id"),
+	)).Table("tree").Order("id").Find(&categories).Error
+	if err != nil {
+		t.Fatalf("failed to query tree: %v", err)
+	}
+	var names []string
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	assert.Equal(t, []string{"root", "child", "grandchild"}, names)
+
+	// cycles end the recursion
+	db.Model(&root).Update("parent_id", child.ID)
+	var count int64
+	err = db.Clauses(WithRecursive("tree",
+		db.Model(&CTECategory{}).Select("id").Where("id = ?", root.ID),
+		db.Model(&CTECategory{}).Select("cte_categories.id").Joins("JOIN tree ON cte_categories.parent_id = tree.id"),
+	)).Table("tree").Count(&count).Error
+	if err != nil {
+		t.Fatalf("failed to query graph: %v", err)
+	}
+	assert.Equal(t, int64(3), count)
+
+	// several clauses are merged, with materialization hints
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+	stmt := dryRun.Clauses(
+		With{Expressions: []CommonTableExpression{{Name: "roots", Materialization: Materialized, Query: dryRun.Model(&CTECategory{}).Where("parent_id IS NULL")}}},
+		With{Expressions: []CommonTableExpression{{Name: "numbers", Columns: []string{"n"}, Materialization: NotMaterialized, Query: clause.Expr{SQL: "VALUES (?),(?)", Vars: []interface{}{1, 2}}}}},
+	).Table("roots").Find(&[]CTECategory{}).Statement
+	assert.Equal(t, `WITH "roots" AS MATERIALIZED (SELECT * FROM "cte_categories" WHERE parent_id IS NULL),"numbers"("n") AS NOT MATERIALIZED (VALUES (?),(?)) SELECT * FROM "roots"`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{1, 2}, stmt.Vars)
+
+	// updates of common table expressions
+	result := db.Clauses(With{Expressions: []CommonTableExpression{{Name: "leaves", Query: "SELECT id FROM cte_categories WHERE id NOT IN (SELECT parent_id FROM cte_categories WHERE parent_id IS NOT NULL)"}}}).
+		Model(&CTECategory{}).Where("id IN (SELECT id FROM leaves)").Update("name", "leaf")
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(2), result.RowsAffected)
+}
@@ -6,20 +6,150 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"gorm.io/gorm/migrator"
 )
 
 var (
-	sqliteSeparator    = "`|\"|'|\t"
-	indexRegexp        = regexp.MustCompile(fmt.Sprintf("CREATE(?: UNIQUE)? INDEX [%v][\\w\\d]+[%v] ON (.*)$", sqliteSeparator, sqliteSeparator))
-	tableRegexp        = regexp.MustCompile(fmt.Sprintf("(?i)(CREATE TABLE [%v]?[\\w\\d]+[%v]?)(?: \\((.*)\\))?", sqliteSeparator, sqliteSeparator))
-	separatorRegexp    = regexp.MustCompile(fmt.Sprintf("[%v]", sqliteSeparator))
-	columnsRegexp      = regexp.MustCompile(fmt.Sprintf("\\([%v]?([\\w\\d]+)[%v]?(?:,[%v]?([\\w\\d]+)[%v]){0,}\\)", sqliteSeparator, sqliteSeparator, sqliteSeparator, sqliteSeparator))
-	columnRegexp       = regexp.MustCompile(fmt.Sprintf("^[%v]?([\\w\\d]+)[%v]?\\s+([\\w\\(\\)\\d]+)(.*)$", sqliteSeparator, sqliteSeparator))
-	defaultValueRegexp = regexp.MustCompile("(?i) DEFAULT \\(?(.+)?\\)?( |COLLATE|GENERATED|$)")
+	identifierPattern     = "(?:\"(?:[^\"]|\"\")+\"|`(?:[^`]|``)+`|\\[[^\\]]+\\]|'(?:[^']|'')+'|[\\w$]+)"
+	tableRegexp           = regexp.MustCompile(fmt.Sprintf("(?is)(CREATE TABLE (?:IF NOT EXISTS )?%v)(?:\\s*\\((.*)\\))?", identifierPattern))
+	columnRegexp          = regexp.MustCompile(fmt.Sprintf("^(%v)\\s+([\\w\\(\\)\\d]+)(.*)$", identifierPattern))
+	columnNameRegexp      = regexp.MustCompile("^" + identifierPattern)
+	primaryKeyRegexp      = regexp.MustCompile("(?is)^PRIMARY\\s+KEY\\s*\\((.*)\\)")
+	defaultFunctionRegexp = regexp.MustCompile(`(?s)^[\w$]+\s*\(.*\)$`)
+	foreignKeyRegexp      = regexp.MustCompile(fmt.Sprintf("(?is)^FOREIGN\\s+KEY\\s*\\(([^)]*)\\)\\s*REFERENCES\\s+(%v)", identifierPattern))
+	referencesRegexp      = regexp.MustCompile(fmt.Sprintf("(?is)\\s+REFERENCES\\s+(%v)(?:\\s*\\([^)]*\\))?(?:\\s+ON\\s+(?:DELETE|UPDATE)\\s+(?:SET\\s+NULL|SET\\s+DEFAULT|CASCADE|RESTRICT|NO\\s+ACTION)|\\s+MATCH\\s+\\w+|\\s+(?:NOT\\s+)?DEFERRABLE(?:\\s+INITIALLY\\s+(?:DEFERRED|IMMEDIATE))?)*", identifierPattern))
+	tableConstraintRegexp = regexp.MustCompile("(?i)^(?:PRIMARY\\s+KEY|CHECK|CONSTRAINT|UNIQUE|FOREIGN\\s+KEY)\\b")
+	generatedColumnRegexp = regexp.MustCompile("(?i)\\s(?:GENERATED\\s+ALWAYS\\s+)?AS\\s*\\(")
+	indexColumnRegexp     = regexp.MustCompile(fmt.Sprintf("(?is)^(%v)(?:\\s+COLLATE\\s+\\S+)?(?:\\s+(?:ASC|DESC))?$", identifierPattern))
+	indexRegexp           = regexp.MustCompile(fmt.Sprintf("(?is)^\\s*CREATE\\s+(UNIQUE\\s+)?INDEX\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(?:%[1]v\\s*\\.\\s*)?(%[1]v)\\s+ON\\s+(%[1]v)\\s*\\(", identifierPattern))
 )
 
+// columnDefault the DEFAULT clause of a column definition
+type columnDefault struct {
+	// Value literal default without quotes, e.g. hello of DEFAULT 'hello'
+	Value string
+	// Expr expression default as written, e.g. CURRENT_TIMESTAMP or (strftime('%s','now'))
+	Expr string
+}
+
+// String returns the expression of expression defaults, the value of literal ones
+func (d columnDefault) String() string {
+	if d.Expr != "" {
+		return d.Expr
+	}
+	return d.Value
+}
+
+// parseColumnDefault returns the DEFAULT clause of the column constraints definition, e.g. ` NOT NULL DEFAULT 0`,
+// DEFAULT keywords in quotes and parentheses, like in CHECK constraints, are skipped
+func parseColumnDefault(definition string) (columnDefault, bool) {
+	var (
+		quote byte
+		depth int
+	)
+	for i := 0; i < len(definition); i++ {
+		c := definition[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			// the type's arguments may begin before definition, e.g. decimal(10, 2)
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && (i == 0 || !isIdentifierChar(definition[i-1])) && len(definition) > i+7 &&
+			strings.EqualFold(definition[i:i+7], "DEFAULT") && !isIdentifierChar(definition[i+7]):
+			return parseDefaultValue(strings.TrimLeft(definition[i+7:], " \t\r\n"))
+		}
+	}
+	return columnDefault{}, false
+}
+
+// parseDefaultValue parses the value following a DEFAULT keyword
+func parseDefaultValue(str string) (columnDefault, bool) {
+	if str == "" {
+		return columnDefault{}, false
+	}
+
+	switch str[0] {
+	case '(':
+		var (
+			quote byte
+			depth int
+		)
+		for i := 0; i < len(str); i++ {
+			switch c := str[i]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth--; depth == 0 {
+					return columnDefault{Expr: str[:i+1]}, true
+				}
+			}
+		}
+		return columnDefault{}, false
+	case '\'', '"':
+		quote := str[:1]
+		for i := 1; i < len(str); i++ {
+			if str[i] != quote[0] {
+				continue
+			}
+			if i+1 < len(str) && str[i+1] == quote[0] {
+				i++
+				continue
+			}
+			return columnDefault{Value: strings.ReplaceAll(str[1:i], quote+quote, quote)}, true
+		}
+		return columnDefault{}, false
+	}
+
+	end := strings.IndexAny(str, " \t\r\n")
+	if end < 0 {
+		end = len(str)
+	}
+	if value := str[:end]; isDefaultKeyword(value) {
+		return columnDefault{Expr: strings.ToUpper(value)}, true
+	}
+	return columnDefault{Value: str[:end]}, true
+}
+
+// isDefaultKeyword reports whether str is a keyword SQLite evaluates when a row is inserted
+func isDefaultKeyword(str string) bool {
+	switch strings.ToUpper(str) {
+	case "CURRENT_TIMESTAMP", "CURRENT_DATE", "CURRENT_TIME":
+		return true
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+type indexDDL struct {
+	unique  bool
+	name    string
+	table   string
+	columns []string
+	where   string
+}
+
 type ddl struct {
 	head    string
 	fields  []string
@@ -49,16 +179,42 @@ func parseDDL(strs ...string) (*ddl, error) {
 					next = []rune(ddlBody)[idx+1]
 				}
 
-				if sc := string(c); separatorRegexp.MatchString(sc) {
-					if c == next {
-						buf += sc // Skip escaped quote
-						idx++
-					} else if quote > 0 {
-						quote = 0
+				if quote == 0 && ((c == '-' && next == '-') || (c == '/' && next == '*')) {
+					// keep comments verbatim, -- comments end with their line
+					end := idx + 2
+					for ; end < len(ddlBodyRunes); end++ {
+						if c == '-' && ddlBodyRunes[end] == '\n' {
+							break
+						}
+						if c == '/' && end > idx+2 && ddlBodyRunes[end-1] == '*' && ddlBodyRunes[end] == '/' {
+							end++
+							break
+						}
+					}
+					// a comment right after a comma belongs to the previous field, e.g. `id` integer, -- row id
+					if strings.TrimSpace(buf) == "" && len(result.fields) > 0 {
+						result.fields[len(result.fields)-1] += " " + string(ddlBodyRunes[idx:end])
 					} else {
-						quote = c
+						buf += string(ddlBodyRunes[idx:end])
 					}
-				} else if quote == 0 {
+					idx = end - 1
+					continue
+				}
+
+				if quote != 0 {
+					if c == quote {
+						if c != ']' && next == c {
+							buf += string(c) // Skip escaped quote
+							idx++
+						} else {
+							quote = 0
+						}
+					}
+				} else if c == '`' || c == '"' || c == '\'' || c == '[' {
+					if quote = c; c == '[' {
+						quote = ']'
+					}
+				} else {
 					if c == '(' {
 						bracketLevel++
 					} else if c == ')' {
@@ -89,17 +245,11 @@ func parseDDL(strs ...string) (*ddl, error) {
 
 			for _, f := range result.fields {
 				fUpper := strings.ToUpper(f)
-				if strings.HasPrefix(fUpper, "CHECK") ||
-					strings.HasPrefix(fUpper, "CONSTRAINT") {
-					continue
-				}
-
 				if strings.HasPrefix(fUpper, "PRIMARY KEY") {
-					matches := columnsRegexp.FindStringSubmatch(f)
-					if len(matches) > 1 {
-						for _, name := range matches[1:] {
+					if matches := primaryKeyRegexp.FindStringSubmatch(f); len(matches) > 0 {
+						for _, name := range splitIdentifiers(matches[1]) {
 							for idx, column := range result.columns {
-								if column.NameValue.String == name {
+								if strings.EqualFold(column.NameValue.String, name) {
 									column.PrimaryKeyValue = sql.NullBool{Bool: true, Valid: true}
 									result.columns[idx] = column
 									break
@@ -107,15 +257,25 @@ func parseDDL(strs ...string) (*ddl, error) {
 							}
 						}
 					}
+				} else if isTableConstraint(f) {
+					continue
 				} else if matches := columnRegexp.FindStringSubmatch(f); len(matches) > 0 {
+					definition, comment, _ := splitComments(f)
+					if definition != f {
+						if matches = columnRegexp.FindStringSubmatch(definition); len(matches) == 0 {
+							continue
+						}
+					}
+
 					columnType := migrator.ColumnType{
-						NameValue:         sql.NullString{String: matches[1], Valid: true},
+						NameValue:         sql.NullString{String: unquoteIdentifier(matches[1]), Valid: true},
 						DataTypeValue:     sql.NullString{String: matches[2], Valid: true},
 						ColumnTypeValue:   sql.NullString{String: matches[2], Valid: true},
 						PrimaryKeyValue:   sql.NullBool{Valid: true},
 						UniqueValue:       sql.NullBool{Valid: true},
 						NullableValue:     sql.NullBool{Valid: true},
 						DefaultValueValue: sql.NullString{Valid: true},
+						CommentValue:      sql.NullString{String: comment, Valid: comment != ""},
 					}
 
 					matchUpper := strings.ToUpper(matches[3])
@@ -130,22 +290,19 @@ func parseDDL(strs ...string) (*ddl, error) {
 					if strings.Contains(matchUpper, " PRIMARY") {
 						columnType.PrimaryKeyValue = sql.NullBool{Bool: true, Valid: true}
 					}
-					if defaultMatches := defaultValueRegexp.FindStringSubmatch(matches[3]); len(defaultMatches) > 1 {
-						columnType.DefaultValueValue = sql.NullString{String: strings.Trim(defaultMatches[1], `"`), Valid: true}
+					// the collation is part of the column type, so a changed collation is migrated
+					if collation := collateRegexp.FindStringSubmatch(matches[3]); collation != nil {
+						columnType.ColumnTypeValue.String += " COLLATE " + quoteIdentifier(unquoteIdentifier(collation[1]))
+					}
+					if columnDefault, ok := parseColumnDefault(matches[3]); ok {
+						columnType.DefaultValueValue = sql.NullString{String: columnDefault.String(), Valid: true}
 					}
 
 					result.columns = append(result.columns, columnType)
 				}
 			}
-		} else if matches := indexRegexp.FindStringSubmatch(str); len(matches) > 0 {
-			if columns := columnsRegexp.FindStringSubmatch(matches[1]); len(columns) == 1 {
-				for idx, c := range result.columns {
-					if c.NameValue.String == columns[0] {
-						c.UniqueValue = sql.NullBool{Bool: true, Valid: true}
-						result.columns[idx] = c
-					}
-				}
-			}
+		} else if _, err := parseIndexDDL(str); err == nil {
+			// unique indexes are reported by GetIndexes, not as column uniqueness
 		} else {
 			return nil, errors.New("invalid DDL")
 		}
@@ -159,11 +316,69 @@ func (d *ddl) compile() string {
 		return d.head
 	}
 
-	return fmt.Sprintf("%s (%s)", d.head, strings.Join(d.fields, ","))
+	fields := make([]string, len(d.fields))
+	for i, field := range d.fields {
+		// a -- comment runs to the end of the line, so the field has to end it
+		if _, _, lineComment := splitComments(field); lineComment {
+			field += "\n"
+		}
+		fields[i] = field
+	}
+	return fmt.Sprintf("%s (%s)", d.head, strings.Join(fields, ","))
+}
+
+// columnIndex returns the index of the field defining column name, -1 if there is none
+func (d *ddl) columnIndex(name string) int {
+	for i, f := range d.fields {
+		if isTableConstraint(f) {
+			continue
+		}
+
+		if matches := columnRegexp.FindStringSubmatch(f); len(matches) > 0 && strings.EqualFold(unquoteIdentifier(matches[1]), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceColumn replaces the definition of column name, it returns false if the column doesn't exist
+func (d *ddl) replaceColumn(name, definition string) bool {
+	if idx := d.columnIndex(name); idx >= 0 {
+		d.fields[idx] = definition
+		return true
+	}
+	return false
+}
+
+// removeColumn removes the definition of column name, it returns false if the column doesn't exist
+func (d *ddl) removeColumn(name string) bool {
+	if idx := d.columnIndex(name); idx >= 0 {
+		d.fields = append(d.fields[:idx], d.fields[idx+1:]...)
+		return true
+	}
+	return false
+}
+
+// renameColumn renames column oldName to newName in its definition and in table constraints referring to it,
+// it returns false if the column doesn't exist
+func (d *ddl) renameColumn(oldName, newName string) bool {
+	idx := d.columnIndex(oldName)
+	if idx < 0 {
+		return false
+	}
+
+	for i, f := range d.fields {
+		if i == idx {
+			d.fields[i] = quoteIdentifier(newName) + f[columnRegexp.FindStringSubmatchIndex(f)[3]:]
+		} else {
+			d.fields[i] = renameIdentifier(f, oldName, newName)
+		}
+	}
+	return true
 }
 
 func (d *ddl) addConstraint(name string, sql string) {
-	reg := regexp.MustCompile("^CONSTRAINT [\"`]?" + regexp.QuoteMeta(name) + "[\"` ]")
+	reg := constraintNameRegexp(name)
 
 	for i := 0; i < len(d.fields); i++ {
 		if reg.MatchString(d.fields[i]) {
@@ -176,7 +391,7 @@ func (d *ddl) addConstraint(name string, sql string) {
 }
 
 func (d *ddl) removeConstraint(name string) bool {
-	reg := regexp.MustCompile("^CONSTRAINT [\"`]?" + regexp.QuoteMeta(name) + "[\"` ]")
+	reg := constraintNameRegexp(name)
 
 	for i := 0; i < len(d.fields); i++ {
 		if reg.MatchString(d.fields[i]) {
@@ -188,7 +403,7 @@ func (d *ddl) removeConstraint(name string) bool {
 }
 
 func (d *ddl) hasConstraint(name string) bool {
-	reg := regexp.MustCompile("^CONSTRAINT [\"`]?" + regexp.QuoteMeta(name) + "[\"` ]")
+	reg := constraintNameRegexp(name)
 
 	for _, f := range d.fields {
 		if reg.MatchString(f) {
@@ -198,23 +413,409 @@ func (d *ddl) hasConstraint(name string) bool {
 	return false
 }
 
+func (d *ddl) renameConstraint(oldName, newName string) bool {
+	reg := constraintNameRegexp(oldName)
+
+	for i := 0; i < len(d.fields); i++ {
+		if loc := reg.FindStringSubmatchIndex(d.fields[i]); loc != nil {
+			d.fields[i] = d.fields[i][:loc[2]] + quoteIdentifier(newName) + d.fields[i][loc[3]:]
+			return true
+		}
+	}
+	return false
+}
+
+// removeForeignKey removes an unnamed foreign key on columns referencing refTable,
+// both table constraints and column REFERENCES clauses are supported
+func (d *ddl) removeForeignKey(columns []string, refTable string) bool {
+	for i := 0; i < len(d.fields); i++ {
+		if matches := foreignKeyRegexp.FindStringSubmatch(d.fields[i]); len(matches) > 0 {
+			if strings.EqualFold(unquoteIdentifier(matches[2]), refTable) && equalIdentifiers(splitIdentifiers(matches[1]), columns) {
+				d.fields = append(d.fields[:i], d.fields[i+1:]...)
+				return true
+			}
+		} else if len(columns) == 1 {
+			if matches := columnRegexp.FindStringSubmatch(d.fields[i]); len(matches) > 0 && strings.EqualFold(unquoteIdentifier(matches[1]), columns[0]) {
+				if loc := referencesRegexp.FindStringSubmatchIndex(d.fields[i]); loc != nil &&
+					strings.EqualFold(unquoteIdentifier(d.fields[i][loc[2]:loc[3]]), refTable) {
+					d.fields[i] = d.fields[i][:loc[0]] + d.fields[i][loc[1]:]
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkClause a CHECK constraint of a field, start and end are its offsets in the field, including its CONSTRAINT name
+type checkClause struct {
+	Check
+	start, end int
+}
+
+// parseCheckClauses returns the CHECK constraints of field, a table constraint or the constraints of a column definition
+func parseCheckClauses(field string) (clauses []checkClause) {
+	var (
+		column       string
+		quote        byte
+		depth        int
+		name         string
+		nameStart    = -1
+		isConstraint = isTableConstraint(field)
+	)
+	if !isConstraint {
+		column = unquoteIdentifier(columnNameRegexp.FindString(field))
+	}
+
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			continue
+		case c == '[':
+			quote = ']'
+			continue
+		case c == '-' && strings.HasPrefix(field[i:], "--"):
+			if end := strings.IndexByte(field[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(field)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(field[i:], "/*"):
+			if end := strings.Index(field[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(field)
+			}
+			continue
+		case c == '(':
+			depth++
+			continue
+		case c == ')':
+			depth--
+			continue
+		}
+		if depth != 0 || (i > 0 && isIdentifierChar(field[i-1])) {
+			continue
+		}
+
+		if keywordAt(field, i, "CONSTRAINT") {
+			rest := strings.TrimLeft(field[i+len("CONSTRAINT"):], " \t\r\n")
+			if quoted := columnNameRegexp.FindString(rest); quoted != "" {
+				name, nameStart = unquoteIdentifier(quoted), i
+				i = len(field) - len(rest) + len(quoted) - 1
+			}
+			continue
+		}
+		if !keywordAt(field, i, "CHECK") {
+			if !unicode.IsSpace(rune(c)) {
+				name, nameStart = "", -1
+			}
+			continue
+		}
+
+		open := i + len("CHECK")
+		for open < len(field) && unicode.IsSpace(rune(field[open])) {
+			open++
+		}
+		end := closingParenthesis(field, open)
+		if end < 0 {
+			return clauses
+		}
+
+		clause := checkClause{Check: Check{Name: name, Expression: strings.TrimSpace(field[open+1 : end]), Column: column}, start: i, end: end + 1}
+		if nameStart >= 0 {
+			clause.start = nameStart
+		}
+		clauses = append(clauses, clause)
+		name, nameStart, i = "", -1, end
+	}
+	return clauses
+}
+
+// keywordAt reports whether keyword is at offset i of str, followed by a character that can't continue it
+func keywordAt(str string, i int, keyword string) bool {
+	return len(str) >= i+len(keyword) && strings.EqualFold(str[i:i+len(keyword)], keyword) &&
+		(len(str) == i+len(keyword) || !isIdentifierChar(str[i+len(keyword)]))
+}
+
+// closingParenthesis returns the offset of the parenthesis closing the one at offset open of str, -1 if there is none
+func closingParenthesis(str string, open int) int {
+	if open >= len(str) || str[open] != '(' {
+		return -1
+	}
+
+	var (
+		quote byte
+		depth int
+	)
+	for i := open; i < len(str); i++ {
+		switch c := str[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// checks returns the CHECK constraints of the table, both table constraints and column constraints
+func (d *ddl) checks() (checks []Check) {
+	for _, f := range d.fields {
+		for _, clause := range parseCheckClauses(f) {
+			checks = append(checks, clause.Check)
+		}
+	}
+	return checks
+}
+
+// findCheck returns the CHECK constraint named name, or an unnamed one with expression when name is empty
+func (d *ddl) findCheck(name, expression string) (Check, bool) {
+	for _, chk := range d.checks() {
+		if chk.matches(name, expression) {
+			return chk, true
+		}
+	}
+	return Check{}, false
+}
+
+// removeCheck removes the CHECK constraint named name, or an unnamed one with expression, CHECK table constraints
+// are removed with their field, column constraints are cut from their column definition
+func (d *ddl) removeCheck(name, expression string) bool {
+	for i := 0; i < len(d.fields); i++ {
+		for _, clause := range parseCheckClauses(d.fields[i]) {
+			if !clause.matches(name, expression) {
+				continue
+			}
+
+			if clause.Column == "" {
+				d.fields = append(d.fields[:i], d.fields[i+1:]...)
+			} else {
+				d.fields[i] = strings.TrimRight(d.fields[i][:clause.start], " \t\r\n") + d.fields[i][clause.end:]
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// getColumns returns the quoted names of the stored columns, generated columns can't be copied into a rebuilt table
 func (d *ddl) getColumns() []string {
 	res := []string{}
 
 	for _, f := range d.fields {
-		fUpper := strings.ToUpper(f)
-		if strings.HasPrefix(fUpper, "PRIMARY KEY") ||
-			strings.HasPrefix(fUpper, "CHECK") ||
-			strings.HasPrefix(fUpper, "CONSTRAINT") {
+		if definition, _, _ := splitComments(f); isTableConstraint(f) || generatedColumnRegexp.MatchString(definition) {
 			continue
 		}
 
-		reg := regexp.MustCompile("^[\"`']?([\\w\\d]+)[\"`']?")
-		match := reg.FindStringSubmatch(f)
-
-		if match != nil {
-			res = append(res, "`"+match[1]+"`")
+		if name := columnNameRegexp.FindString(f); name != "" {
+			res = append(res, quoteIdentifier(unquoteIdentifier(name)))
 		}
 	}
 	return res
 }
+
+// splitComments removes the -- and /* */ comments of str, returning their text separately,
+// lineComment reports whether str has a -- comment, which runs to the end of its line
+func splitComments(str string) (definition, comment string, lineComment bool) {
+	var (
+		quote    byte
+		buf      strings.Builder
+		comments []string
+	)
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quote > 0:
+			if c == quote || (quote == '[' && c == ']') {
+				quote = 0
+			}
+		case c == '`' || c == '"' || c == '\'' || c == '[':
+			quote = c
+		case c == '-' && i+1 < len(str) && str[i+1] == '-':
+			end := strings.IndexByte(str[i:], '\n')
+			if end < 0 {
+				end = len(str) - i
+			}
+			comments = append(comments, strings.TrimSpace(str[i+2:i+end]))
+			lineComment = true
+			i += end - 1
+			continue
+		case c == '/' && i+1 < len(str) && str[i+1] == '*':
+			end := strings.Index(str[i+2:], "*/")
+			if end < 0 {
+				end = len(str) - i - 2
+			}
+			comments = append(comments, strings.TrimSpace(str[i+2:i+2+end]))
+			i += end + 3
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return strings.TrimSpace(buf.String()), strings.Join(comments, " "), lineComment
+}
+
+// parseIndexDDL parses a CREATE INDEX statement, keeping column expressions and the WHERE clause of partial indexes verbatim
+func parseIndexDDL(str string) (*indexDDL, error) {
+	loc := indexRegexp.FindStringSubmatchIndex(str)
+	if loc == nil {
+		return nil, errors.New("invalid index DDL")
+	}
+
+	var (
+		result       = indexDDL{unique: loc[2] >= 0, name: unquoteIdentifier(str[loc[4]:loc[5]]), table: unquoteIdentifier(str[loc[6]:loc[7]])}
+		bodyRunes    = []rune(str[loc[1]:])
+		bracketLevel = 1
+		quote        rune
+		buf          string
+		idx          int
+	)
+
+	for ; idx < len(bodyRunes) && bracketLevel > 0; idx++ {
+		c := bodyRunes[idx]
+		switch {
+		case quote > 0:
+			if c == quote || (quote == '[' && c == ']') {
+				quote = 0
+			}
+		case c == '`' || c == '"' || c == '\'' || c == '[':
+			quote = c
+		case c == '(':
+			bracketLevel++
+		case c == ')':
+			bracketLevel--
+			if bracketLevel == 0 {
+				continue
+			}
+		case c == ',' && bracketLevel == 1:
+			result.columns = append(result.columns, strings.TrimSpace(buf))
+			buf = ""
+			continue
+		}
+		buf += string(c)
+	}
+
+	if bracketLevel != 0 {
+		return nil, errors.New("invalid index DDL, unbalanced brackets")
+	}
+	result.columns = append(result.columns, strings.TrimSpace(buf))
+
+	if rest := strings.TrimSpace(string(bodyRunes[idx:])); rest != "" {
+		if len(rest) < 6 || !strings.EqualFold(rest[:5], "WHERE") || !unicode.IsSpace(rune(rest[5])) {
+			return nil, errors.New("invalid index DDL")
+		}
+		result.where = strings.TrimSpace(rest[5:])
+	}
+
+	return &result, nil
+}
+
+// renameIndexDDL returns the CREATE INDEX statement str creating the index as name in schema,
+// the original name is kept when name is empty and the schema is omitted when schema is empty
+func renameIndexDDL(str, schema, name string) (string, error) {
+	loc := indexRegexp.FindStringSubmatchIndex(str)
+	if loc == nil {
+		return "", errors.New("invalid index DDL")
+	}
+
+	if name == "" {
+		name = unquoteIdentifier(str[loc[4]:loc[5]])
+	}
+	name = quoteIdentifier(name)
+	if schema != "" {
+		name = quoteIdentifier(schema) + "." + name
+	}
+	return str[:loc[4]] + name + str[loc[5]:], nil
+}
+
+// indexColumnName returns the column name of an indexed column, ok is false if it is an expression
+func indexColumnName(str string) (name string, ok bool) {
+	if matches := indexColumnRegexp.FindStringSubmatch(strings.TrimSpace(str)); len(matches) > 0 {
+		return unquoteIdentifier(matches[1]), true
+	}
+	return "", false
+}
+
+// unquoteIdentifier removes SQLite identifier quotes, "name", `name`, [name] or 'name'
+func unquoteIdentifier(str string) string {
+	str = strings.TrimSpace(str)
+	if len(str) < 2 {
+		return str
+	}
+
+	switch first, last := str[0], str[len(str)-1]; {
+	case first == '[' && last == ']':
+		return str[1 : len(str)-1]
+	case (first == '"' || first == '`' || first == '\'') && last == first:
+		q := string(first)
+		return strings.ReplaceAll(str[1:len(str)-1], q+q, q)
+	}
+	return str
+}
+
+// isTableConstraint reports whether the field of a CREATE TABLE statement is a table constraint instead of a column
+func isTableConstraint(field string) bool {
+	return tableConstraintRegexp.MatchString(field)
+}
+
+// renameIdentifier replaces the quoted identifier oldName in str with newName, unquoted words are kept,
+// as they can't be told apart from keywords and functions
+func renameIdentifier(str, oldName, newName string) string {
+	quoted := regexp.QuoteMeta(oldName)
+	reg := regexp.MustCompile("(?i)\"" + quoted + "\"|`" + quoted + "`|\\[" + quoted + "\\]")
+	return reg.ReplaceAllLiteralString(str, quoteIdentifier(newName))
+}
+
+// constraintNameRegexp matches a table constraint named name, the submatch is the quoted name
+func constraintNameRegexp(name string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	return regexp.MustCompile("(?i)^CONSTRAINT\\s+(\"" + quoted + "\"|`" + quoted + "`|\\[" + quoted + "\\]|" + quoted + ")\\s")
+}
+
+// splitIdentifiers splits a comma separated identifier list and unquotes each identifier
+func splitIdentifiers(str string) (results []string) {
+	for _, name := range strings.Split(str, ",") {
+		results = append(results, unquoteIdentifier(name))
+	}
+	return
+}
+
+func equalIdentifiers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if !strings.EqualFold(a[idx], b[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeExpression strips whitespace and identifier quotes so equivalent expressions compare equal
+func normalizeExpression(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '`' || r == '"' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(str))
+}
@@ -35,6 +35,11 @@ func TestParseDDL(t *testing.T) {
 			{NameValue: sql.NullString{String: "ID", Valid: true}, DataTypeValue: sql.NullString{String: "int", Valid: true}, ColumnTypeValue: sql.NullString{String: "int", Valid: true}, NullableValue: sql.NullBool{Bool: false, Valid: true}, DefaultValueValue: sql.NullString{Valid: true}, UniqueValue: sql.NullBool{Valid: true}, PrimaryKeyValue: sql.NullBool{Valid: true}},
 		},
 		},
+		{"with_collate", []string{"CREATE TABLE `files` (`name` text NOT NULL COLLATE natural,`title` text COLLATE \"unicode_nocase\")"}, 2, []migrator.ColumnType{
+			{NameValue: sql.NullString{String: "name", Valid: true}, DataTypeValue: sql.NullString{String: "text", Valid: true}, ColumnTypeValue: sql.NullString{String: "text COLLATE \"natural\"", Valid: true}, NullableValue: sql.NullBool{Valid: true}, DefaultValueValue: sql.NullString{Valid: true}, UniqueValue: sql.NullBool{Valid: true}, PrimaryKeyValue: sql.NullBool{Valid: true}},
+			{NameValue: sql.NullString{String: "title", Valid: true}, DataTypeValue: sql.NullString{String: "text", Valid: true}, ColumnTypeValue: sql.NullString{String: "text COLLATE \"unicode_nocase\"", Valid: true}, NullableValue: sql.NullBool{Valid: true}, DefaultValueValue: sql.NullString{Valid: true}, UniqueValue: sql.NullBool{Valid: true}, PrimaryKeyValue: sql.NullBool{Valid: true}},
+		},
+		},
 		{"no brackets", []string{"create table test"}, 0, nil},
 	}
 
@@ -173,17 +178,17 @@ func TestGetColumns(t *testing.T) {
 		{
 			name:    "with_fk",
 			ddl:     "CREATE TABLE `notes` (`id` integer NOT NULL,`text` varchar(500),`user_id` integer,PRIMARY KEY (`id`),CONSTRAINT `fk_users_notes` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
-			columns: []string{"`id`", "`text`", "`user_id`"},
+			columns: []string{`"id"`, `"text"`, `"user_id"`},
 		},
 		{
 			name:    "with_check",
 			ddl:     "CREATE TABLE Persons (ID int NOT NULL,LastName varchar(255) NOT NULL,FirstName varchar(255),Age int,CHECK (Age>=18),CHECK (FirstName!='John'))",
-			columns: []string{"`ID`", "`LastName`", "`FirstName`", "`Age`"},
+			columns: []string{`"ID"`, `"LastName"`, `"FirstName"`, `"Age"`},
 		},
 		{
 			name:    "with_escaped_quote",
 			ddl:     "CREATE TABLE Persons (ID int NOT NULL,LastName varchar(255) NOT NULL DEFAULT \"\",FirstName varchar(255))",
-			columns: []string{"`ID`", "`LastName`", "`FirstName`"},
+			columns: []string{`"ID"`, `"LastName"`, `"FirstName"`},
 		},
 	}
 
@@ -200,3 +205,165 @@ func TestGetColumns(t *testing.T) {
 		})
 	}
 }
+
+func TestParseIndexDDL(t *testing.T) {
+	params := []struct {
+		name  string
+		sql   string
+		index indexDDL
+	}{
+		{"simple", "CREATE INDEX `idx_users_name` ON `users`(`name`)", indexDDL{name: "idx_users_name", table: "users", columns: []string{"`name`"}}},
+		{"unique_multi", "CREATE UNIQUE INDEX \"idx_users_email_age\" ON \"users\" (\"email\" COLLATE NOCASE, \"age\" DESC)", indexDDL{unique: true, name: "idx_users_email_age", table: "users", columns: []string{"\"email\" COLLATE NOCASE", "\"age\" DESC"}}},
+		{"partial", "create index if not exists main.idx_active on users(name) where deleted_at is null and (age > 18)", indexDDL{name: "idx_active", table: "users", columns: []string{"name"}, where: "deleted_at is null and (age > 18)"}},
+		{"expression", "CREATE UNIQUE INDEX `idx_email` ON `users`(lower(`email`), json_extract(data, '$.a,b') DESC)", indexDDL{unique: true, name: "idx_email", table: "users", columns: []string{"lower(`email`)", "json_extract(data, '$.a,b') DESC"}}},
+		{"brackets", "CREATE INDEX [idx x] ON [users]([first name], [last name])", indexDDL{name: "idx x", table: "users", columns: []string{"[first name]", "[last name]"}}},
+		{"quoted_where", "CREATE INDEX `idx_where` ON `users`(`where`) WHERE `where` <> 'WHERE x'", indexDDL{name: "idx_where", table: "users", columns: []string{"`where`"}, where: "`where` <> 'WHERE x'"}},
+	}
+
+	for _, p := range params {
+		t.Run(p.name, func(t *testing.T) {
+			idx, err := parseIndexDDL(p.sql)
+			if err != nil {
+				t.Fatalf("failed to parse index: %v", err)
+			}
+			assert.Equal(t, p.index, *idx)
+		})
+	}
+
+	if _, err := parseIndexDDL("CREATE INDEX idx ON users(name"); err == nil {
+		t.Errorf("Expected unbalanced brackets to fail")
+	}
+}
+
+func TestRemoveForeignKey(t *testing.T) {
+	params := []struct {
+		name     string
+		fields   []string
+		columns  []string
+		refTable string
+		success  bool
+		expect   []string
+	}{
+		{
+			name:     "table_constraint",
+			fields:   []string{"`id` integer", "`user_id` integer", "FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE"},
+			columns:  []string{"user_id"},
+			refTable: "users",
+			success:  true,
+			expect:   []string{"`id` integer", "`user_id` integer"},
+		},
+		{
+			name:     "column_references",
+			fields:   []string{"`id` integer", "user_id integer NOT NULL REFERENCES users(id) ON UPDATE SET NULL DEFERRABLE INITIALLY DEFERRED"},
+			columns:  []string{"user_id"},
+			refTable: "users",
+			success:  true,
+			expect:   []string{"`id` integer", "user_id integer NOT NULL"},
+		},
+		{
+			name:     "other_table",
+			fields:   []string{"`user_id` integer", "FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)"},
+			columns:  []string{"user_id"},
+			refTable: "companies",
+			success:  false,
+			expect:   []string{"`user_id` integer", "FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)"},
+		},
+	}
+
+	for _, p := range params {
+		t.Run(p.name, func(t *testing.T) {
+			testDDL := ddl{fields: p.fields}
+
+			success := testDDL.removeForeignKey(p.columns, p.refTable)
+
+			assert.Equal(t, p.success, success)
+			assert.Equal(t, p.expect, testDDL.fields)
+		})
+	}
+}
+
+func TestRemoveCheck(t *testing.T) {
+	testDDL := ddl{fields: []string{"`age` integer", "CHECK (`age` >= 18)", "CHECK (age < 200)"}}
+
+	assert.True(t, testDDL.removeCheck("", "age>=18"))
+	assert.False(t, testDDL.removeCheck("", "age > 200"))
+	assert.Equal(t, []string{"`age` integer", "CHECK (age < 200)"}, testDDL.fields)
+}
+
+func TestParseChecks(t *testing.T) {
+	createDDL, err := parseDDL(`CREATE TABLE "products" ("id" integer PRIMARY KEY,"price" decimal(10,2) NOT NULL CHECK (price > 0),` +
+		`"code" text CONSTRAINT "chk_code" CHECK (length(code) = 8 AND code <> ')') UNIQUE,"note" text -- check (note)` + "\n," +
+		`CHECK (price < 1000),CONSTRAINT [chk_note] CHECK (note IS NULL OR (note <> ''')')))`)
+	if err != nil {
+		t.Fatalf("failed to parse DDL: %v", err)
+	}
+
+	assert.Equal(t, []Check{
+		{Expression: "price > 0", Column: "price"},
+		{Name: "chk_code", Expression: "length(code) = 8 AND code <> ')'", Column: "code"},
+		{Expression: "price < 1000"},
+		{Name: "chk_note", Expression: "note IS NULL OR (note <> ''')')"},
+	}, createDDL.checks())
+
+	assert.True(t, createDDL.removeCheck("chk_code", ""))
+	assert.True(t, createDDL.removeCheck("", "price>0"))
+	assert.False(t, createDDL.removeCheck("chk_code", ""))
+	assert.True(t, createDDL.removeCheck("CHK_NOTE", ""))
+	assert.Equal(t, []string{
+		`"id" integer PRIMARY KEY`, `"price" decimal(10,2) NOT NULL`, `"code" text UNIQUE`,
+		`"note" text -- check (note)`, "CHECK (price < 1000)",
+	}, createDDL.fields)
+}
+
+func TestRenameConstraint(t *testing.T) {
+	testDDL := ddl{fields: []string{"`id` integer", "constraint [chk_age] CHECK (age > 18)"}}
+
+	assert.True(t, testDDL.renameConstraint("chk_age", "chk_adult"))
+	assert.False(t, testDDL.renameConstraint("chk_age", "chk_other"))
+	assert.Equal(t, []string{"`id` integer", "constraint \"chk_adult\" CHECK (age > 18)"}, testDDL.fields)
+}
+
+func TestParseDDLComments(t *testing.T) {
+	ddl, err := parseDDL("CREATE TABLE `posts` (`id` integer, -- row id\n`title` text NOT NULL -- it's the title, (required)\n,`body` text /* markdown */ DEFAULT \"\")")
+	if err != nil {
+		t.Fatalf("failed to parse DDL: %v", err)
+	}
+
+	assert.Equal(t, []string{`"id"`, `"title"`, `"body"`}, ddl.getColumns())
+	comments := map[string]string{}
+	for _, column := range ddl.columns {
+		comments[column.NameValue.String] = column.CommentValue.String
+	}
+	assert.Equal(t, map[string]string{"id": "row id", "title": "it's the title, (required)", "body": "markdown"}, comments)
+	assert.False(t, ddl.columns[1].NullableValue.Bool)
+
+	assert.True(t, ddl.removeColumn("id"))
+	assert.False(t, ddl.removeColumn("missing"))
+	assert.Equal(t, "CREATE TABLE `posts` (`title` text NOT NULL -- it's the title, (required)\n,`body` text /* markdown */ DEFAULT \"\")", ddl.compile())
+}
+
+func TestParseColumnDefault(t *testing.T) {
+	params := []struct {
+		definition string
+		expected   columnDefault
+		ok         bool
+	}{
+		{" NOT NULL DEFAULT 0", columnDefault{Value: "0"}, true},
+		{" DEFAULT -1.5 NOT NULL", columnDefault{Value: "-1.5"}, true},
+		{" DEFAULT 'it''s' COLLATE NOCASE", columnDefault{Value: "it's"}, true},
+		{` DEFAULT "hello"`, columnDefault{Value: "hello"}, true},
+		{" DEFAULT current_timestamp NOT NULL", columnDefault{Expr: "CURRENT_TIMESTAMP"}, true},
+		{" DEFAULT (strftime('%s','now')) NOT NULL", columnDefault{Expr: "(strftime('%s','now'))"}, true},
+		{" DEFAULT(lower(')'))", columnDefault{Expr: "(lower(')'))"}, true},
+		{" CHECK (status <> 'DEFAULT 1') DEFAULT 2", columnDefault{Value: "2"}, true},
+		{" 2) DEFAULT 1.5", columnDefault{Value: "1.5"}, true},
+		{" REFERENCES defaults(id)", columnDefault{}, false},
+		{" NOT NULL", columnDefault{}, false},
+	}
+
+	for _, p := range params {
+		columnDefault, ok := parseColumnDefault(p.definition)
+		assert.Equal(t, p.ok, ok, p.definition)
+		assert.Equal(t, p.expected, columnDefault, p.definition)
+	}
+}
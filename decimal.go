@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+var (
+	decimalTypeRegexp  = regexp.MustCompile(`(?i)^\s*(decimal_text|decimal|numeric)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?\s*$`)
+	decimalValueRegexp = regexp.MustCompile(`^[+-]?(?:\d+(?:\.\d*)?|\.\d+)$`)
+)
+
+// Decimal a decimal number kept as its exact text, e.g. 12345678901234567890.123456789,
+// its columns are declared as decimal_text, so SQLite stores it as text instead of rounding it to a float64.
+// Text compares as text, e.g. in ORDER BY, while arithmetic functions like SUM convert it to a number
+type Decimal string
+
+// GormDataType declares Decimal columns as decimal_text
+func (Decimal) GormDataType() string {
+	return "decimal_text"
+}
+
+// Scan implements sql.Scanner, see ScanDecimal
+func (d *Decimal) Scan(value interface{}) error {
+	str, err := ScanDecimal(value)
+	*d = Decimal(str)
+	return err
+}
+
+// Value implements driver.Valuer, the zero value is stored as NULL, which scans back as the zero value
+func (d Decimal) Value() (driver.Value, error) {
+	if d == "" {
+		return nil, nil
+	}
+	if !decimalValueRegexp.MatchString(string(d)) {
+		return nil, fmt.Errorf("sqlite: invalid decimal %q", string(d))
+	}
+	return string(d), nil
+}
+
+// Rat returns d as a big.Rat, ok is false if d isn't a decimal number
+func (d Decimal) Rat() (r *big.Rat, ok bool) {
+	if d == "" {
+		return new(big.Rat), true
+	}
+	if !decimalValueRegexp.MatchString(string(d)) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(d))
+}
+
+// ScanDecimal returns the exact text of a decimal scanned from SQLite, integers and reals stored by columns with NUMERIC
+// affinity are formatted without exponent, reals with the fewest digits that read back as the same float64.
+// It suits sql.Scanner implementations of decimal types, e.g. passing the result to decimal.NewFromString
+func ScanDecimal(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("sqlite: can't scan %T into a decimal", value)
+}
+
+// decimalDataType returns the decimal_text type of decimal fields stored as text, fields declared as decimal_text and,
+// with Config.DecimalAsText, as decimal or numeric. The precision of the type is kept, falling back to the precision
+// and scale tags and then to the config
+func (dialector Dialector) decimalDataType(field *schema.Field) (string, bool) {
+	matches := decimalTypeRegexp.FindStringSubmatch(string(field.DataType))
+	if matches == nil || !strings.EqualFold(matches[1], "decimal_text") && !dialector.DecimalAsText {
+		return "", false
+	}
+
+	precision, scale := field.Precision, field.Scale
+	if matches[2] != "" {
+		precision, _ = strconv.Atoi(matches[2])
+		scale = 0
+		if matches[3] != "" {
+			scale, _ = strconv.Atoi(matches[3])
+		}
+	}
+	if precision == 0 {
+		precision, scale = dialector.DecimalPrecision, dialector.DecimalScale
+	}
+
+	if precision == 0 {
+		return "decimal_text", true
+	}
+	return fmt.Sprintf("decimal_text(%d,%d)", precision, scale), true
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDecimal(t *testing.T) {
+	type Invoice struct {
+		ID       uint
+		Amount   Decimal
+		Price    string  `gorm:"type:decimal(30,10)"`
+		Discount Decimal `gorm:"precision:10;scale:4"`
+		Tax      *Decimal
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{DecimalAsText: true, DecimalPrecision: 20, DecimalScale: 6}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&Invoice{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+
+	ddl, _ := db.Migrator().(Migrator).getRawDDL("invoices")
+	assert.Equal(t, `CREATE TABLE "invoices" ("id" integer,"amount" decimal_text(20,6),"price" decimal_text(30,10),"discount" decimal_text(10,4),"tax" decimal_text(20,6),PRIMARY KEY ("id"))`, ddl)
+
+	invoice := Invoice{Amount: "12345678901234567890.123456789", Price: "98765432109876543210.0123456789"}
+	if err := db.Create(&invoice).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	var storage string
+	db.Raw("SELECT typeof(amount) || typeof(price) || typeof(discount) || typeof(tax) FROM invoices").Scan(&storage)
+	assert.Equal(t, "texttextnullnull", storage, "the zero decimal is NULL")
+
+	var result Invoice
+	if err := db.First(&result, invoice.ID).Error; err != nil {
+		t.Fatalf("failed to find: %v", err)
+	}
+	assert.Equal(t, invoice.Amount, result.Amount)
+	assert.Equal(t, invoice.Price, result.Price)
+	assert.Equal(t, Decimal(""), result.Discount)
+	assert.Nil(t, result.Tax)
+
+	value, err := Decimal("").Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	if err := db.Create(&Invoice{Amount: "1e10"}).Error; err == nil {
+		t.Errorf("Expected invalid decimal to fail")
+	}
+
+	// decimal and numeric columns keep NUMERIC affinity without DecimalAsText
+	db, err = gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Invoice{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	ddl, _ = db.Migrator().(Migrator).getRawDDL("invoices")
+	assert.Equal(t, `CREATE TABLE "invoices" ("id" integer,"amount" decimal_text,"price" decimal(30,10),"discount" decimal_text(10,4),"tax" decimal_text,PRIMARY KEY ("id"))`, ddl)
+}
+
+func TestScanDecimal(t *testing.T) {
+	params := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, ""},
+		{"1.50", "1.50"},
+		{[]byte("-0.25"), "-0.25"},
+		{int64(9007199254740993), "9007199254740993"},
+		{0.1, "0.1"},
+		{1e21, "1000000000000000000000"},
+	}
+
+	for _, p := range params {
+		if str, err := ScanDecimal(p.value); err != nil || str != p.expected {
+			t.Errorf("Expected %#v to scan as %v, got %v, %v", p.value, p.expected, str, err)
+		}
+	}
+
+	if _, err := ScanDecimal(true); err == nil {
+		t.Errorf("Expected scanning a bool to fail")
+	}
+
+	if r, ok := Decimal("12.5").Rat(); !ok || r.FloatString(1) != "12.5" {
+		t.Errorf("Expected decimal to convert to big.Rat, got %v", r)
+	}
+	if _, ok := Decimal("1/3").Rat(); ok {
+		t.Errorf("Expected fractions to be rejected")
+	}
+}
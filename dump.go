@@ -0,0 +1,243 @@
+package sqlite
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var virtualTableRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+VIRTUAL\s+TABLE\s+.+?\s+USING\s+(\w+)`)
+
+// shadowTableSuffixes suffixes of the shadow tables created by virtual table modules, named <table>_<suffix>
+var shadowTableSuffixes = map[string][]string{
+	"fts3":      {"content", "segments", "segdir", "docsize", "stat"},
+	"fts4":      {"content", "segments", "segdir", "docsize", "stat"},
+	"fts5":      {"data", "idx", "content", "docsize", "config"},
+	"rtree":     {"node", "rowid", "parent"},
+	"rtree_i32": {"node", "rowid", "parent"},
+	"geopoly":   {"node", "rowid", "parent"},
+	"vec0":      vectorShadowTableSuffixes(),
+}
+
+// DumpOptions options of Dump
+type DumpOptions struct {
+	// Schema database schema to dump, defaults to main
+	Schema string
+	// Tables only dumps these tables with their indexes and triggers, and the views of these names
+	Tables []string
+	// SchemaOnly skips the rows of tables
+	SchemaOnly bool
+	// DataOnly skips the schema, e.g. to load the rows into a migrated database
+	DataOnly bool
+}
+
+type dumpObject struct {
+	Type    string
+	Name    string
+	TblName string
+	SQL     string
+}
+
+// Dump writes the schema and rows of db to w as SQL like the .dump command of the sqlite3 shell,
+// in a transaction with foreign keys disabled. Tables are created parent tables first, each followed
+// by its rows, then indexes, triggers and views, so triggers don't fire while the rows are loaded.
+// Virtual tables are written as CREATE VIRTUAL TABLE with the rows of their shadow tables
+func Dump(db *gorm.DB, w io.Writer, opts ...DumpOptions) error {
+	var options DumpOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	selected := map[string]bool{}
+	for _, table := range options.Tables {
+		selected[strings.ToLower(table)] = true
+	}
+
+	// reading in a transaction dumps a consistent snapshot
+	return db.Transaction(func(tx *gorm.DB) error {
+		var objects []dumpObject
+		if err := tx.Raw("SELECT type, name, tbl_name, sql FROM " + masterTable(options.Schema) + " WHERE sql IS NOT NULL ORDER BY rowid").Scan(&objects).Error; err != nil {
+			return err
+		}
+
+		shadowTables := map[string]string{}
+		for _, object := range objects {
+			if matches := virtualTableRegexp.FindStringSubmatch(object.SQL); object.Type == "table" && matches != nil {
+				for _, suffix := range shadowTableSuffixes[strings.ToLower(matches[1])] {
+					shadowTables[strings.ToLower(object.Name+"_"+suffix)] = object.Name
+				}
+			}
+		}
+
+		isSelected := func(name string) bool {
+			if vtable, ok := shadowTables[strings.ToLower(name)]; ok {
+				name = vtable
+			}
+			return len(selected) == 0 || selected[strings.ToLower(name)]
+		}
+
+		var tables []string
+		for _, object := range objects {
+			if object.Type == "table" && !strings.HasPrefix(strings.ToLower(object.Name), "sqlite_") && isSelected(object.Name) {
+				tables = append(tables, object.Name)
+			}
+		}
+		tables, err := sortTablesByForeignKeys(tx, options.Schema, tables)
+		if err != nil {
+			return err
+		}
+
+		writer := bufio.NewWriter(w)
+		writer.WriteString("PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n")
+
+		for _, table := range tables {
+			_, shadow := shadowTables[strings.ToLower(table)]
+			if !options.DataOnly && !shadow {
+				for _, object := range objects {
+					if object.Type == "table" && object.Name == table {
+						writer.WriteString(object.SQL + ";\n")
+					}
+				}
+			}
+
+			if !options.SchemaOnly && !virtualTableRegexp.MatchString(tableSQL(objects, table)) {
+				// creating the virtual table filled its shadow tables
+				if shadow {
+					writer.WriteString("DELETE FROM " + quoteIdentifier(table) + ";\n")
+				}
+				if err := dumpRows(tx, writer, options.Schema, table); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !options.SchemaOnly && len(selected) == 0 && tableSQL(objects, "sqlite_sequence") != "" {
+			writer.WriteString("DELETE FROM sqlite_sequence;\n")
+			if err := dumpRows(tx, writer, options.Schema, "sqlite_sequence"); err != nil {
+				return err
+			}
+		}
+
+		if !options.DataOnly {
+			for _, object := range objects {
+				if _, shadow := shadowTables[strings.ToLower(object.TblName)]; object.Type != "table" && !shadow && isSelected(object.TblName) {
+					writer.WriteString(object.SQL + ";\n")
+				}
+			}
+		}
+
+		writer.WriteString("COMMIT;\n")
+		return writer.Flush()
+	})
+}
+
+// tableSQL returns the DDL of table in objects
+func tableSQL(objects []dumpObject, table string) string {
+	for _, object := range objects {
+		if object.Type == "table" && strings.EqualFold(object.Name, table) {
+			return object.SQL
+		}
+	}
+	return ""
+}
+
+// sortTablesByForeignKeys orders tables so referenced tables come before the tables referencing them,
+// tables of a reference cycle keep their order
+func sortTablesByForeignKeys(tx *gorm.DB, schema string, tables []string) ([]string, error) {
+	names := map[string]string{}
+	for _, table := range tables {
+		names[strings.ToLower(table)] = table
+	}
+
+	var (
+		sorted  = make([]string, 0, len(tables))
+		visited = map[string]bool{}
+		visit   func(table string) error
+	)
+	visit = func(table string) error {
+		if visited[table] {
+			return nil
+		}
+		visited[table] = true
+
+		var parents []string
+		if err := tx.Raw(`SELECT "table" FROM pragma_foreign_key_list(?, ?)`, table, schemaName(schema)).Scan(&parents).Error; err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			if name, ok := names[strings.ToLower(parent)]; ok {
+				if err := visit(name); err != nil {
+					return err
+				}
+			}
+		}
+		sorted = append(sorted, table)
+		return nil
+	}
+
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// dumpRows writes the rows of table as INSERT statements, values are written by quote(), which reads back exactly
+func dumpRows(tx *gorm.DB, writer *bufio.Writer, schema, table string) error {
+	var columns []struct {
+		Name   string
+		Hidden int
+	}
+	if err := tx.Raw("SELECT name, hidden FROM pragma_table_xinfo(?, ?) ORDER BY cid", table, schemaName(schema)).Scan(&columns).Error; err != nil {
+		return err
+	}
+
+	var (
+		names, values []string
+		generated     bool
+	)
+	for _, column := range columns {
+		// hidden columns of virtual tables and generated columns can't be inserted
+		if column.Hidden != 0 {
+			generated = true
+			continue
+		}
+		names = append(names, quoteIdentifier(column.Name))
+		values = append(values, "quote("+quoteIdentifier(column.Name)+")")
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	insert := "INSERT INTO " + quoteIdentifier(table)
+	if generated {
+		insert += "(" + strings.Join(names, ",") + ")"
+	}
+
+	rows, err := tx.Raw("SELECT " + strings.Join(values, "||','||") + " FROM " + qualifyTable(schema, table)).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		writer.WriteString(insert + " VALUES(" + row + ");\n")
+	}
+	return rows.Err()
+}
+
+// qualifyTable returns the quoted name of table in schema
+func qualifyTable(schema, table string) string {
+	if schema == "" {
+		return quoteIdentifier(table)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
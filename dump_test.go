@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDump(t *testing.T) {
+	db := openTestDB(t, "dump")
+	for _, sql := range []string{
+		// pets is created before the users it references
+		"CREATE TABLE `pets` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer REFERENCES `users`(`id`),`name` text)",
+		"CREATE TABLE `users` (`id` integer,`name` text,`avatar` blob,`score` real,`upper_name` text GENERATED ALWAYS AS (upper(`name`)),PRIMARY KEY (`id`))",
+		"CREATE INDEX `idx_pets_name` ON `pets`(`name`)",
+		"CREATE VIEW `user_names` AS SELECT `name` FROM `users`",
+		"CREATE TRIGGER `pets_ai` AFTER INSERT ON `pets` FOR EACH ROW BEGIN UPDATE `users` SET `score` = `score` + 1 WHERE `id` = NEW.`user_id`; END",
+		"CREATE VIRTUAL TABLE `boxes` USING rtree(`id`, `min_x`, `max_x`)",
+		"INSERT INTO `users` (`id`, `name`, `avatar`, `score`) VALUES (1, 'it''s me', x'00ff', 0.1), (2, NULL, NULL, 1e300)",
+		"INSERT INTO `pets` (`user_id`, `name`) VALUES (1, 'cat'), (1, 'dog')",
+		"INSERT INTO `boxes` VALUES (1, 0, 10), (2, 5, 15)",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("failed to execute %v: %v", sql, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Dump(db, &buf); err != nil {
+		t.Fatalf("failed to dump: %v", err)
+	}
+	dump := buf.String()
+
+	assert.True(t, strings.HasPrefix(dump, "PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n"))
+	assert.True(t, strings.HasSuffix(dump, "COMMIT;\n"))
+	assert.Less(t, strings.Index(dump, "CREATE TABLE `users`"), strings.Index(dump, "CREATE TABLE `pets`"))
+	assert.Less(t, strings.Index(dump, `INSERT INTO "pets"`), strings.Index(dump, "CREATE TRIGGER `pets_ai`"))
+	assert.Contains(t, dump, `INSERT INTO "users"("id","name","avatar","score") VALUES(1,'it''s me',X'00FF',2.1);`)
+	assert.Contains(t, dump, "DELETE FROM sqlite_sequence;\n")
+	assert.NotContains(t, dump, "CREATE TABLE \"boxes_node\"")
+
+	restored, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := restored.Exec(dump).Error; err != nil {
+		t.Fatalf("failed to load dump: %v", err)
+	}
+
+	for _, query := range []string{
+		"SELECT type || name || sql FROM sqlite_master WHERE name NOT LIKE 'boxes%' ORDER BY name",
+		"SELECT id || ',' || quote(name) || ',' || quote(avatar) || ',' || score || ',' || quote(upper_name) FROM users ORDER BY id",
+		"SELECT id || ',' || user_id || ',' || name FROM pets ORDER BY id",
+		"SELECT name || seq FROM sqlite_sequence",
+		"SELECT id FROM boxes WHERE max_x >= 12",
+	} {
+		var expected, actual []string
+		db.Raw(query).Scan(&expected)
+		restored.Raw(query).Scan(&actual)
+		assert.NotEmpty(t, expected, query)
+		assert.Equal(t, expected, actual, query)
+	}
+
+	// the restored trigger still works, the pets inserted above already counted twice
+	restored.Exec("INSERT INTO `pets` (`user_id`, `name`) VALUES (1, 'fish')")
+	var score float64
+	restored.Raw("SELECT score FROM users WHERE id = 1").Scan(&score)
+	assert.Equal(t, 3.1, score)
+
+	buf.Reset()
+	if err := Dump(db, &buf, DumpOptions{Tables: []string{"pets"}, SchemaOnly: true}); err != nil {
+		t.Fatalf("failed to dump: %v", err)
+	}
+	assert.Equal(t, "PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n"+
+		"CREATE TABLE `pets` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer REFERENCES `users`(`id`),`name` text);\n"+
+		"CREATE INDEX `idx_pets_name` ON `pets`(`name`);\n"+
+		"CREATE TRIGGER `pets_ai` AFTER INSERT ON `pets` FOR EACH ROW BEGIN UPDATE `users` SET `score` = `score` + 1 WHERE `id` = NEW.`user_id`; END;\n"+
+		"COMMIT;\n", buf.String())
+
+	buf.Reset()
+	if err := Dump(db, &buf, DumpOptions{Tables: []string{"users"}, DataOnly: true}); err != nil {
+		t.Fatalf("failed to dump: %v", err)
+	}
+	assert.NotContains(t, buf.String(), "CREATE")
+	assert.Equal(t, 2, strings.Count(buf.String(), "INSERT INTO"))
+}
@@ -3,5 +3,29 @@ package sqlite
 import "errors"
 
 var (
-	ErrConstraintsNotImplemented = errors.New("constraints not implemented on sqlite, consider using DisableForeignKeyConstraintWhenMigrating, more details https://github.com/go-gorm/gorm/wiki/GORM-V2-Release-Note-Draft#all-new-migrator")
+	// ErrColumnHasDependencies returned by DropColumn with Config.DisableDropColumnRebuild when other schema objects refer to the column
+	ErrColumnHasDependencies = errors.New("sqlite: column is referenced by other schema objects")
+	// ErrUnsupportedAutoIncrement returned by CreateTable for autoIncrement fields other than a single integer primary key
+	ErrUnsupportedAutoIncrement = errors.New("sqlite: only a single integer primary key can be auto incremented")
+	// ErrSchemaChanged returned by migrations rebuilding a table when another connection changed the schema meanwhile
+	ErrSchemaChanged = errors.New("sqlite: schema changed by another connection")
+	// ErrForeignKeyViolation returned by migrations rebuilding a table when rows violate enforced foreign keys afterwards
+	ErrForeignKeyViolation = errors.New("sqlite: foreign key violation")
+	// ErrExtensionNotAllowed returned by Initialize for extensions missing from Config.ExtensionAllowlist
+	ErrExtensionNotAllowed = errors.New("sqlite: extension is not allowed")
+	// ErrExtensionLoadingUnsupported returned when Config.Extensions is set but the driver can't load extensions
+	ErrExtensionLoadingUnsupported = errors.New("sqlite: the driver is built without extension loading")
+	// ErrReadOnly returned for write statements on databases opened with Config.ReadOnly or Config.Immutable
+	ErrReadOnly = errors.New("sqlite: write statement rejected, the database is opened read only")
+	// ErrPoolClosed returned by Pool.Acquire once the pool is closed
+	ErrPoolClosed = errors.New("sqlite: pool is closed")
+	// ErrInvalidTenant returned by Pool.Acquire for tenant names that aren't plain file names
+	ErrInvalidTenant = errors.New("sqlite: invalid tenant name")
+	// ErrUnknownVersion returned by migrations depending on the SQLite version when the dialector wasn't initialized
+	ErrUnknownVersion = errors.New("sqlite: SQLite version is unknown, the dialector wasn't initialized")
+	// ErrQueryTimeout returned for statements interrupted because they ran longer than Config.MaxQueryDuration
+	ErrQueryTimeout = errors.New("sqlite: statement interrupted")
+	// ErrProgressHandlerUnsupported returned when Config.MaxSteps is set but the driver can't register progress handlers
+	ErrProgressHandlerUnsupported = errors.New("sqlite: the driver can't register progress handlers")
+	ErrConstraintsNotImplemented  = errors.New("constraints not implemented on sqlite, consider using DisableForeignKeyConstraintWhenMigrating, more details https://github.com/go-gorm/gorm/wiki/GORM-V2-Release-Note-Draft#all-new-migrator")
 )
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ExtensionLoader is implemented by driver connections that load extensions, like mattn/go-sqlite3's
+type ExtensionLoader interface {
+	LoadExtension(lib string, entry string) error
+}
+
+// defaultExtensionEntryPoint entry point SQLite tries before the one derived from the file name
+const defaultExtensionEntryPoint = "sqlite3_extension_init"
+
+// checkExtensions reports the first extension of the config missing from ExtensionAllowlist
+func (dialector Dialector) checkExtensions() error {
+	if len(dialector.ExtensionAllowlist) == 0 {
+		return nil
+	}
+
+	for _, extension := range dialector.Extensions {
+		allowed := false
+		for _, name := range dialector.ExtensionAllowlist {
+			if extension == name || extensionName(extension) == name {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %v", ErrExtensionNotAllowed, extension)
+		}
+	}
+	return nil
+}
+
+// loadExtensions returns a connect hook loading extensions on each new connection,
+// loading stays disabled for SQL, so load_extension() can't load other libraries
+func loadExtensions(extensions []string) connectHook {
+	return func(ctx context.Context, conn driver.Conn) error {
+		loader, ok := conn.(ExtensionLoader)
+		if !ok {
+			return fmt.Errorf("%w: driver connection %T can't load extensions", ErrExtensionLoadingUnsupported, conn)
+		}
+
+		for _, extension := range extensions {
+			err := loader.LoadExtension(extension, defaultExtensionEntryPoint)
+			if err != nil && !isExtensionLoadingDisabled(err) {
+				// the error of the default entry point is dropped, a missing file fails the same way again
+				err = loader.LoadExtension(extension, extensionEntryPoint(extension))
+			}
+			if err != nil {
+				if isExtensionLoadingDisabled(err) {
+					return fmt.Errorf("%w: %v", ErrExtensionLoadingUnsupported, err)
+				}
+				return fmt.Errorf("sqlite: failed to load extension %v: %w", extension, err)
+			}
+		}
+		return nil
+	}
+}
+
+// isExtensionLoadingDisabled reports whether err comes from a driver built without extension loading,
+// e.g. mattn/go-sqlite3 with the sqlite_omit_load_extension tag
+func isExtensionLoadingDisabled(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "extensions have been disabled")
+}
+
+// extensionName returns the file name of extension without directories, the lib prefix and suffixes,
+// e.g. spellfix of /usr/lib/libspellfix.so
+func extensionName(extension string) string {
+	// SQLite splits on both separators on every platform
+	name := strings.TrimPrefix(extension[strings.LastIndexAny(extension, `/\`)+1:], "lib")
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// extensionEntryPoint returns the entry point SQLite derives from the file name of extension,
+// sqlite3_ followed by the lower cased letters of its name and _init, https://www.sqlite.org/c3ref/load_extension.html
+func extensionEntryPoint(extension string) string {
+	var builder strings.Builder
+	builder.WriteString("sqlite3_")
+	for _, r := range extensionName(extension) {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			builder.WriteRune(unicode.ToLower(r))
+		}
+	}
+	builder.WriteString("_init")
+	return builder.String()
+}
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestExtensions(t *testing.T) {
+	missing := filepath.Join(tempDir(t), "libspellfix.so")
+
+	_, err := gorm.Open(OpenInMemory("", Config{Extensions: []string{missing}, ExtensionAllowlist: []string{"vec0"}}), &gorm.Config{})
+	assert.True(t, errors.Is(err, ErrExtensionNotAllowed), "got %v", err)
+
+	_, err = gorm.Open(OpenInMemory("", Config{Extensions: []string{missing}, ExtensionAllowlist: []string{"spellfix"}}), &gorm.Config{})
+	if assert.Error(t, err) {
+		assert.False(t, errors.Is(err, ErrExtensionNotAllowed))
+		assert.Contains(t, err.Error(), "failed to load extension "+missing)
+	}
+
+	// extension loading stays disabled for SQL
+	db, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	assert.Error(t, db.Exec("SELECT load_extension(?)", missing).Error)
+}
+
+func TestExtensionEntryPoint(t *testing.T) {
+	assert.Equal(t, "spellfix", extensionName("/usr/lib/libspellfix.so"))
+	assert.Equal(t, "sqlite3_spellfix_init", extensionEntryPoint("/usr/lib/libspellfix.so"))
+	assert.Equal(t, "sqlite3_vec_init", extensionEntryPoint("vec0.dylib"))
+	assert.Equal(t, "sqlite3_crypto_init", extensionEntryPoint(`C:\ext\crypto.dll`))
+}
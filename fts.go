@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// FTSConfig describes a full-text search table backed by fts5, https://www.sqlite.org/fts5.html
+type FTSConfig struct {
+	// Columns indexed columns, defaults to the model's string fields
+	Columns []string
+	// Content external content table, the FTS table only stores the index when set
+	Content string
+	// ContentRowID integer primary key of the Content table, defaults to rowid
+	ContentRowID string
+	// Tokenize tokenizer definition, e.g. "porter unicode61"
+	Tokenize string
+	// Prefix prefix index lengths, e.g. "2 3"
+	Prefix string
+	// Triggers creates triggers keeping the index in sync with the Content table
+	Triggers bool
+}
+
+// FTSTabler is implemented by models that are stored as fts5 virtual tables, AutoMigrate creates them once
+// and never alters them
+type FTSTabler interface {
+	FTSTable() FTSConfig
+}
+
+// Match returns a full-text search condition, column may be the FTS table name to search all columns,
+// e.g. db.Where(Match("documents", "sqlite OR gorm")).Order("rank").Find(&docs)
+func Match(column string, query string) clause.Expression {
+	return clause.Expr{SQL: "? MATCH ?", Vars: []interface{}{clause.Column{Name: column}, query}}
+}
+
+func ftsTableSQLs(stmt *gorm.Statement, config FTSConfig) (sqls []string) {
+	columns := config.Columns
+	if len(columns) == 0 {
+		for _, dbName := range stmt.Schema.DBNames {
+			if field := stmt.Schema.FieldsByDBName[dbName]; field.DataType == schema.String && !field.PrimaryKey && !field.IgnoreMigration {
+				columns = append(columns, dbName)
+			}
+		}
+	}
+
+	definitions := make([]string, 0, len(columns)+4)
+	for _, column := range columns {
+		definitions = append(definitions, stmt.Quote(column))
+	}
+	if config.Content != "" {
+		definitions = append(definitions, "content="+quoteString(config.Content))
+		if config.ContentRowID != "" {
+			definitions = append(definitions, "content_rowid="+quoteString(config.ContentRowID))
+		}
+	}
+	if config.Tokenize != "" {
+		definitions = append(definitions, "tokenize="+quoteString(config.Tokenize))
+	}
+	if config.Prefix != "" {
+		definitions = append(definitions, "prefix="+quoteString(config.Prefix))
+	}
+
+	// the triggers are created in the database of the table, their bodies can't qualify table names
+	schema, name := splitQualifiedTable(statementTable(stmt))
+	table := quoteIdentifier(name)
+	sqls = append(sqls, fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s)", qualifyName(schema, name), strings.Join(definitions, ",")))
+
+	if config.Content != "" && config.Triggers {
+		var (
+			rowID                = "rowid"
+			content              = stmt.Quote(config.Content)
+			names                = strings.Join(definitions[:len(columns)], ",")
+			newValues, oldValues []string
+		)
+		if config.ContentRowID != "" {
+			rowID = stmt.Quote(config.ContentRowID)
+		}
+		for _, column := range definitions[:len(columns)] {
+			newValues = append(newValues, "new."+column)
+			oldValues = append(oldValues, "old."+column)
+		}
+
+		insertSQL := fmt.Sprintf("INSERT INTO %s(rowid,%s) VALUES (new.%s,%s);", table, names, rowID, strings.Join(newValues, ","))
+		deleteSQL := fmt.Sprintf("INSERT INTO %s(%s,rowid,%s) VALUES ('delete',old.%s,%s);", table, table, names, rowID, strings.Join(oldValues, ","))
+		sqls = append(sqls,
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s END", qualifyName(schema, name+"_ai"), content, insertSQL),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s BEGIN %s END", qualifyName(schema, name+"_ad"), content, deleteSQL),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END", qualifyName(schema, name+"_au"), content, deleteSQL, insertSQL),
+		)
+	}
+	return
+}
+
+// quoteString quotes str as an SQL string literal
+func quoteString(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type ftsArticle struct {
+	ID    uint
+	Title string
+	Body  string
+}
+
+type ftsArticleIndex struct {
+	RowID uint `gorm:"column:rowid"`
+	Title string
+	Body  string
+}
+
+func (ftsArticleIndex) FTSTable() FTSConfig {
+	return FTSConfig{Columns: []string{"title", "body"}, Content: "fts_articles", ContentRowID: "id", Tokenize: "porter unicode61", Triggers: true}
+}
+
+func TestFTSTable(t *testing.T) {
+	db := openTestDB(t, "fts")
+
+	if err := db.AutoMigrate(&ftsArticle{}, &ftsArticleIndex{}); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			t.Skip("fts5 is not enabled, build with -tags sqlite_fts5")
+		}
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	// existing virtual tables are left untouched
+	if err := db.AutoMigrate(&ftsArticle{}, &ftsArticleIndex{}); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+
+	articles := []ftsArticle{{Title: "SQLite full-text search", Body: "fts5 is fast"}, {Title: "GORM", Body: "the fantastic ORM library for golang"}}
+	db.Create(&articles)
+	db.Model(&articles[1]).Update("body", "running queries with sqlite")
+
+	var results []ftsArticleIndex
+	if err := db.Where(Match("fts_article_indices", "sqlite")).Order("rank").Find(&results).Error; err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	assert.Len(t, results, 2)
+
+	db.Delete(&articles[0])
+	results = nil
+	db.Where(Match("body", "fast")).Find(&results)
+	assert.Len(t, results, 0)
+}
+
+type ftsTenantNote struct {
+	RowID uint `gorm:"column:rowid"`
+	Body  string
+}
+
+func (ftsTenantNote) TableName() string {
+	return "tenant.notes_fts"
+}
+
+func (ftsTenantNote) FTSTable() FTSConfig {
+	return FTSConfig{Content: "notes", Triggers: true}
+}
+
+func TestFTSTableSQLsAttached(t *testing.T) {
+	db := openTestDB(t, "fts_attached")
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&ftsTenantNote{}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	sqls := ftsTableSQLs(stmt, ftsTenantNote{}.FTSTable())
+	if assert.Len(t, sqls, 4) {
+		assert.Equal(t, `CREATE VIRTUAL TABLE "tenant"."notes_fts" USING fts5("body",content='notes')`, sqls[0])
+		assert.Equal(t, `CREATE TRIGGER "tenant"."notes_fts_ai" AFTER INSERT ON "notes" BEGIN INSERT INTO "notes_fts"(rowid,"body") VALUES (new.rowid,new."body"); END`, sqls[1])
+		assert.True(t, strings.HasPrefix(sqls[2], `CREATE TRIGGER "tenant"."notes_fts_ad" AFTER DELETE ON "notes"`), sqls[2])
+		assert.True(t, strings.HasPrefix(sqls[3], `CREATE TRIGGER "tenant"."notes_fts_au" AFTER UPDATE ON "notes"`), sqls[3])
+	}
+}
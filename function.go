@@ -0,0 +1,290 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Function an application-defined SQL function registered on every pooled connection, https://www.sqlite.org/appfunc.html
+type Function struct {
+	Name string
+	// Impl a Go function implementing a scalar function, e.g. func(s string) string, or for an aggregate function
+	// a constructor of aggregators with Step and Done methods, e.g. func() *product
+	Impl interface{}
+	// Aggregate Impl constructs aggregators
+	Aggregate bool
+	// Pure the function always returns the same result for the same arguments, so SQLite can use it in indexes
+	// and optimize calls away
+	Pure bool
+}
+
+// FunctionRegisterer is implemented by driver connections that register application-defined functions, like
+// mattn/go-sqlite3's, a driver wrapping its connections implements it to support Config.Functions.
+// Drivers registering functions process-wide, like modernc.org/sqlite, are supported with a FunctionRegistry
+type FunctionRegisterer interface {
+	RegisterFunc(name string, impl interface{}, pure bool) error
+	RegisterAggregator(name string, impl interface{}, pure bool) error
+}
+
+// registerFunctions returns a connect hook registering functions on each new connection
+func registerFunctions(functions []Function) connectHook {
+	return func(ctx context.Context, conn driver.Conn) error {
+		registerer, ok := conn.(FunctionRegisterer)
+		if !ok {
+			return fmt.Errorf("sqlite: driver connection %T can't register functions", conn)
+		}
+		return addFunctions(registerer, functions)
+	}
+}
+
+// addFunctions registers functions with registerer
+func addFunctions(registerer FunctionRegisterer, functions []Function) error {
+	for _, function := range functions {
+		if function.Name == "" || function.Impl == nil {
+			return errors.New("sqlite: function requires a name and an implementation")
+		}
+
+		var err error
+		if function.Aggregate {
+			err = registerer.RegisterAggregator(function.Name, function.Impl, function.Pure)
+		} else {
+			err = registerer.RegisterFunc(function.Name, function.Impl, function.Pure)
+		}
+		if err != nil {
+			return fmt.Errorf("sqlite: failed to register function %v: %w", function.Name, err)
+		}
+	}
+	return nil
+}
+
+// ScalarFunc a scalar function called with the values of its arguments
+type ScalarFunc func(args []driver.Value) (driver.Value, error)
+
+// Aggregate an aggregate function, Step is called with the values of the arguments of every row, Done returns the result
+type Aggregate interface {
+	Step(args []driver.Value) error
+	Done() (driver.Value, error)
+}
+
+// FunctionRegistry registers Config.Functions for drivers registering functions process-wide instead of on a
+// connection, like modernc.org/sqlite, whose registered functions are created on every connection opened afterwards.
+// Set Config.FunctionRegistry, the dialector registers the functions before it opens connections, a function
+// registered by an earlier dialector isn't registered again. The Go functions of Config.Functions are adapted to
+// ScalarFunc and Aggregate, converting the values of their arguments and results, e.g. for modernc.org/sqlite
+//
+//	RegisterScalar: func(name string, nArgs int32, pure bool, fn sqlite.ScalarFunc) error {
+//		impl := func(ctx *modernc.FunctionContext, args []driver.Value) (driver.Value, error) { return fn(args) }
+//		if pure {
+//			return modernc.RegisterDeterministicScalarFunction(name, nArgs, impl)
+//		}
+//		return modernc.RegisterScalarFunction(name, nArgs, impl)
+//	},
+type FunctionRegistry struct {
+	// RegisterScalar registers the scalar function name taking nArgs arguments, -1 for a variable number
+	RegisterScalar func(name string, nArgs int32, pure bool, fn ScalarFunc) error
+	// RegisterAggregate registers the aggregate function name taking nArgs arguments, newAggregate is called for
+	// every group, e.g. by MakeAggregate of modernc.org/sqlite's FunctionImpl
+	RegisterAggregate func(name string, nArgs int32, pure bool, newAggregate func() Aggregate) error
+
+	mu         sync.Mutex
+	registered map[string]bool
+}
+
+// register registers name with fc unless it is registered already
+func (r *FunctionRegistry) register(name string, fc func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.registered[name] {
+		return nil
+	}
+	if err := fc(); err != nil {
+		return err
+	}
+	if r.registered == nil {
+		r.registered = map[string]bool{}
+	}
+	r.registered[name] = true
+	return nil
+}
+
+func (r *FunctionRegistry) RegisterFunc(name string, impl interface{}, pure bool) error {
+	fn := reflect.ValueOf(impl)
+	if fn.Kind() != reflect.Func {
+		return fmt.Errorf("sqlite: function %T isn't a func", impl)
+	}
+	typ := fn.Type()
+	if typ.NumOut() == 0 || typ.NumOut() > 2 || (typ.NumOut() == 2 && typ.Out(1) != errorType) {
+		return errors.New("sqlite: function must return a value and optionally an error")
+	}
+	if r.RegisterScalar == nil {
+		return errors.New("sqlite: FunctionRegistry.RegisterScalar isn't set")
+	}
+
+	return r.register(name, func() error {
+		return r.RegisterScalar(name, numArgs(typ), pure, func(args []driver.Value) (driver.Value, error) {
+			out, err := callFunction(fn, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(out) == 2 && !out[1].IsNil() {
+				return nil, out[1].Interface().(error)
+			}
+			return functionResult(out[0])
+		})
+	})
+}
+
+func (r *FunctionRegistry) RegisterAggregator(name string, impl interface{}, pure bool) error {
+	constructor := reflect.ValueOf(impl)
+	if constructor.Kind() != reflect.Func || constructor.Type().NumIn() != 0 || constructor.Type().NumOut() != 1 {
+		return fmt.Errorf("sqlite: aggregator %T isn't a constructor of aggregators", impl)
+	}
+	aggregatorType := constructor.Type().Out(0)
+	step, ok := aggregatorType.MethodByName("Step")
+	if !ok {
+		return fmt.Errorf("sqlite: aggregator %v has no Step method", aggregatorType)
+	}
+	done, ok := aggregatorType.MethodByName("Done")
+	if !ok || done.Type.NumIn() != 1 || done.Type.NumOut() == 0 || done.Type.NumOut() > 2 {
+		return fmt.Errorf("sqlite: aggregator %v has no Done method returning a value", aggregatorType)
+	}
+	if r.RegisterAggregate == nil {
+		return errors.New("sqlite: FunctionRegistry.RegisterAggregate isn't set")
+	}
+
+	// the method types of a type include the receiver
+	nArgs := int32(step.Type.NumIn() - 1)
+	if step.Type.IsVariadic() {
+		nArgs = -1
+	}
+	return r.register(name, func() error {
+		return r.RegisterAggregate(name, nArgs, pure, func() Aggregate {
+			aggregator := constructor.Call(nil)[0]
+			return &reflectAggregate{step: aggregator.MethodByName("Step"), done: aggregator.MethodByName("Done")}
+		})
+	})
+}
+
+// reflectAggregate calls the Step and Done methods of an aggregator of Config.Functions
+type reflectAggregate struct {
+	step, done reflect.Value
+}
+
+func (a *reflectAggregate) Step(args []driver.Value) error {
+	out, err := callFunction(a.step, args)
+	if err != nil {
+		return err
+	}
+	if len(out) > 0 && out[len(out)-1].Type() == errorType && !out[len(out)-1].IsNil() {
+		return out[len(out)-1].Interface().(error)
+	}
+	return nil
+}
+
+func (a *reflectAggregate) Done() (driver.Value, error) {
+	out := a.done.Call(nil)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return functionResult(out[0])
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// numArgs returns the number of arguments of function type typ, -1 if it is variadic
+func numArgs(typ reflect.Type) int32 {
+	if typ.IsVariadic() {
+		return -1
+	}
+	return int32(typ.NumIn())
+}
+
+// callFunction calls fn with args converted to its parameter types
+func callFunction(fn reflect.Value, args []driver.Value) ([]reflect.Value, error) {
+	typ := fn.Type()
+	fixed := typ.NumIn()
+	if typ.IsVariadic() {
+		fixed--
+	}
+	if len(args) < fixed || (!typ.IsVariadic() && len(args) > fixed) {
+		return nil, fmt.Errorf("sqlite: function takes %d arguments, got %d", fixed, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if i < fixed {
+			argType = typ.In(i)
+		} else {
+			argType = typ.In(fixed).Elem()
+		}
+
+		value, err := functionArg(arg, argType)
+		if err != nil {
+			return nil, err
+		}
+		in[i] = value
+	}
+	return fn.Call(in), nil
+}
+
+// functionArg converts the SQLite value arg to typ, NULL to the zero value
+func functionArg(arg driver.Value, typ reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(typ), nil
+	}
+
+	value := reflect.ValueOf(arg)
+	switch kind := typ.Kind(); {
+	case kind == reflect.Interface:
+		if value.Type().AssignableTo(typ) {
+			result := reflect.New(typ).Elem()
+			result.Set(value)
+			return result, nil
+		}
+	case kind == reflect.Bool:
+		if i, ok := arg.(int64); ok {
+			return reflect.ValueOf(i != 0).Convert(typ), nil
+		}
+	case isNumberKind(kind) == isNumberKind(value.Kind()) && value.Type().ConvertibleTo(typ):
+		// numbers aren't converted to strings, which converts them to a rune
+		return value.Convert(typ), nil
+	}
+	return reflect.Value{}, fmt.Errorf("sqlite: can't convert argument %v to %v", arg, typ)
+}
+
+// functionResult converts the result of a function to an SQLite value, booleans to 0 and 1
+func functionResult(value reflect.Value) (driver.Value, error) {
+	switch kind := value.Kind(); {
+	case kind == reflect.Interface || kind == reflect.Ptr:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return functionResult(value.Elem())
+	case kind == reflect.Bool:
+		if value.Bool() {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		return value.Int(), nil
+	case kind >= reflect.Uint && kind <= reflect.Uint64:
+		return int64(value.Uint()), nil
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		return value.Float(), nil
+	case kind == reflect.String:
+		return value.String(), nil
+	case kind == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+		return value.Bytes(), nil
+	}
+	return nil, fmt.Errorf("sqlite: unsupported function result %v", value.Type())
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
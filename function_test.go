@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type productAggregator struct {
+	product int64
+}
+
+func (p *productAggregator) Step(value int64) {
+	p.product *= value
+}
+
+func (p *productAggregator) Done() int64 {
+	return p.product
+}
+
+func TestFunctions(t *testing.T) {
+	functions := []Function{
+		{Name: "reverse", Impl: func(s string) string {
+			runes := []rune(s)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes)
+		}, Pure: true},
+		{Name: "product", Impl: func() *productAggregator { return &productAggregator{product: 1} }, Aggregate: true, Pure: true},
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{Functions: functions}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(3)
+
+	db.Exec("CREATE TABLE numbers (value integer, name text)")
+	db.Exec("INSERT INTO numbers VALUES (2, 'two'), (3, 'three'), (4, 'four')")
+	// pure functions can be used in indexes
+	if err := db.Exec("CREATE INDEX idx_numbers_reversed ON numbers(reverse(name))").Error; err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	// every pooled connection has the functions
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := sqlDB.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get connection: %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		var (
+			reversed string
+			product  int64
+		)
+		assert.NoError(t, conn.QueryRowContext(context.Background(), "SELECT reverse(?)", "gorm").Scan(&reversed))
+		assert.Equal(t, "mrog", reversed)
+		assert.NoError(t, conn.QueryRowContext(context.Background(), "SELECT product(value) FROM numbers").Scan(&product))
+		assert.Equal(t, int64(24), product)
+	}
+
+	_, err = gorm.Open(OpenInMemory("", Config{Functions: []Function{{Name: "invalid", Impl: "not a function"}}}), &gorm.Config{})
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "invalid"), err.Error())
+	}
+}
+
+// recordingRegistry returns a FunctionRegistry recording the registered functions and their number of arguments,
+// like a driver registering functions process-wide
+func recordingRegistry() (*FunctionRegistry, map[string]int32, map[string]ScalarFunc, map[string]func() Aggregate) {
+	nArgs, scalars, aggregates := map[string]int32{}, map[string]ScalarFunc{}, map[string]func() Aggregate{}
+	return &FunctionRegistry{
+		RegisterScalar: func(name string, n int32, pure bool, fn ScalarFunc) error {
+			nArgs[name], scalars[name] = n, fn
+			return nil
+		},
+		RegisterAggregate: func(name string, n int32, pure bool, newAggregate func() Aggregate) error {
+			nArgs[name], aggregates[name] = n, newAggregate
+			return nil
+		},
+	}, nArgs, scalars, aggregates
+}
+
+func TestFunctionRegistry(t *testing.T) {
+	registry, nArgs, scalars, aggregates := recordingRegistry()
+	config := Config{Regexp: true, FunctionRegistry: registry, Functions: []Function{
+		{Name: "repeat", Impl: func(s string, times int, separators ...string) string {
+			return strings.Repeat(s+strings.Join(separators, ""), times)
+		}, Pure: true},
+		{Name: "product", Impl: func() *productAggregator { return &productAggregator{product: 1} }, Aggregate: true},
+	}}
+
+	db, err := gorm.Open(OpenInMemory("", config), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	assert.Equal(t, map[string]int32{"regexp": 2, "repeat": -1, "product": 1}, nArgs)
+
+	// the functions are registered with the registry instead of the connections
+	var repeated string
+	assert.Error(t, db.Raw("SELECT repeat('go', 2)").Row().Scan(&repeated))
+
+	result, err := scalars["repeat"]([]driver.Value{"go", int64(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, "gogo", result)
+	result, err = scalars["repeat"]([]driver.Value{"go", int64(2), "-", nil})
+	assert.NoError(t, err)
+	assert.Equal(t, "go-go-", result)
+	_, err = scalars["repeat"]([]driver.Value{"go"})
+	assert.Error(t, err, "too few arguments")
+	_, err = scalars["repeat"]([]driver.Value{"go", "twice"})
+	assert.Error(t, err, "text isn't converted to a number")
+
+	result, err = scalars["regexp"]([]driver.Value{"^go", "gorm"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	_, err = scalars["regexp"]([]driver.Value{"(", "gorm"})
+	assert.Error(t, err, "the error of the function is returned")
+
+	// every group gets a new aggregate
+	for i := 0; i < 2; i++ {
+		aggregate := aggregates["product"]()
+		for _, value := range []int64{2, 3, 4} {
+			assert.NoError(t, aggregate.Step([]driver.Value{value}))
+		}
+		result, err = aggregate.Done()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(24), result)
+	}
+
+	// functions are registered once per registry
+	delete(nArgs, "repeat")
+	if _, err := gorm.Open(OpenInMemory("", config), &gorm.Config{}); err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	assert.NotContains(t, nArgs, "repeat")
+
+	_, err = gorm.Open(OpenInMemory("", Config{FunctionRegistry: &FunctionRegistry{}, Functions: config.Functions}), &gorm.Config{})
+	assert.Error(t, err, "RegisterScalar isn't set")
+}
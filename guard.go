@@ -0,0 +1,231 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+)
+
+// internalQueryRegexp matches pragmas and queries of the schema, e.g. of the migrator, Config.MaxRows doesn't limit them
+var internalQueryRegexp = regexp.MustCompile(`(?i)^\s*PRAGMA\b|\bpragma_\w+|\bsqlite_(?:temp_)?(?:master|schema)\b`)
+
+// ProgressHandlerRegisterer is implemented by driver connections that register progress handlers, SQLite calls
+// callback every steps virtual machine instructions of a statement and interrupts it when it returns true, steps
+// less than 1 remove the handler. Connections of mattn/go-sqlite3 are supported when built with cgo, other drivers
+// implement it to support Config.MaxSteps
+type ProgressHandlerRegisterer interface {
+	RegisterProgressHandler(steps int, callback func() bool)
+}
+
+// QueryLimit limit of Config exceeded by a statement
+type QueryLimit string
+
+const (
+	// QueryLimitRows the statement returned more rows than Config.MaxRows
+	QueryLimitRows QueryLimit = "rows"
+	// QueryLimitSteps the statement ran more virtual machine instructions than Config.MaxSteps
+	QueryLimitSteps QueryLimit = "steps"
+)
+
+// QueryLimitError returned for statements aborted because they exceeded Config.MaxRows or Config.MaxSteps,
+// e.g. an accidental full table scan
+type QueryLimitError struct {
+	Limit QueryLimit
+	Max   int64
+	SQL   string
+}
+
+func (e *QueryLimitError) Error() string {
+	return fmt.Sprintf("sqlite: statement aborted, it exceeded the limit of %d %s: %s", e.Max, e.Limit, e.SQL)
+}
+
+// guardConn aborts statements of its connection exceeding maxRows or maxSteps
+type guardConn struct {
+	driver.Conn
+	maxRows, maxSteps int64
+	// progress registered the progress handler of maxSteps, aborted is set once it interrupted a statement
+	progress ProgressHandlerRegisterer
+	aborted  bool
+}
+
+// newGuardConn registers the progress handler of maxSteps on the driver connection raw, which conn wraps
+func newGuardConn(conn, raw driver.Conn, maxRows, maxSteps int64) (*guardConn, error) {
+	c := &guardConn{Conn: conn, maxRows: maxRows, maxSteps: maxSteps}
+	if maxSteps > 0 {
+		registerer, ok := raw.(ProgressHandlerRegisterer)
+		if !ok {
+			if registerer, ok = sqliteProgressHandler(raw); !ok {
+				return nil, fmt.Errorf("%w: driver connection %T", ErrProgressHandlerUnsupported, raw)
+			}
+		}
+
+		// SQLite counts the instructions of each statement, the handler is first called once a statement ran maxSteps
+		steps := math.MaxInt32
+		if maxSteps < math.MaxInt32 {
+			steps = int(maxSteps)
+		}
+		registerer.RegisterProgressHandler(steps, func() bool {
+			c.aborted = true
+			return true
+		})
+		c.progress = registerer
+	}
+	return c, nil
+}
+
+// unwrap returns the driver connection
+func (c *guardConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// begin resets the abort of the previous statement
+func (c *guardConn) begin() {
+	c.aborted = false
+}
+
+// err returns the error of query, a QueryLimitError if the progress handler aborted it
+func (c *guardConn) err(query string, err error) error {
+	if err != nil && err != io.EOF && c.aborted {
+		return &QueryLimitError{Limit: QueryLimitSteps, Max: c.maxSteps, SQL: query}
+	}
+	return err
+}
+
+// wrapRows limits the rows of query to maxRows, unless it is a pragma or a query of the schema, the progress handler
+// interrupts queries while their rows are read
+func (c *guardConn) wrapRows(rows driver.Rows, query string) driver.Rows {
+	limitRows := c.maxRows > 0 && !internalQueryRegexp.MatchString(query)
+	if limitRows || c.progress != nil {
+		return &guardRows{Rows: rows, conn: c, query: query, limitRows: limitRows}
+	}
+	return rows
+}
+
+func (c *guardConn) Close() error {
+	if c.progress != nil {
+		c.progress.RegisterProgressHandler(0, nil)
+	}
+	return c.Conn.Close()
+}
+
+func (c *guardConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *guardConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, errNotImplemented
+}
+
+func (c *guardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	c.begin()
+	result, err := execer.ExecContext(ctx, query, args)
+	return result, c.err(query, err)
+}
+
+func (c *guardConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	c.begin()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, c.err(query, err)
+	}
+	return c.wrapRows(rows, query), nil
+}
+
+func (c *guardConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *guardConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &guardStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+type guardStmt struct {
+	driver.Stmt
+	conn  *guardConn
+	query string
+}
+
+func (s *guardStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	s.conn.begin()
+	result, err := execer.ExecContext(ctx, args)
+	return result, s.conn.err(s.query, err)
+}
+
+func (s *guardStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	s.conn.begin()
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		return nil, s.conn.err(s.query, err)
+	}
+	return s.conn.wrapRows(rows, s.query), nil
+}
+
+// guardRows fails once its statement returned more than maxRows rows, SQLite runs the statement while its rows are read
+type guardRows struct {
+	driver.Rows
+	conn      *guardConn
+	query     string
+	limitRows bool
+	rows      int64
+}
+
+func (r *guardRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return r.conn.err(r.query, err)
+	}
+
+	r.rows++
+	if r.limitRows && r.rows > r.conn.maxRows {
+		return &QueryLimitError{Limit: QueryLimitRows, Max: r.conn.maxRows, SQL: r.query}
+	}
+	return nil
+}
+
+func (r *guardRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *guardRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
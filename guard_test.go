@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type GuardItem struct {
+	ID   uint
+	Name string
+}
+
+func TestMaxRows(t *testing.T) {
+	db, err := gorm.Open(OpenInMemory("", Config{MaxRows: 3}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&GuardItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	items := []GuardItem{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	assert.NoError(t, db.Create(&items).Error)
+	assert.NoError(t, db.Find(&items).Error)
+	assert.Len(t, items, 3)
+
+	assert.NoError(t, db.Create(&GuardItem{Name: "d"}).Error)
+	err = db.Find(&items).Error
+	var limitErr *QueryLimitError
+	if assert.True(t, errors.As(err, &limitErr), "unexpected error %v", err) {
+		assert.Equal(t, QueryLimitRows, limitErr.Limit)
+		assert.Equal(t, int64(3), limitErr.Max)
+		assert.Contains(t, limitErr.SQL, "guard_items")
+	}
+
+	var count int64
+	assert.NoError(t, db.Model(&GuardItem{}).Count(&count).Error)
+	assert.Equal(t, int64(4), count)
+
+	// pragmas of the migrator aren't limited
+	type GuardWideItem struct {
+		ID uint
+		A  string
+		B  string
+		C  string
+		D  string
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&GuardWideItem{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+	columnTypes, err := db.Migrator().ColumnTypes(&GuardWideItem{})
+	assert.NoError(t, err)
+	assert.Len(t, columnTypes, 5)
+}
+
+func TestMaxSteps(t *testing.T) {
+	name := "guard_steps"
+	setup, err := gorm.Open(OpenInMemory(name), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := setup.AutoMigrate(&GuardItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	items := make([]GuardItem, 2000)
+	for i := range items {
+		items[i].Name = "item"
+	}
+	assert.NoError(t, setup.CreateInBatches(&items, 500).Error)
+
+	db, err := gorm.Open(OpenInMemory(name, Config{MaxSteps: 5000}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	var item GuardItem
+	assert.NoError(t, db.First(&item, 1000).Error)
+
+	// a full table scan returning a single row
+	var count int64
+	err = db.Model(&GuardItem{}).Where("name = ?", "other").Count(&count).Error
+	var limitErr *QueryLimitError
+	if assert.True(t, errors.As(err, &limitErr), "unexpected error %v", err) {
+		assert.Equal(t, QueryLimitSteps, limitErr.Limit)
+		assert.Equal(t, int64(5000), limitErr.Max)
+	}
+	// the interrupt rolls back the transaction of gorm, which fails to roll it back again
+	err = db.Session(&gorm.Session{SkipDefaultTransaction: true}).Model(&GuardItem{}).Where("1 = 1").Update("name", "other").Error
+	assert.True(t, errors.As(err, &limitErr), "unexpected error %v", err)
+	err = db.Raw(slowQuery).Row().Scan(&count)
+	assert.True(t, errors.As(err, &limitErr), "unexpected error %v", err)
+
+	// steps are counted per statement, the connection keeps working after an abort
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, db.First(&GuardItem{}, i+1).Error)
+	}
+	assert.NoError(t, setup.Model(&GuardItem{}).Where("name = ?", "other").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
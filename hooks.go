@@ -0,0 +1,425 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// savepointRegexp matches SAVEPOINT, RELEASE and ROLLBACK TO statements, the savepoint name is the last group
+var savepointRegexp = regexp.MustCompile(`(?is)^\s*(SAVEPOINT|RELEASE(?:\s+SAVEPOINT)?|ROLLBACK(?:\s+TRANSACTION)?\s+TO(?:\s+SAVEPOINT)?)` +
+	`\s+("(?:[^"]|"")*"|` + "`[^`]*`" + `|\[[^\]]*\]|[^\s;]+)\s*;?\s*$`)
+
+// HookEventType type of a HookEvent
+type HookEventType string
+
+const (
+	// HookCommit a transaction was committed, including single statements outside of transactions
+	HookCommit HookEventType = "commit"
+	// HookRollback a transaction was rolled back
+	HookRollback HookEventType = "rollback"
+	// HookSchemaChange a committed transaction created, altered or dropped tables, indexes, views or triggers
+	HookSchemaChange HookEventType = "schema_change"
+	// HookCheckpoint Checkpoint ran a WAL checkpoint, automatic checkpoints of SQLite are not reported
+	HookCheckpoint HookEventType = "checkpoint"
+)
+
+// HookEvent an event of Hooks
+type HookEvent struct {
+	Type HookEventType
+	// Tables changed rows of a commit, or whose schema changed, tables of attached databases are qualified
+	// with their schema. Rows of WITHOUT ROWID tables and rows deleted by DELETE without WHERE aren't reported
+	Tables []string
+	// Checkpoint result of a checkpoint
+	Checkpoint CheckpointResult
+	// Changes row level changes of a commit, in the order they were made, see Config.CaptureChanges
+	Changes []Change
+}
+
+// HookRegisterer is implemented by driver connections that register commit, rollback and update hooks
+// and authorizers, like mattn/go-sqlite3's
+type HookRegisterer interface {
+	RegisterCommitHook(callback func() int)
+	RegisterRollbackHook(callback func())
+	RegisterUpdateHook(callback func(op int, db string, table string, rowid int64))
+	RegisterAuthorizer(callback func(op int, arg1, arg2, arg3 string) int)
+}
+
+// Hooks notifies subscribers of commits, rollbacks, schema changes and checkpoints of all connections of a database,
+// e.g. to build replication or change data capture without polling, see Config.Hooks
+//
+// Subscribers run on the goroutine of the statement or transaction once it ended, while it still holds its connection,
+// so they should hand work off instead of blocking
+type Hooks struct {
+	mu          sync.RWMutex
+	subscribers []*hookSubscriber
+}
+
+type hookSubscriber struct {
+	fc func(HookEvent)
+}
+
+// NewHooks returns hooks without subscribers
+func NewHooks() *Hooks {
+	return &Hooks{}
+}
+
+// Subscribe calls fc with every event until unsubscribe is called
+func (h *Hooks) Subscribe(fc func(HookEvent)) (unsubscribe func()) {
+	subscriber := &hookSubscriber{fc: fc}
+
+	h.mu.Lock()
+	h.subscribers = append(h.subscribers, subscriber)
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, s := range h.subscribers {
+			if s == subscriber {
+				h.subscribers = append(h.subscribers[:i:i], h.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (h *Hooks) emit(event HookEvent) {
+	h.mu.RLock()
+	subscribers := h.subscribers
+	h.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.fc(event)
+	}
+}
+
+// schemaChangeTables returns the table changed by the schema change op of the authorizer, ok is false for other ops
+// and changes of temporary objects
+func schemaChangeTables(op int, arg1, arg2 string) (table string, ok bool) {
+	switch op {
+	case sqlite3.SQLITE_CREATE_TABLE, sqlite3.SQLITE_DROP_TABLE, sqlite3.SQLITE_CREATE_VIEW, sqlite3.SQLITE_DROP_VIEW,
+		sqlite3.SQLITE_CREATE_VTABLE, sqlite3.SQLITE_DROP_VTABLE:
+		return arg1, true
+	case sqlite3.SQLITE_CREATE_INDEX, sqlite3.SQLITE_DROP_INDEX, sqlite3.SQLITE_CREATE_TRIGGER, sqlite3.SQLITE_DROP_TRIGGER,
+		sqlite3.SQLITE_ALTER_TABLE:
+		return arg2, true
+	}
+	return "", false
+}
+
+// hookConn collects the changes of its connection with the driver's hooks, and emits their events once
+// the statement or transaction causing them ended, the driver's hooks run before a commit completes
+type hookConn struct {
+	driver.Conn
+	hooks *Hooks
+	// captureChanges collects changes, see Config.CaptureChanges
+	captureChanges bool
+	// tables, schemaTables and changes of the current transaction
+	tables       []string
+	schemaTables []string
+	changes      []capturedChange
+	// unread changes of the current transaction whose new values aren't read yet start at unread
+	unread int
+	// savepoints open savepoints of the current transaction, the innermost last
+	savepoints []savepointMark
+	// old values of the row the pre-update hook reported last, the update hook reports the same change next
+	old *preUpdate
+	// pending events of ended transactions
+	pending []pendingHookEvent
+}
+
+// savepointMark the changes of the current transaction when a savepoint was opened, ROLLBACK TO drops later ones
+type savepointMark struct {
+	name                          string
+	tables, schemaTables, changes int
+}
+
+type pendingHookEvent struct {
+	HookEvent
+	changes []capturedChange
+}
+
+// newHookConn registers the hooks collecting the changes of conn
+func newHookConn(conn driver.Conn, hooks *Hooks, captureChanges bool) (*hookConn, error) {
+	registerer, ok := conn.(HookRegisterer)
+	if !ok {
+		return nil, fmt.Errorf("sqlite: driver connection %T can't register hooks", conn)
+	}
+
+	c := &hookConn{Conn: conn, hooks: hooks, captureChanges: captureChanges}
+	if captureChanges {
+		registerPreUpdateHook(conn, func(update *preUpdate) {
+			c.old = update
+		})
+	}
+	registerer.RegisterUpdateHook(func(op int, db string, table string, rowid int64) {
+		if db == "temp" {
+			return
+		}
+
+		c.tables = appendUnique(c.tables, hookTableName(db, table))
+		if c.captureChanges {
+			change := capturedChange{Change: Change{Table: hookTableName(db, table), RowID: rowid, Op: changeOp(op)}, schema: db, table: table}
+			if old := c.old; old != nil && old.matches(op, db, table, rowid) {
+				change.old = old.values
+			}
+			c.old = nil
+			c.changes = append(c.changes, change)
+		}
+	})
+	registerer.RegisterAuthorizer(func(op int, arg1, arg2, arg3 string) int {
+		if table, ok := schemaChangeTables(op, arg1, arg2); ok && arg3 != "temp" {
+			c.schemaTables = appendUnique(c.schemaTables, hookTableName(arg3, table))
+		}
+		return sqlite3.SQLITE_OK
+	})
+	registerer.RegisterCommitHook(func() int {
+		c.pending = append(c.pending, pendingHookEvent{HookEvent: HookEvent{Type: HookCommit, Tables: c.tables}, changes: c.changes})
+		if len(c.schemaTables) > 0 {
+			c.pending = append(c.pending, pendingHookEvent{HookEvent: HookEvent{Type: HookSchemaChange, Tables: c.schemaTables}})
+		}
+		c.reset()
+		return 0
+	})
+	registerer.RegisterRollbackHook(func() {
+		c.pending = append(c.pending, pendingHookEvent{HookEvent: HookEvent{Type: HookRollback, Tables: c.tables}})
+		c.reset()
+	})
+	return c, nil
+}
+
+// unwrap returns the driver connection
+func (c *hookConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// reset forgets the changes of the current transaction
+func (c *hookConn) reset() {
+	c.tables, c.schemaTables, c.changes, c.old = nil, nil, nil, nil
+	c.unread, c.savepoints = 0, nil
+}
+
+// track follows the savepoints of the current transaction after query succeeded, changes rolled back to
+// a savepoint are forgotten
+func (c *hookConn) track(query string) {
+	matches := savepointRegexp.FindStringSubmatch(query)
+	if matches == nil {
+		return
+	}
+
+	name, keyword := unquoteIdentifier(matches[2]), strings.ToUpper(strings.Fields(matches[1])[0])
+	if keyword == "SAVEPOINT" {
+		c.savepoints = append(c.savepoints, savepointMark{name: name, tables: len(c.tables), schemaTables: len(c.schemaTables), changes: len(c.changes)})
+		return
+	}
+
+	for i := len(c.savepoints) - 1; i >= 0; i-- {
+		mark := c.savepoints[i]
+		if !strings.EqualFold(mark.name, name) {
+			continue
+		}
+
+		if keyword == "RELEASE" {
+			// releasing a savepoint keeps its changes
+			c.savepoints = c.savepoints[:i]
+			return
+		}
+
+		// rolling back to a savepoint keeps it open
+		c.savepoints = c.savepoints[:i+1]
+		c.tables, c.schemaTables, c.changes = c.tables[:mark.tables], c.schemaTables[:mark.schemaTables], c.changes[:mark.changes]
+		if c.unread > mark.changes {
+			c.unread = mark.changes
+		}
+		return
+	}
+}
+
+// readValues reads the new values of the unread changes of the current transaction, once the statement making them ended
+func (c *hookConn) readValues() {
+	if c.unread < len(c.changes) {
+		readNewValues(context.Background(), c.Conn, c.changes[c.unread:])
+		c.unread = len(c.changes)
+	}
+}
+
+// flush emits the pending events, changes of failed statements outside of transactions are dropped,
+// e.g. the authorizer ran for a CREATE TABLE of an existing table
+func (c *hookConn) flush() {
+	if conn, ok := c.Conn.(interface{ AutoCommit() bool }); ok && conn.AutoCommit() {
+		c.reset()
+	} else if c.captureChanges {
+		c.readValues()
+	}
+
+	pending := c.pending
+	c.pending = nil
+	for _, event := range pending {
+		if len(event.changes) > 0 {
+			// hooks must not use their connection, values of the last statement are read once the transaction committed
+			event.Changes = readChanges(context.Background(), c.Conn, event.changes)
+		}
+		c.hooks.emit(event.HookEvent)
+	}
+}
+
+func (c *hookConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *hookConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &hookTx{Tx: tx, conn: c}, nil
+}
+
+func (c *hookConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	defer c.flush()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == nil {
+		c.track(query)
+	}
+	return result, err
+}
+
+func (c *hookConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		c.flush()
+		return nil, err
+	}
+	return &hookRows{Rows: rows, conn: c}, nil
+}
+
+func (c *hookConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *hookConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		c.flush()
+		return nil, err
+	}
+	return &hookStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+type hookTx struct {
+	driver.Tx
+	conn *hookConn
+}
+
+func (tx *hookTx) Commit() error {
+	defer tx.conn.flush()
+	return tx.Tx.Commit()
+}
+
+func (tx *hookTx) Rollback() error {
+	defer tx.conn.flush()
+	return tx.Tx.Rollback()
+}
+
+type hookStmt struct {
+	driver.Stmt
+	conn  *hookConn
+	query string
+}
+
+func (s *hookStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	defer s.conn.flush()
+	result, err := execer.ExecContext(ctx, args)
+	if err == nil {
+		s.conn.track(s.query)
+	}
+	return result, err
+}
+
+func (s *hookStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		s.conn.flush()
+		return nil, err
+	}
+	return &hookRows{Rows: rows, conn: s.conn}, nil
+}
+
+// hookRows emits the events of its statement once closed, e.g. the commit of an INSERT ... RETURNING
+type hookRows struct {
+	driver.Rows
+	conn *hookConn
+}
+
+func (r *hookRows) Close() error {
+	defer r.conn.flush()
+	return r.Rows.Close()
+}
+
+func (r *hookRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *hookRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+// hookTableName returns table qualified with schema db, unless it is the main database
+func hookTableName(db, table string) string {
+	if db == "" || db == "main" {
+		return table
+	}
+	return db + "." + table
+}
+
+// appendUnique appends str to strs unless it is already included
+func appendUnique(strs []string, str string) []string {
+	for _, s := range strs {
+		if s == str {
+			return strs
+		}
+	}
+	return append(strs, str)
+}
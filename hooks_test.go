@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type HookItem struct {
+	ID   uint
+	Name string `gorm:"index"`
+}
+
+// hookRecorder records the events of hooks
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []HookEvent
+}
+
+func (r *hookRecorder) record(event HookEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// take returns the recorded events of type typ and forgets all events
+func (r *hookRecorder) take(typ HookEventType) (events []HookEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if event.Type == typ {
+			events = append(events, event)
+		}
+	}
+	r.events = nil
+	return
+}
+
+func TestHooks(t *testing.T) {
+	hooks := NewHooks()
+	recorder := &hookRecorder{}
+	unsubscribe := hooks.Subscribe(recorder.record)
+
+	path := filepath.Join(tempDir(t), "hooks.db")
+	db, err := gorm.Open(New("file:"+path+"?_journal_mode=WAL", Config{Hooks: hooks}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&HookItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	// AutoMigrate runs all statements in one transaction
+	events := recorder.take(HookSchemaChange)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"hook_items"}, events[0].Tables)
+	}
+
+	db.Create(&HookItem{Name: "jinzhu"})
+	events = recorder.take(HookCommit)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"hook_items"}, events[0].Tables)
+	}
+
+	// events are emitted once the transaction ended
+	err = db.Transaction(func(tx *gorm.DB) error {
+		tx.Create(&HookItem{Name: "rolled back"})
+		assert.Empty(t, recorder.take(HookRollback))
+		return errors.New("rollback")
+	})
+	assert.Error(t, err)
+	events = recorder.take(HookRollback)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"hook_items"}, events[0].Tables)
+	}
+
+	// the authorizer runs for failing DDL too, it is no schema change
+	assert.Error(t, db.Exec("CREATE TABLE hook_items (id integer)").Error)
+	db.Model(&HookItem{}).Where("name = ?", "jinzhu").Update("name", "jz")
+	assert.Empty(t, recorder.take(HookSchemaChange))
+
+	if err := db.Migrator().DropIndex(&HookItem{}, "Name"); err != nil {
+		t.Fatalf("failed to drop index: %v", err)
+	}
+	events = recorder.take(HookSchemaChange)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, []string{"hook_items"}, events[0].Tables)
+	}
+
+	result, err := Checkpoint(db, CheckpointPassive)
+	if err != nil {
+		t.Fatalf("failed to checkpoint: %v", err)
+	}
+	events = recorder.take(HookCheckpoint)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, result, events[0].Checkpoint)
+	}
+
+	unsubscribe()
+	db.Create(&HookItem{Name: "unobserved"})
+	assert.Empty(t, recorder.take(HookCommit))
+}
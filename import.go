@@ -0,0 +1,199 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// maxVariableNumber SQLite's default limit of bound variables in a statement, SQLITE_MAX_VARIABLE_NUMBER
+const maxVariableNumber = 32766
+
+// ImportOptions options of ImportBatch
+type ImportOptions struct {
+	// BatchSize rows inserted per INSERT statement, defaults to as many rows as the bound variable limit allows
+	BatchSize int
+	// KeepIndexes keeps the secondary indexes of the table, by default they are dropped while importing and created
+	// again afterwards, which is faster than updating them for every row when importing many rows
+	KeepIndexes bool
+	// SkipHooks skips the create hooks of the model
+	SkipHooks bool
+}
+
+// ImportBatch bulk inserts values, a slice of models, or a channel of models or slices of models streamed until it is
+// closed, the model of a chan interface{} is set with db.Model, e.g. ImportBatch(db.Model(&User{}), ch).
+// The rows are inserted in a single transaction with foreign keys checked at commit (PRAGMA defer_foreign_keys)
+// and the secondary indexes of the table dropped, any error rolls back the whole import. Outside of a transaction
+// the connection also runs with PRAGMA synchronous = OFF, so the import isn't durable until the next synced write
+func ImportBatch(db *gorm.DB, values interface{}, opts ...ImportOptions) error {
+	var options ImportOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	// a new statement for every operation, so the pragmas below don't leave their destination as model
+	db = db.Session(&gorm.Session{})
+
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		// synchronous is a per connection setting
+		if _, ok := db.Statement.ConnPool.(*sql.Conn); !ok {
+			if _, err := db.DB(); err == nil {
+				return db.Connection(func(tx *gorm.DB) error {
+					return ImportBatch(tx, values, opts...)
+				})
+			}
+		}
+
+		var synchronous int
+		if err := db.Raw("PRAGMA synchronous").Scan(&synchronous).Error; err != nil {
+			return err
+		}
+		if err := db.Exec("PRAGMA synchronous = OFF").Error; err != nil {
+			return err
+		}
+		defer db.Exec(fmt.Sprintf("PRAGMA synchronous = %d", synchronous))
+	}
+
+	reflectValue := reflect.Indirect(reflect.ValueOf(values))
+	model := values
+	if reflectValue.Kind() == reflect.Chan {
+		if model = db.Statement.Model; model == nil {
+			model = reflect.New(reflectValue.Type().Elem()).Interface()
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("PRAGMA defer_foreign_keys = ON").Error; err != nil {
+			return err
+		}
+
+		var indexSQLs []string
+		err := tx.Migrator().(Migrator).RunWithValue(model, func(stmt *gorm.Statement) error {
+			if options.BatchSize <= 0 && stmt.Schema != nil && len(stmt.Schema.DBNames) > 0 {
+				options.BatchSize = maxVariableNumber / len(stmt.Schema.DBNames)
+			}
+			if options.KeepIndexes {
+				return nil
+			}
+
+			var err error
+			indexSQLs, err = dropIndexes(tx, statementTable(stmt))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		create := tx.Session(&gorm.Session{CreateBatchSize: options.BatchSize, SkipHooks: options.SkipHooks})
+		if reflectValue.Kind() == reflect.Chan {
+			err = importChan(create, reflectValue, options.BatchSize)
+		} else {
+			err = create.Create(values).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, sql := range indexSQLs {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dropIndexes drops the indexes created with CREATE INDEX of table, returning the statements creating them again,
+// indexes of PRIMARY KEY and UNIQUE constraints can't be dropped
+func dropIndexes(tx *gorm.DB, table string) ([]string, error) {
+	var indexes []struct {
+		Name string
+		SQL  string
+	}
+	schema, table := splitQualifiedTable(table)
+	if err := tx.Raw("SELECT name, sql FROM "+masterTable(schema)+" WHERE type = ? AND tbl_name = ? COLLATE NOCASE AND sql IS NOT NULL", "index", table).Scan(&indexes).Error; err != nil {
+		return nil, err
+	}
+
+	sqls := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		sql := index.SQL
+		if schema != "" {
+			var err error
+			if sql, err = renameIndexDDL(sql, schema, ""); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Exec("DROP INDEX " + qualifyTable(schema, index.Name)).Error; err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, sql)
+	}
+	return sqls, nil
+}
+
+// importChan creates the values received from ch until it is closed, single models are collected into batches
+func importChan(tx *gorm.DB, ch reflect.Value, batchSize int) error {
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var (
+		batch reflect.Value
+		cases = []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: ch},
+		}
+	)
+	flush := func() error {
+		if !batch.IsValid() || batch.Len() == 0 {
+			return nil
+		}
+		err := tx.Create(batch.Interface()).Error
+		batch = reflect.Value{}
+		return err
+	}
+
+	for {
+		chosen, value, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		if value.Kind() == reflect.Interface {
+			if value = value.Elem(); !value.IsValid() {
+				continue
+			}
+		}
+
+		if kind := reflect.Indirect(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := tx.Create(value.Interface()).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if batch.IsValid() && batch.Type().Elem() != value.Type() {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if !batch.IsValid() {
+			batch = reflect.MakeSlice(reflect.SliceOf(value.Type()), 0, batchSize)
+		}
+		if batch = reflect.Append(batch, value); batch.Len() >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestImportBatch(t *testing.T) {
+	type ImportItem struct {
+		ID       uint
+		Code     string `gorm:"uniqueIndex"`
+		Name     string `gorm:"index"`
+		ParentID *uint
+		Parent   *ImportItem
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{ForeignKeys: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&ImportItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var synchronous int
+	db.Raw("PRAGMA synchronous").Scan(&synchronous)
+
+	// children come before their parents, foreign keys are checked at commit
+	items := make([]ImportItem, 1000)
+	for i := range items {
+		id, parentID := uint(i+1), uint(len(items)-i)
+		items[i] = ImportItem{ID: id, Code: fmt.Sprint("code", i), Name: fmt.Sprint("name", i%10), ParentID: &parentID}
+	}
+	if err := ImportBatch(db, &items); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	var count int64
+	db.Model(&ImportItem{}).Count(&count)
+	assert.Equal(t, int64(1000), count)
+	assert.True(t, db.Migrator().HasIndex(&ImportItem{}, "Code"))
+	assert.True(t, db.Migrator().HasIndex(&ImportItem{}, "Name"))
+
+	var restored int
+	db.Raw("PRAGMA synchronous").Scan(&restored)
+	assert.Equal(t, synchronous, restored)
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for i := 0; i < 10; i++ {
+			ch <- &ImportItem{Code: fmt.Sprint("streamed", i)}
+		}
+		ch <- []ImportItem{{Code: "slice1"}, {Code: "slice2"}}
+	}()
+	if err := ImportBatch(db.Model(&ImportItem{}), ch, ImportOptions{BatchSize: 3}); err != nil {
+		t.Fatalf("failed to import channel: %v", err)
+	}
+	db.Model(&ImportItem{}).Count(&count)
+	assert.Equal(t, int64(1012), count)
+
+	// the unique index can't be created again, the whole import is rolled back
+	err = ImportBatch(db, []ImportItem{{Code: "duplicate"}, {Code: "duplicate"}})
+	assert.Error(t, err)
+	db.Model(&ImportItem{}).Count(&count)
+	assert.Equal(t, int64(1012), count)
+	assert.True(t, db.Migrator().HasIndex(&ImportItem{}, "Code"))
+
+	// invalid foreign keys fail at commit
+	parentID := uint(100000)
+	assert.Error(t, ImportBatch(db, []ImportItem{{Code: "orphan", ParentID: &parentID}}, ImportOptions{KeepIndexes: true}))
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// Index describes an existing index as reported by SQLite, its methods mirror gorm's index interface
+type Index struct {
+	TableName       string
+	NameValue       string
+	ColumnList      []string
+	PrimaryKeyValue sql.NullBool
+	UniqueValue     sql.NullBool
+	WhereValue      sql.NullString
+
+	// origin is "c" for CREATE INDEX, "u" for UNIQUE constraints and "pk" for PRIMARY KEY constraints
+	origin string
+}
+
+// Table returns the table the index belongs to
+func (idx Index) Table() string {
+	return idx.TableName
+}
+
+// Name returns the name of the index
+func (idx Index) Name() string {
+	return idx.NameValue
+}
+
+// Columns returns the indexed columns in index order
+func (idx Index) Columns() []string {
+	return idx.ColumnList
+}
+
+// PrimaryKey returns the index is created by a PRIMARY KEY constraint or not
+func (idx Index) PrimaryKey() (isPrimaryKey bool, ok bool) {
+	return idx.PrimaryKeyValue.Bool, idx.PrimaryKeyValue.Valid
+}
+
+// Unique returns the index is unique or not
+func (idx Index) Unique() (unique bool, ok bool) {
+	return idx.UniqueValue.Bool, idx.UniqueValue.Valid
+}
+
+// Option returns extra index options, always empty on SQLite
+func (idx Index) Option() string {
+	return ""
+}
+
+// Where returns the WHERE expression of a partial index
+func (idx Index) Where() (where string, ok bool) {
+	return idx.WhereValue.String, idx.WhereValue.Valid
+}
+
+// implements reports whether the index enforces the uniqueness of the unique index declared by idx, under any name,
+// e.g. a UNIQUE table constraint or a unique index created by other tools
+func (idx Index) implements(declared *schema.Index) bool {
+	if !idx.UniqueValue.Bool || declared.Class != "UNIQUE" || len(idx.ColumnList) != len(declared.Fields) ||
+		normalizeExpression(idx.WhereValue.String) != normalizeExpression(declared.Where) {
+		return false
+	}
+
+	for i, field := range declared.Fields {
+		column := field.Expression
+		if column == "" && field.Field != nil {
+			column = field.DBName
+		}
+		if !strings.EqualFold(normalizeExpression(idx.ColumnList[i]), normalizeExpression(column)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// interruptConn limits the duration of the statements of its connection, see Config.MaxQueryDuration.
+// The driver interrupts running statements with sqlite3_interrupt once their context is done, but an interrupt
+// between two calls into SQLite is lost, so statements whose context is already done never start
+type interruptConn struct {
+	driver.Conn
+	// maxDuration limits every statement
+	maxDuration time.Duration
+}
+
+// unwrap returns the driver connection
+func (c *interruptConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// withTimeout returns the context of a statement, limited to maxDuration
+func (c *interruptConn) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.maxDuration)
+}
+
+// interruptErr returns the error of a statement whose context stmtCtx derived from ctx is done, the error of ctx
+// if it is done too, otherwise the statement ran out of maxDuration
+func (c *interruptConn) interruptErr(ctx, stmtCtx context.Context, err error) error {
+	if err == nil || stmtCtx.Err() == nil {
+		return err
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.Is(err, stmtCtx.Err()) && !(errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrInterrupt) {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w, it ran longer than %v", ErrQueryTimeout, c.maxDuration)
+}
+
+func (c *interruptConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *interruptConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return beginner.BeginTx(ctx, opts)
+}
+
+func (c *interruptConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmtCtx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	result, err := execer.ExecContext(stmtCtx, query, args)
+	return result, c.interruptErr(ctx, stmtCtx, err)
+}
+
+func (c *interruptConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmtCtx, cancel := c.withTimeout(ctx)
+	rows, err := queryer.QueryContext(stmtCtx, query, args)
+	if err != nil {
+		cancel()
+		return nil, c.interruptErr(ctx, stmtCtx, err)
+	}
+	return &interruptRows{Rows: rows, conn: c, ctx: ctx, stmtCtx: stmtCtx, cancel: cancel}, nil
+}
+
+func (c *interruptConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *interruptConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &interruptStmt{Stmt: stmt, conn: c}, nil
+}
+
+type interruptStmt struct {
+	driver.Stmt
+	conn *interruptConn
+}
+
+func (s *interruptStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmtCtx, cancel := s.conn.withTimeout(ctx)
+	defer cancel()
+	result, err := execer.ExecContext(stmtCtx, args)
+	return result, s.conn.interruptErr(ctx, stmtCtx, err)
+}
+
+func (s *interruptStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmtCtx, cancel := s.conn.withTimeout(ctx)
+	rows, err := queryer.QueryContext(stmtCtx, args)
+	if err != nil {
+		cancel()
+		return nil, s.conn.interruptErr(ctx, stmtCtx, err)
+	}
+	return &interruptRows{Rows: rows, conn: s.conn, ctx: ctx, stmtCtx: stmtCtx, cancel: cancel}, nil
+}
+
+// interruptRows keeps the statement context until closed, SQLite runs the statement while its rows are read
+type interruptRows struct {
+	driver.Rows
+	conn         *interruptConn
+	ctx, stmtCtx context.Context
+	cancel       context.CancelFunc
+}
+
+func (r *interruptRows) Next(dest []driver.Value) error {
+	if r.stmtCtx.Err() != nil {
+		return r.conn.interruptErr(r.ctx, r.stmtCtx, r.stmtCtx.Err())
+	}
+	return r.conn.interruptErr(r.ctx, r.stmtCtx, r.Rows.Next(dest))
+}
+
+func (r *interruptRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+func (r *interruptRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *interruptRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQuery counts to a billion, it takes far longer than the tests wait
+const slowQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT count(*) FROM c"
+
+type InterruptItem struct {
+	ID   uint
+	Name string
+}
+
+func TestInterruptOnCancel(t *testing.T) {
+	db := openTestDB(t, "interrupt_cancel")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var count int64
+	err := db.WithContext(ctx).Raw(slowQuery).Row().Scan(&count)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+	// statements of done contexts never start
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 20; i++ {
+		err = db.WithContext(ctx).Exec(slowQuery).Error
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+	}
+}
+
+func TestMaxQueryDuration(t *testing.T) {
+	path := filepath.Join(tempDir(t), "interrupt.db")
+	db, err := gorm.Open(New(path, Config{MaxQueryDuration: 100 * time.Millisecond}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&InterruptItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	start := time.Now()
+	var count int64
+	err = db.Raw(slowQuery).Row().Scan(&count)
+	assert.True(t, errors.Is(err, ErrQueryTimeout), "unexpected error %v", err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+	// interrupted writes roll back
+	err = db.Exec("INSERT INTO interrupt_items (name) SELECT 'item' || x FROM (" +
+		"WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT x FROM c)").Error
+	assert.True(t, errors.Is(err, ErrQueryTimeout), "unexpected error %v", err)
+	db.Model(&InterruptItem{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// the timeout applies to each statement
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, db.Create(&InterruptItem{Name: "jinzhu"}).Error)
+		time.Sleep(50 * time.Millisecond)
+	}
+	db.Model(&InterruptItem{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	// a cancelled context isn't reported as a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = db.WithContext(ctx).Raw(slowQuery).Row().Scan(&count)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// JSON is a raw JSON document stored as text, query it with JSONQuery, JSONExtract and JSONSet,
+// which compile to the json1 functions https://www.sqlite.org/json1.html
+type JSON json.RawMessage
+
+// Value return json value, implement driver.Valuer interface
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan scan value into JSON, implements sql.Scanner interface
+func (j *JSON) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		bytes = make([]byte, len(v))
+		copy(bytes, v)
+	case string:
+		bytes = []byte(v)
+	case int64, float64, bool:
+		// values of numeric affinity columns are returned as numbers
+		bytes = []byte(fmt.Sprint(v))
+	default:
+		return fmt.Errorf("failed to unmarshal JSON value: %v", value)
+	}
+
+	if !json.Valid(bytes) {
+		return errors.New("invalid JSON value")
+	}
+	*j = JSON(bytes)
+	return nil
+}
+
+// MarshalJSON to output non base64 encoded []byte
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return json.RawMessage(j).MarshalJSON()
+}
+
+// UnmarshalJSON to deserialize []byte
+func (j *JSON) UnmarshalJSON(b []byte) error {
+	result := json.RawMessage{}
+	err := result.UnmarshalJSON(b)
+	*j = JSON(result)
+	return err
+}
+
+func (j JSON) String() string {
+	return string(j)
+}
+
+// GormDataType gorm common data type
+func (JSON) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType gorm db data type
+func (JSON) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "text"
+}
+
+// JSONQueryExpression json query expression, implements clause.Expression interface to use as querier
+type JSONQueryExpression struct {
+	column      string
+	keys        []string
+	hasKeys     bool
+	equals      bool
+	equalsValue interface{}
+}
+
+// JSONQuery query column as json
+func JSONQuery(column string) *JSONQueryExpression {
+	return &JSONQueryExpression{column: column}
+}
+
+// HasKey returns clause.Expression matching rows whose document contains the nested keys
+func (jsonQuery *JSONQueryExpression) HasKey(keys ...string) *JSONQueryExpression {
+	jsonQuery.keys = keys
+	jsonQuery.hasKeys = true
+	return jsonQuery
+}
+
+// Equals returns clause.Expression matching rows whose value at the nested keys equals value
+func (jsonQuery *JSONQueryExpression) Equals(value interface{}, keys ...string) *JSONQueryExpression {
+	jsonQuery.keys = keys
+	jsonQuery.equals = true
+	jsonQuery.equalsValue = value
+	return jsonQuery
+}
+
+// Build implements clause.Expression
+func (jsonQuery *JSONQueryExpression) Build(builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok && len(jsonQuery.keys) > 0 {
+		switch {
+		case jsonQuery.hasKeys:
+			builder.WriteString("json_type(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonPath(jsonQuery.keys...))
+			builder.WriteString(") IS NOT NULL")
+		case jsonQuery.equals:
+			builder.WriteString("json_extract(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonPath(jsonQuery.keys...))
+			builder.WriteString(") = ")
+			addJSONVar(builder, stmt, jsonQuery.equalsValue)
+		}
+	}
+}
+
+// JSONExtract returns the value at path of column's document, e.g. JSONExtract("attrs", "$.name")
+func JSONExtract(column string, path string) clause.Expression {
+	return clause.Expr{SQL: "json_extract(?,?)", Vars: []interface{}{clause.Column{Name: column}, path}}
+}
+
+// JSONSetExpression json_set expression, implements clause.Expression interface to use as updater
+type JSONSetExpression struct {
+	column     string
+	path2value map[string]interface{}
+	paths      []string
+}
+
+// JSONSet update fields of column's document, e.g. db.Model(&user).UpdateColumn("attrs", JSONSet("attrs").Set("$.age", 20))
+func JSONSet(column string) *JSONSetExpression {
+	return &JSONSetExpression{column: column, path2value: map[string]interface{}{}}
+}
+
+// Set sets value at path, maps, slices and structs are stored as JSON documents
+func (jsonSet *JSONSetExpression) Set(path string, value interface{}) *JSONSetExpression {
+	if _, ok := jsonSet.path2value[path]; !ok {
+		jsonSet.paths = append(jsonSet.paths, path)
+	}
+	jsonSet.path2value[path] = value
+	return jsonSet
+}
+
+// Build implements clause.Expression
+func (jsonSet *JSONSetExpression) Build(builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		builder.WriteString("json_set(")
+		builder.WriteQuoted(jsonSet.column)
+		for _, path := range jsonSet.paths {
+			builder.WriteByte(',')
+			builder.AddVar(stmt, path)
+			builder.WriteByte(',')
+			addJSONVar(builder, stmt, jsonSet.path2value[path])
+		}
+		builder.WriteByte(')')
+	}
+}
+
+// jsonPath builds a json path from nested keys, keys are quoted so they may contain dots
+func jsonPath(keys ...string) string {
+	var path strings.Builder
+	path.WriteByte('$')
+	for _, key := range keys {
+		path.WriteString(`."`)
+		path.WriteString(strings.ReplaceAll(key, `"`, `\"`))
+		path.WriteByte('"')
+	}
+	return path.String()
+}
+
+// addJSONVar adds value as a query variable, composite values are converted to JSON documents
+func addJSONVar(builder clause.Builder, stmt *gorm.Statement, value interface{}) {
+	switch v := value.(type) {
+	case JSON:
+		builder.WriteString("json(")
+		builder.AddVar(stmt, v)
+		builder.WriteByte(')')
+		return
+	case json.RawMessage:
+		addJSONVar(builder, stmt, JSON(v))
+		return
+	case []byte, time.Time, driver.Valuer:
+		builder.AddVar(stmt, value)
+		return
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if bytes, err := json.Marshal(value); err == nil {
+			addJSONVar(builder, stmt, JSON(bytes))
+			return
+		}
+	}
+	builder.AddVar(stmt, value)
+}
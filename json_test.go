@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestJSON(t *testing.T) {
+	type Record struct {
+		ID    uint
+		Attrs JSON
+	}
+
+	db := openTestDB(t, "json")
+	if err := db.AutoMigrate(&Record{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	records := []Record{{Attrs: JSON(`{"name":"jinzhu","tags":["a","b"]}`)}, {Attrs: JSON(`123`)}, {}}
+	if err := db.Create(&records).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	var results []Record
+	if err := db.Order("id").Find(&results).Error; err != nil {
+		t.Fatalf("failed to find: %v", err)
+	}
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, `{"name":"jinzhu","tags":["a","b"]}`, results[0].Attrs.String())
+		assert.Equal(t, `123`, results[1].Attrs.String())
+		assert.Nil(t, results[2].Attrs)
+	}
+
+	var invalid JSON
+	assert.Error(t, invalid.Scan("{invalid"))
+}
+
+func TestJSONExpressions(t *testing.T) {
+	db := openTestDB(t, "json_expressions").Session(&gorm.Session{DryRun: true})
+
+	type Record struct {
+		ID    uint
+		Attrs JSON
+	}
+
+	params := []struct {
+		name string
+		tx   *gorm.DB
+		sql  string
+		vars []interface{}
+	}{
+		{
+			"has_key",
+			db.Where(JSONQuery("attrs").HasKey("user", "name")).Find(&Record{}),
+			`SELECT * FROM "records" WHERE json_type("attrs",?) IS NOT NULL`,
+			[]interface{}{`$."user"."name"`},
+		},
+		{
+			"equals",
+			db.Where(JSONQuery("attrs").Equals("jinzhu", "name")).Find(&Record{}),
+			`SELECT * FROM "records" WHERE json_extract("attrs",?) = ?`,
+			[]interface{}{`$."name"`, "jinzhu"},
+		},
+		{
+			"equals_document",
+			db.Where(JSONQuery("attrs").Equals([]string{"a"}, "tags")).Find(&Record{}),
+			`SELECT * FROM "records" WHERE json_extract("attrs",?) = json(?)`,
+			[]interface{}{`$."tags"`, JSON(`["a"]`)},
+		},
+		{
+			"extract",
+			db.Where("? > ?", JSONExtract("attrs", "$.age"), 18).Find(&Record{}),
+			`SELECT * FROM "records" WHERE json_extract("attrs",?) > ?`,
+			[]interface{}{"$.age", 18},
+		},
+		{
+			"set",
+			db.Model(&Record{ID: 1}).UpdateColumn("attrs", JSONSet("attrs").Set("$.age", 20).Set("$.tags", map[string]int{"a": 1})),
+			`UPDATE "records" SET "attrs"=json_set("attrs",?,?,?,json(?)) WHERE "id" = ?`,
+			[]interface{}{"$.age", 20, "$.tags", JSON(`{"a":1}`), uint(1)},
+		},
+	}
+
+	for _, p := range params {
+		t.Run(p.name, func(t *testing.T) {
+			assert.Equal(t, p.sql, p.tx.Statement.SQL.String())
+			assert.Equal(t, p.vars, p.tx.Statement.Vars)
+		})
+	}
+}
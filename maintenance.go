@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CheckResult result of IntegrityCheck and QuickCheck
+type CheckResult struct {
+	// Problems describes each problem found, it is empty if the database is sound
+	Problems []string
+}
+
+// OK returns the check found no problems or not
+func (r CheckResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Vacuum rebuilds the database file, repacking it into a minimal amount of disk space
+func Vacuum(db *gorm.DB) error {
+	return db.Exec("VACUUM").Error
+}
+
+// VacuumInto writes a vacuumed copy of the database to path, which must not exist or be an empty file
+func VacuumInto(db *gorm.DB, path string) error {
+	return db.Exec("VACUUM INTO ?", path).Error
+}
+
+// IntegrityCheck runs PRAGMA integrity_check, a thorough check of the whole database
+func IntegrityCheck(db *gorm.DB) (CheckResult, error) {
+	return runCheck(db, "PRAGMA integrity_check")
+}
+
+// QuickCheck runs PRAGMA quick_check, which skips index content verification and runs in O(N) time
+func QuickCheck(db *gorm.DB) (CheckResult, error) {
+	return runCheck(db, "PRAGMA quick_check")
+}
+
+// Optimize runs PRAGMA optimize, which updates query planner statistics where they are likely useful
+func Optimize(db *gorm.DB) error {
+	return db.Exec("PRAGMA optimize").Error
+}
+
+// CheckpointMode mode of Checkpoint, https://www.sqlite.org/pragma.html#pragma_wal_checkpoint
+type CheckpointMode string
+
+const (
+	// CheckpointPassive checkpoints as many frames as possible without waiting for readers or writers
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// CheckpointFull waits for writers, then checkpoints all frames
+	CheckpointFull CheckpointMode = "FULL"
+	// CheckpointRestart works like CheckpointFull and also waits for readers, so the next writer restarts the WAL
+	CheckpointRestart CheckpointMode = "RESTART"
+	// CheckpointTruncate works like CheckpointRestart and also truncates the WAL file to zero bytes
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// CheckpointResult result of Checkpoint
+type CheckpointResult struct {
+	// Busy the checkpoint couldn't complete because of concurrent readers or writers
+	Busy bool
+	// Log frames in the WAL file, -1 if the database is not in WAL mode
+	Log int
+	// Checkpointed frames moved back into the database file, -1 if the database is not in WAL mode
+	Checkpointed int
+}
+
+// Checkpoint runs a WAL checkpoint with mode, CheckpointPassive is used when mode is empty
+func Checkpoint(db *gorm.DB, mode CheckpointMode) (result CheckpointResult, err error) {
+	if mode == "" {
+		mode = CheckpointPassive
+	}
+
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return result, fmt.Errorf("sqlite: unknown checkpoint mode %v", mode)
+	}
+
+	err = db.Raw("PRAGMA wal_checkpoint("+string(mode)+")").Row().Scan(&result.Busy, &result.Log, &result.Checkpointed)
+	if dialector, ok := asDialector(db.Dialector); ok && err == nil && dialector.Hooks != nil {
+		dialector.Hooks.emit(HookEvent{Type: HookCheckpoint, Checkpoint: result})
+	}
+	return
+}
+
+func runCheck(db *gorm.DB, pragma string) (result CheckResult, err error) {
+	var messages []string
+	if err = db.Raw(pragma).Scan(&messages).Error; err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		if message != "ok" {
+			result.Problems = append(result.Problems, message)
+		}
+	}
+	return
+}
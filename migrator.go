@@ -16,21 +16,268 @@ type Migrator struct {
 	migrator.Migrator
 }
 
+// RunWithoutForeignKey runs fc with foreign key enforcement disabled, foreign_keys is a per connection setting,
+// so m.DB is pinned to a single connection while fc runs
 func (m *Migrator) RunWithoutForeignKey(fc func() error) error {
+	if _, ok := m.DB.Statement.ConnPool.(gorm.TxCommitter); ok {
+		// PRAGMA foreign_keys is a no-op inside a transaction
+		return fc()
+	}
+
+	if _, ok := m.DB.Statement.ConnPool.(*sql.Conn); !ok {
+		if _, err := m.DB.DB(); err == nil {
+			return m.DB.Connection(func(tx *gorm.DB) error {
+				db := m.DB
+				m.DB = tx.Session(&gorm.Session{})
+				defer func() { m.DB = db }()
+
+				return m.RunWithoutForeignKey(fc)
+			})
+		}
+	}
+
 	var enabled int
 	m.DB.Raw("PRAGMA foreign_keys").Scan(&enabled)
 	if enabled == 1 {
-		m.DB.Exec("PRAGMA foreign_keys = OFF")
+		if err := m.DB.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
 		defer m.DB.Exec("PRAGMA foreign_keys = ON")
+
+		// table rebuilds check the foreign keys that are enforced again afterwards
+		db := m.DB
+		m.DB = m.DB.Set(foreignKeysEnforcedKey, true).Session(&gorm.Session{})
+		defer func() { m.DB = db }()
 	}
 
 	return fc()
 }
 
+const foreignKeysEnforcedKey = "sqlite:foreign_keys_enforced"
+
+// foreignKeysEnforced reports whether foreign keys are enforced on the connection of m.DB, outside of RunWithoutForeignKey
+func (m Migrator) foreignKeysEnforced() bool {
+	if _, ok := m.DB.Get(foreignKeysEnforcedKey); ok {
+		return true
+	}
+
+	var enabled int
+	m.DB.Raw("PRAGMA foreign_keys").Scan(&enabled)
+	return enabled == 1
+}
+
+// AutoMigrate auto migrate values in a single transaction, so a failing statement rolls back the whole migration instead
+// of leaving the schema half migrated. Foreign keys are checked once all tables are migrated, when they are enforced
+func (m Migrator) AutoMigrate(values ...interface{}) error {
+	return m.RunWithoutForeignKey(func() error {
+		checkForeignKeys := m.foreignKeysEnforced()
+		return m.DB.Transaction(func(tx *gorm.DB) error {
+			db := m.DB
+			m.DB = tx
+			defer func() { m.DB = db }()
+
+			// RunWithoutForeignKey can't disable enforcement inside a transaction of the caller, violations between
+			// statements are only reported when the transaction commits then
+			var enforced int
+			tx.Raw("PRAGMA foreign_keys").Scan(&enforced)
+			if enforced == 1 {
+				if err := tx.Exec("PRAGMA defer_foreign_keys = ON").Error; err != nil {
+					return err
+				}
+			}
+			if err := m.autoMigrate(values...); err != nil {
+				return err
+			}
+
+			if checkForeignKeys {
+				schemas := map[string]bool{}
+				for _, value := range values {
+					if err := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+						schema, _ := splitQualifiedTable(statementTable(stmt))
+						if !schemas[schema] {
+							schemas[schema] = true
+							return m.checkForeignKeys(tx, schema, "migrating")
+						}
+						return nil
+					}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// autoMigrate migrates values, virtual tables are created after regular tables so they can use them as content.
+// Tables and columns are renamed from their former names first, see TableRenamer and the renamedFrom tag,
+// CHECK constraints whose expression changed are replaced afterwards, see CheckConstrainer
+func (m Migrator) autoMigrate(values ...interface{}) error {
+	var tables, virtualTables []interface{}
+	for _, value := range values {
+		if isVirtualTable(value) {
+			virtualTables = append(virtualTables, value)
+		} else {
+			tables = append(tables, value)
+		}
+	}
+
+	for _, value := range tables {
+		if err := m.migrateRenames(value); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Migrator.AutoMigrate(tables...); err != nil {
+		return err
+	}
+	for _, value := range tables {
+		if err := m.migrateChecks(value); err != nil {
+			return err
+		}
+	}
+
+	// virtual tables can't be altered, only create missing ones
+	for _, value := range virtualTables {
+		if !m.HasTable(value) {
+			if err := m.CreateTable(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m Migrator) CreateTable(values ...interface{}) error {
+	var tables, virtualTables []interface{}
+	for _, value := range values {
+		if isVirtualTable(value) {
+			virtualTables = append(virtualTables, value)
+		} else {
+			tables = append(tables, value)
+		}
+	}
+
+	for _, value := range m.ReorderModels(tables, false) {
+		if err := m.createTable(value); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range virtualTables {
+		if err := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+			sqls, err := virtualTableSQLs(value, stmt)
+			if err != nil {
+				return err
+			}
+
+			for _, sql := range sqls {
+				if err := m.DB.Exec(sql).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable creates the table of value like gorm's migrator, the PRIMARY KEY table constraint is left out when a column
+// definition declares the primary key, as an AUTOINCREMENT column has to
+func (m Migrator) createTable(value interface{}) error {
+	tx := m.DB.Session(&gorm.Session{})
+	return m.RunWithValue(value, func(stmt *gorm.Statement) (err error) {
+		if err := m.checkAutoIncrement(stmt.Schema); err != nil {
+			return err
+		}
+
+		var (
+			createTableSQL          = "CREATE TABLE ? ("
+			values                  = []interface{}{m.CurrentTable(stmt)}
+			hasPrimaryKeyInDataType bool
+		)
+
+		for _, dbName := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[dbName]
+			if !field.IgnoreMigration {
+				createTableSQL += "? ?,"
+				hasPrimaryKeyInDataType = hasPrimaryKeyInDataType || strings.Contains(strings.ToUpper(m.DataTypeOf(field)), "PRIMARY KEY")
+				values = append(values, clause.Column{Name: dbName}, m.DB.Migrator().FullDataTypeOf(field))
+			}
+		}
+
+		if !hasPrimaryKeyInDataType && len(stmt.Schema.PrimaryFields) > 0 {
+			createTableSQL += "PRIMARY KEY ?,"
+			primaryKeys := []interface{}{}
+			for _, field := range stmt.Schema.PrimaryFields {
+				primaryKeys = append(primaryKeys, clause.Column{Name: field.DBName})
+			}
+			values = append(values, primaryKeys)
+		}
+
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			defer func(name string) {
+				if err == nil {
+					err = tx.Migrator().CreateIndex(value, name)
+				}
+			}(idx.Name)
+		}
+
+		for _, rel := range stmt.Schema.Relationships.Relations {
+			if !m.DB.DisableForeignKeyConstraintWhenMigrating {
+				if constraint := rel.ParseConstraint(); constraint != nil && constraint.Schema == stmt.Schema {
+					sql, vars := buildConstraint(constraint)
+					createTableSQL += sql + ","
+					values = append(values, vars...)
+				}
+			}
+		}
+
+		for _, chk := range declaredChecks(stmt) {
+			if chk.Name == "" {
+				createTableSQL += "CHECK (?),"
+				values = append(values, clause.Expr{SQL: chk.Expression})
+			} else {
+				createTableSQL += "CONSTRAINT ? CHECK (?),"
+				values = append(values, clause.Column{Name: chk.Name}, clause.Expr{SQL: chk.Expression})
+			}
+		}
+
+		createTableSQL = strings.TrimSuffix(createTableSQL, ",") + ")"
+		if tableOption, ok := m.DB.Get("gorm:table_options"); ok {
+			createTableSQL += fmt.Sprint(tableOption)
+		}
+		return tx.Exec(createTableSQL, values...).Error
+	})
+}
+
+// checkAutoIncrement returns an error for autoIncrement tags SQLite can't honor, it only auto increments
+// a single INTEGER PRIMARY KEY, the alias of the rowid
+func (m Migrator) checkAutoIncrement(s *schema.Schema) error {
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings["AUTOINCREMENT"]; !ok || !field.AutoIncrement {
+			continue
+		}
+
+		switch {
+		case !strings.HasPrefix(strings.ToLower(m.DataTypeOf(field)), "integer"):
+			return fmt.Errorf("%w: field %v of %v requires type integer", ErrUnsupportedAutoIncrement, field.Name, s.Name)
+		case field.PrimaryKey && len(s.PrimaryFields) > 1:
+			return fmt.Errorf("%w: field %v of %v is part of a composite primary key, consider a unique index instead", ErrUnsupportedAutoIncrement, field.Name, s.Name)
+		case !field.PrimaryKey && len(s.PrimaryFields) > 0:
+			return fmt.Errorf("%w: field %v of %v is not the primary key", ErrUnsupportedAutoIncrement, field.Name, s.Name)
+		}
+	}
+	return nil
+}
+
 func (m Migrator) HasTable(value interface{}) bool {
 	var count int
 	m.Migrator.RunWithValue(value, func(stmt *gorm.Statement) error {
-		return m.DB.Raw("SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?", stmt.Table).Row().Scan(&count)
+		schema, table := splitQualifiedTable(statementTable(stmt))
+		return m.DB.Raw("SELECT count(*) FROM "+masterTable(schema)+" WHERE type='table' AND name = ? COLLATE NOCASE", table).Row().Scan(&count)
 	})
 	return count > 0
 }
@@ -42,7 +289,7 @@ func (m Migrator) DropTable(values ...interface{}) error {
 
 		for i := len(values) - 1; i >= 0; i-- {
 			if err := m.RunWithValue(values[i], func(stmt *gorm.Statement) error {
-				return tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: stmt.Table}).Error
+				return tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: statementTable(stmt)}).Error
 			}); err != nil {
 				return err
 			}
@@ -66,9 +313,9 @@ func (m Migrator) HasColumn(value interface{}, name string) bool {
 		}
 
 		if name != "" {
+			schema, table := splitQualifiedTable(statementTable(stmt))
 			m.DB.Raw(
-				"SELECT count(*) FROM sqlite_master WHERE type = ? AND tbl_name = ? AND (sql LIKE ? OR sql LIKE ? OR sql LIKE ? OR sql LIKE ? OR sql LIKE ?)",
-				"table", stmt.Table, `%"`+name+`" %`, `%`+name+` %`, "%`"+name+"`%", "%["+name+"]%", "%\t"+name+"\t%",
+				"SELECT count(*) FROM pragma_table_xinfo(?, ?) WHERE name = ? COLLATE NOCASE", table, schemaName(schema), unquoteIdentifier(name),
 			).Row().Scan(&count)
 		}
 		return nil
@@ -76,90 +323,410 @@ func (m Migrator) HasColumn(value interface{}, name string) bool {
 	return count > 0
 }
 
-func (m Migrator) AlterColumn(value interface{}, name string) error {
-	return m.RunWithoutForeignKey(func() error {
-		return m.recreateTable(value, nil, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
-			if field := stmt.Schema.LookUpField(name); field != nil {
-				reg, err := regexp.Compile("(`|'|\"| )" + field.DBName + "(`|'|\"| ) .*?,")
-				if err != nil {
-					return "", nil, err
-				}
+// FullDataTypeOf returns the column definition of field, with Config.Comments its comment is appended as a -- comment
+func (m Migrator) FullDataTypeOf(field *schema.Field) clause.Expr {
+	// gorm quotes string defaults with double quotes, which SQLite reads as identifiers first
+	withoutDefault := *field
+	withoutDefault.HasDefaultValue = false
+	expr := m.Migrator.FullDataTypeOf(&withoutDefault)
+	if defaultValue := m.defaultValueOf(field); defaultValue != "" {
+		expr.SQL += " DEFAULT " + defaultValue
+	}
 
-				createSQL := reg.ReplaceAllString(rawDDL, fmt.Sprintf("`%v` ?,", field.DBName))
+	if field.Comment != "" && m.config().Comments {
+		expr.SQL += " -- " + strings.Join(strings.Fields(field.Comment), " ") + "\n"
+	}
+	return expr
+}
+
+// defaultValueOf returns the DEFAULT value of field as SQL, strings are quoted as literals and function calls
+// are parenthesized, e.g. (strftime('%s','now')) of strftime('%s','now')
+func (m Migrator) defaultValueOf(field *schema.Field) string {
+	if !field.HasDefaultValue {
+		return ""
+	}
+
+	switch value := field.DefaultValueInterface.(type) {
+	case nil:
+		if field.DefaultValue == "(-)" {
+			return ""
+		}
+		if defaultFunctionRegexp.MatchString(field.DefaultValue) {
+			return "(" + field.DefaultValue + ")"
+		}
+		return field.DefaultValue
+	case string:
+		// gorm inserts the default of string fields as a literal, expressions need parentheses, e.g. (CURRENT_TIMESTAMP)
+		return quoteString(value)
+	default:
+		stmt := &gorm.Statement{Vars: []interface{}{value}}
+		m.Dialector.BindVarTo(stmt, stmt, value)
+		return m.Dialector.Explain(stmt.SQL.String(), value)
+	}
+}
+
+// AddColumn adds the column of field name, ALTER TABLE ... ADD drops the line break ending a -- comment,
+// so commented columns are added without it and rebuilt with AlterColumn
+func (m Migrator) AddColumn(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil || field.Comment == "" || !m.config().Comments {
+			return m.Migrator.AddColumn(value, name)
+		}
+
+		if field.IgnoreMigration {
+			return nil
+		}
+		if err := m.DB.Exec(
+			"ALTER TABLE ? ADD ? ?",
+			m.CurrentTable(stmt), clause.Column{Name: field.DBName}, m.Migrator.FullDataTypeOf(field),
+		).Error; err != nil {
+			return err
+		}
+		return m.AlterColumn(value, name)
+	})
+}
+
+func (m Migrator) AlterColumn(value interface{}, name string) error {
+	return m.recreateTable(value, nil, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
+		if field := stmt.Schema.LookUpField(name); field != nil {
+			createDDL, err := parseDDL(rawDDL)
+			if err != nil {
+				return "", nil, err
+			}
 
-				return createSQL, []interface{}{m.FullDataTypeOf(field)}, nil
+			if createDDL.replaceColumn(field.DBName, quoteIdentifier(field.DBName)+" "+m.FullDataTypeOf(field).SQL) {
+				return createDDL.compile(), nil, nil
 			}
-			return "", nil, fmt.Errorf("failed to alter field with name %v", name)
-		})
+		}
+		return "", nil, fmt.Errorf("failed to alter field with name %v", name)
 	})
 }
 
-// ColumnTypes return columnTypes []gorm.ColumnType and execErr error
+// MigrateColumn migrates column of field, a changed collate tag rebuilds the table as gorm doesn't compare collations,
+// defaults are compared as SQLite declares them. Types are compared by affinity, e.g. a column declared as varchar(255)
+// stores strings like text, so only a changed affinity rebuilds the table
+func (m Migrator) MigrateColumn(value interface{}, field *schema.Field, columnType gorm.ColumnType) error {
+	if fullDataType, ok := columnType.ColumnType(); ok && !field.IgnoreMigration {
+		expected := defaultCollationName
+		if collation := field.TagSettings["COLLATE"]; collation != "" {
+			expected = collation
+		}
+		if !strings.EqualFold(collationOf(fullDataType), expected) {
+			return m.AlterColumn(value, field.DBName)
+		}
+	}
+
+	if c, ok := columnType.(migrator.ColumnType); ok && c.DataTypeValue.Valid && !field.IgnoreMigration && !field.PrimaryKey {
+		dataType := m.DataTypeOf(field)
+		if !equivalentTypes(c.DataTypeValue.String, dataType) {
+			return m.AlterColumn(value, field.DBName)
+		}
+		// gorm compares the type as declared, an equivalent type is the same
+		if loc := collateRegexp.FindStringIndex(dataType); loc != nil {
+			dataType = dataType[:loc[0]]
+		}
+		c.DataTypeValue.String = dataType
+		columnType = c
+	}
+
+	// gorm compares defaults to the tag as written, a default created from the tag is the same
+	if c, ok := columnType.(migrator.ColumnType); ok {
+		if current, ok := c.DefaultValue(); ok && current != field.DefaultValue {
+			if expected, ok := parseColumnDefault(" DEFAULT " + m.defaultValueOf(field)); ok && expected.String() == current {
+				c.DefaultValueValue.String = field.DefaultValue
+				columnType = c
+			}
+		}
+	}
+	return m.Migrator.MigrateColumn(value, field, columnType)
+}
+
+// ColumnTypes return columnTypes []gorm.ColumnType and execErr error,
+// column metadata comes from PRAGMA table_xinfo, the CREATE TABLE DDL is only used for default values
 func (m Migrator) ColumnTypes(value interface{}) ([]gorm.ColumnType, error) {
 	columnTypes := make([]gorm.ColumnType, 0)
 	execErr := m.RunWithValue(value, func(stmt *gorm.Statement) (err error) {
 		var (
-			sqls   []string
-			sqlDDL *ddl
+			uniques    = map[string]bool{}
+			defaults   = map[string]sql.NullString{}
+			comments   = map[string]string{}
+			collations = map[string]string{}
+			sqlTypes   = map[string]*sql.ColumnType{}
 		)
 
-		if err := m.DB.Raw("SELECT sql FROM sqlite_master WHERE type IN ? AND tbl_name = ? AND sql IS NOT NULL order by type = ? desc", []string{"table", "index"}, stmt.Table, "table").Scan(&sqls).Error; err != nil {
+		rawDDL, err := m.getRawDDL(statementTable(stmt))
+		if err != nil {
 			return err
 		}
+		if sqlDDL, err := parseDDL(rawDDL); err == nil {
+			for _, column := range sqlDDL.columns {
+				defaults[strings.ToLower(column.NameValue.String)] = column.DefaultValueValue
+				comments[strings.ToLower(column.NameValue.String)] = column.CommentValue.String
+				if collation := collateRegexp.FindString(column.ColumnTypeValue.String); collation != "" {
+					collations[strings.ToLower(column.NameValue.String)] = collation
+				}
+			}
+		}
 
-		if sqlDDL, err = parseDDL(sqls...); err != nil {
+		indexes, err := m.GetIndexes(statementTable(stmt))
+		if err != nil {
 			return err
 		}
+		for _, idx := range indexes {
+			if idx.origin == "u" && len(idx.Columns()) == 1 {
+				uniques[strings.ToLower(idx.Columns()[0])] = true
+			}
+		}
 
-		rows, err := m.DB.Session(&gorm.Session{}).Table(stmt.Table).Limit(1).Rows()
+		sqlRows, err := m.DB.Session(&gorm.Session{}).Table("?", clause.Table{Name: statementTable(stmt)}).Limit(1).Rows()
 		if err != nil {
 			return err
 		}
-		defer func() {
-			err = rows.Close()
-		}()
+		rawColumnTypes, err := sqlRows.ColumnTypes()
+		sqlRows.Close()
+		if err != nil {
+			return err
+		}
+		for _, c := range rawColumnTypes {
+			sqlTypes[strings.ToLower(c.Name())] = c
+		}
 
-		var rawColumnTypes []*sql.ColumnType
-		rawColumnTypes, err = rows.ColumnTypes()
+		schema, table := splitQualifiedTable(statementTable(stmt))
+		rows, err := m.DB.Raw("SELECT name, type, `notnull`, dflt_value, pk, hidden FROM pragma_table_xinfo(?, ?) ORDER BY cid", table, schemaName(schema)).Rows()
 		if err != nil {
 			return err
 		}
+		defer func() {
+			if closeErr := rows.Close(); err == nil {
+				err = closeErr
+			}
+		}()
 
-		for _, c := range rawColumnTypes {
-			columnType := migrator.ColumnType{SQLColumnType: c}
-			for _, column := range sqlDDL.columns {
-				if column.NameValue.String == c.Name() {
-					column.SQLColumnType = c
-					columnType = column
-					break
+		var primaryKeys int
+		for rows.Next() {
+			var (
+				name, dataType string
+				notNull        bool
+				defaultValue   sql.NullString
+				pk, hidden     int
+			)
+			if err = rows.Scan(&name, &dataType, &notNull, &defaultValue, &pk, &hidden); err != nil {
+				return err
+			}
+
+			// hidden columns of virtual tables are not part of the schema
+			if hidden == 1 {
+				continue
+			}
+
+			lowerName := strings.ToLower(name)
+			// gorm's AutoMigrate matches columns by name, a column declared in another case is the field's column
+			if stmt.Schema != nil {
+				for _, dbName := range stmt.Schema.DBNames {
+					if strings.EqualFold(dbName, name) {
+						name = dbName
+						break
+					}
 				}
 			}
+			columnType := migrator.ColumnType{
+				SQLColumnType:      sqlTypes[lowerName],
+				NameValue:          sql.NullString{String: name, Valid: true},
+				DataTypeValue:      sql.NullString{String: dataType, Valid: true},
+				ColumnTypeValue:    sql.NullString{String: dataType + collations[lowerName], Valid: true},
+				PrimaryKeyValue:    sql.NullBool{Bool: pk > 0, Valid: true},
+				AutoIncrementValue: sql.NullBool{Valid: true},
+				NullableValue:      sql.NullBool{Bool: !notNull && pk == 0, Valid: true},
+				UniqueValue:        sql.NullBool{Bool: uniques[lowerName], Valid: true},
+				DefaultValueValue:  sql.NullString{Valid: true},
+			}
+
+			if value, ok := defaults[lowerName]; ok {
+				columnType.DefaultValueValue = value
+			} else if defaultValue.Valid {
+				columnType.DefaultValueValue.String = strings.Trim(defaultValue.String, `"'`)
+			}
+
+			if m.config().Comments {
+				columnType.CommentValue = sql.NullString{String: comments[lowerName], Valid: true}
+			}
+
+			if pk > 0 {
+				primaryKeys++
+			}
 			columnTypes = append(columnTypes, columnType)
 		}
 
-		return err
+		// a single INTEGER PRIMARY KEY column is an alias of the auto incrementing rowid
+		if primaryKeys == 1 {
+			for idx, c := range columnTypes {
+				columnType := c.(migrator.ColumnType)
+				if columnType.PrimaryKeyValue.Bool && strings.EqualFold(columnType.DataTypeValue.String, "integer") {
+					columnType.AutoIncrementValue.Bool = true
+					columnTypes[idx] = columnType
+				}
+			}
+		}
+
+		return rows.Err()
 	})
 
 	return columnTypes, execErr
 }
 
+// RenameColumn renames the column with ALTER TABLE ... RENAME COLUMN, SQLite before 3.25 doesn't support it,
+// so the table is rebuilt instead
+func (m Migrator) RenameColumn(value interface{}, oldName, newName string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if stmt.Schema != nil {
+			if field := stmt.Schema.LookUpField(oldName); field != nil {
+				oldName = field.DBName
+			}
+			if field := stmt.Schema.LookUpField(newName); field != nil {
+				newName = field.DBName
+			}
+		}
+
+		version, err := m.version()
+		if err != nil {
+			return err
+		}
+		// https://www.sqlite.org/releaselog/3_25_0.html
+		if compareVersion(version, "3.25.0") >= 0 {
+			return m.DB.Exec(
+				"ALTER TABLE ? RENAME COLUMN ? TO ?",
+				m.CurrentTable(stmt), clause.Column{Name: oldName}, clause.Column{Name: newName},
+			).Error
+		}
+
+		return m.RunWithoutForeignKey(func() error {
+			return m.rebuildTable(value, nil, map[string]string{oldName: newName}, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
+				createDDL, err := parseDDL(rawDDL)
+				if err != nil {
+					return "", nil, err
+				}
+
+				if !createDDL.renameColumn(oldName, newName) {
+					return "", nil, fmt.Errorf("failed to find column with name %v", oldName)
+				}
+				return createDDL.compile(), nil, nil
+			})
+		})
+	})
+}
+
+// DropColumn drops the column with ALTER TABLE ... DROP COLUMN of SQLite 3.35, the table is rebuilt instead when the column
+// is referenced by an index, constraint, generated column, view or trigger, or SQLite is older.
+// Config.DisableDropColumnRebuild returns an error listing the dependencies instead of rebuilding the table
 func (m Migrator) DropColumn(value interface{}, name string) error {
-	return m.recreateTable(value, nil, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
-		if field := stmt.Schema.LookUpField(name); field != nil {
-			name = field.DBName
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if stmt.Schema != nil {
+			if field := stmt.Schema.LookUpField(name); field != nil {
+				name = field.DBName
+			}
+		}
+
+		table := statementTable(stmt)
+		dependencies, err := m.dropColumnDependencies(table, name)
+		if err != nil {
+			return err
 		}
 
-		reg, err := regexp.Compile("(`|'|\"| |\\[)" + name + "(`|'|\"| |\\]) .*?,")
+		version, err := m.version()
 		if err != nil {
-			return "", nil, err
+			return err
+		}
+		// https://www.sqlite.org/releaselog/3_35_0.html
+		native := compareVersion(version, "3.35.0") >= 0
+		if native && len(dependencies) == 0 {
+			return m.DB.Exec("ALTER TABLE ? DROP COLUMN ?", m.CurrentTable(stmt), clause.Column{Name: name}).Error
 		}
 
-		createSQL := reg.ReplaceAllString(rawDDL, "")
+		if m.config().DisableDropColumnRebuild {
+			if !native {
+				return fmt.Errorf("sqlite: DROP COLUMN requires SQLite 3.35.0, got %v", version)
+			}
+			return fmt.Errorf("%w: column %v of table %v is referenced by %v", ErrColumnHasDependencies, name, table, strings.Join(dependencies, ", "))
+		}
+
+		return m.recreateTable(value, nil, func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
+			createDDL, err := parseDDL(rawDDL)
+			if err != nil {
+				return "", nil, err
+			}
 
-		return createSQL, nil, nil
+			if !createDDL.removeColumn(name) {
+				return "", nil, fmt.Errorf("failed to find column with name %v", name)
+			}
+			return createDDL.compile(), nil, nil
+		})
 	})
 }
 
+// dropColumnDependencies returns the schema objects referring to column name of table, which keep ALTER TABLE ... DROP COLUMN
+// from dropping it, see https://www.sqlite.org/lang_altertable.html#altertabdropcol
+func (m Migrator) dropColumnDependencies(table, name string) (dependencies []string, err error) {
+	rawDDL, err := m.getRawDDL(table)
+	if err != nil {
+		return nil, err
+	}
+	createDDL, err := parseDDL(rawDDL)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := createDDL.columnIndex(name)
+	if idx < 0 {
+		return nil, fmt.Errorf("failed to find column with name %v", name)
+	}
+
+	nameReg := regexp.MustCompile("(?i)\\b" + regexp.QuoteMeta(name) + "\\b")
+	for i, f := range createDDL.fields {
+		definition, _, _ := splitComments(f)
+		switch {
+		case i == idx:
+			// constraints of the column itself, a CHECK constraint of the column doesn't keep it from being dropped
+			for _, constraint := range []string{"PRIMARY KEY", "UNIQUE", "REFERENCES"} {
+				if regexp.MustCompile("(?i)\\b" + strings.ReplaceAll(constraint, " ", "\\s+") + "\\b").MatchString(definition) {
+					dependencies = append(dependencies, strings.ToLower(constraint)+" constraint")
+				}
+			}
+		case !nameReg.MatchString(definition):
+		case isTableConstraint(definition):
+			dependencies = append(dependencies, "table constraint "+definition)
+		default:
+			if matches := columnRegexp.FindStringSubmatch(definition); len(matches) > 0 && generatedColumnRegexp.MatchString(definition) {
+				dependencies = append(dependencies, "generated column "+unquoteIdentifier(matches[1]))
+			}
+		}
+	}
+
+	schema, tableName := splitQualifiedTable(table)
+	var objects []struct {
+		Type    string
+		Name    string
+		TblName string
+		SQL     string
+	}
+	if err := m.DB.Raw(
+		"SELECT type, name, tbl_name, sql FROM "+masterTable(schema)+" WHERE type IN (?, ?, ?) AND sql IS NOT NULL", "index", "view", "trigger",
+	).Scan(&objects).Error; err != nil {
+		return nil, err
+	}
+
+	tableReg := regexp.MustCompile("(?i)\\b" + regexp.QuoteMeta(tableName) + "\\b")
+	for _, object := range objects {
+		if object.Type == "index" && !strings.EqualFold(object.TblName, tableName) {
+			continue
+		}
+		if nameReg.MatchString(object.SQL) && tableReg.MatchString(object.SQL) {
+			dependencies = append(dependencies, object.Type+" "+object.Name)
+		}
+	}
+	return dependencies, nil
+}
+
 func (m Migrator) CreateConstraint(value interface{}, name string) error {
 	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
 		constraint, chk, table := m.GuessConstraintAndTable(stmt, name)
@@ -210,14 +777,76 @@ func (m Migrator) DropConstraint(value interface{}, name string) error {
 				if err != nil {
 					return "", nil, err
 				}
-				createDDL.removeConstraint(name)
-				createSQL := createDDL.compile()
 
-				return createSQL, nil, nil
+				removed := createDDL.removeConstraint(name)
+				if !removed && constraint != nil {
+					var columns []string
+					for _, field := range constraint.ForeignKeys {
+						columns = append(columns, field.DBName)
+					}
+					removed = createDDL.removeForeignKey(columns, constraint.ReferenceSchema.Table)
+				}
+				// CHECK column constraints, or unnamed CHECK constraints of the check
+				if !removed {
+					removed = createDDL.removeCheck(name, "")
+				}
+				if !removed && chk != nil {
+					removed = createDDL.removeCheck("", chk.Constraint)
+				}
+
+				if !removed {
+					return "", nil, fmt.Errorf("failed to find constraint with name %v", name)
+				}
+				return createDDL.compile(), nil, nil
 			})
 	})
 }
 
+// RenameConstraint renames a named table constraint by rebuilding the table
+func (m Migrator) RenameConstraint(value interface{}, oldName, newName string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		constraint, chk, table := m.GuessConstraintAndTable(stmt, oldName)
+		if constraint != nil {
+			oldName = constraint.Name
+		} else if chk != nil {
+			oldName = chk.Name
+		}
+
+		return m.recreateTable(value, &table,
+			func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error) {
+				createDDL, err := parseDDL(rawDDL)
+				if err != nil {
+					return "", nil, err
+				}
+
+				if !createDDL.renameConstraint(oldName, newName) {
+					return "", nil, fmt.Errorf("failed to find constraint with name %v", oldName)
+				}
+				return createDDL.compile(), nil, nil
+			})
+	})
+}
+
+// GuessConstraintAndTable guess statement's constraint and it's table based on name, tables of attached databases are schema qualified,
+// CHECK constraints of CheckConstrainer are found by name
+func (m Migrator) GuessConstraintAndTable(stmt *gorm.Statement, name string) (*schema.Constraint, *schema.Check, string) {
+	constraint, chk, table := m.Migrator.GuessConstraintAndTable(stmt, name)
+	if table == stmt.Table {
+		table = statementTable(stmt)
+	}
+	if constraint == nil && chk == nil {
+		if constrainer, ok := checkConstrainer(stmt); ok {
+			for _, c := range constrainer.CheckConstraints() {
+				if c.Name != "" && c.Name == name {
+					chk = &schema.Check{Name: c.Name, Constraint: c.Expression}
+					break
+				}
+			}
+		}
+	}
+	return constraint, chk, table
+}
+
 func (m Migrator) HasConstraint(value interface{}, name string) bool {
 	var count int64
 	m.RunWithValue(value, func(stmt *gorm.Statement) error {
@@ -228,17 +857,78 @@ func (m Migrator) HasConstraint(value interface{}, name string) bool {
 			name = chk.Name
 		}
 
-		m.DB.Raw(
-			"SELECT count(*) FROM sqlite_master WHERE type = ? AND tbl_name = ? AND (sql LIKE ? OR sql LIKE ? OR sql LIKE ? OR sql LIKE ? OR sql LIKE ?)",
-			"table", table, `%CONSTRAINT "`+name+`" %`, `%CONSTRAINT `+name+` %`, "%CONSTRAINT `"+name+"`%", "%CONSTRAINT ["+name+"]%", "%CONSTRAINT \t"+name+"\t%",
-		).Row().Scan(&count)
+		// foreign keys are unnamed in SQLite's metadata, match them by their columns instead
+		if constraint != nil {
+			foreignKeys, err := m.GetForeignKeys(table)
+			if err != nil {
+				return err
+			}
+			for _, fk := range foreignKeys {
+				if fk.matches(constraint) {
+					count++
+				}
+			}
+			return nil
+		}
 
+		rawDDL, err := m.getRawDDL(table)
+		if err != nil || rawDDL == "" {
+			return err
+		}
+		createDDL, err := parseDDL(rawDDL)
+		if err != nil {
+			return err
+		}
+		if createDDL.hasConstraint(unquoteIdentifier(name)) {
+			count++
+		} else if _, ok := createDDL.findCheck(unquoteIdentifier(name), ""); ok {
+			count++
+		} else if chk != nil {
+			if _, ok := createDDL.findCheck("", chk.Constraint); ok {
+				count++
+			}
+		}
 		return nil
 	})
 
 	return count > 0
 }
 
+// GetForeignKeys returns the foreign keys of value's table, see https://www.sqlite.org/pragma.html#pragma_foreign_key_list
+func (m Migrator) GetForeignKeys(value interface{}) ([]ForeignKey, error) {
+	foreignKeys := make([]ForeignKey, 0)
+	err := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		schema, table := splitQualifiedTable(statementTable(stmt))
+		rows, err := m.DB.Raw("SELECT id, `table`, `from`, `to`, on_update, on_delete FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq", table, schemaName(schema)).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				id                 int
+				refTable, from     string
+				to                 sql.NullString
+				onUpdate, onDelete string
+			)
+			if err := rows.Scan(&id, &refTable, &from, &to, &onUpdate, &onDelete); err != nil {
+				return err
+			}
+
+			if len(foreignKeys) == 0 || foreignKeys[len(foreignKeys)-1].ID != id {
+				foreignKeys = append(foreignKeys, ForeignKey{ID: id, Table: table, ReferenceTable: refTable, OnUpdate: onUpdate, OnDelete: onDelete})
+			}
+
+			fk := &foreignKeys[len(foreignKeys)-1]
+			fk.Columns = append(fk.Columns, from)
+			fk.References = append(fk.References, to.String)
+		}
+		return rows.Err()
+	})
+	return foreignKeys, err
+}
+
 func (m Migrator) CurrentDatabase() (name string) {
 	var null interface{}
 	m.DB.Raw("PRAGMA database_list").Row().Scan(&null, &name, &null)
@@ -268,7 +958,9 @@ func (m Migrator) CreateIndex(value interface{}, name string) error {
 	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
 		if idx := stmt.Schema.LookIndex(name); idx != nil {
 			opts := m.BuildIndexOptions(idx.Fields, stmt)
-			values := []interface{}{clause.Column{Name: idx.Name}, clause.Table{Name: stmt.Table}, opts}
+			// indexes of attached databases are qualified with the schema, their table is not
+			schema, table := splitQualifiedTable(statementTable(stmt))
+			values := []interface{}{clause.Table{Name: qualifyName(schema, idx.Name)}, clause.Table{Name: quoteIdentifier(table)}, opts}
 
 			createIndexSQL := "CREATE "
 			if idx.Class != "" {
@@ -292,18 +984,37 @@ func (m Migrator) CreateIndex(value interface{}, name string) error {
 	})
 }
 
+// HasIndex reports whether the index name exists, a unique index of the model also exists when another unique index
+// or a UNIQUE table constraint covers the same columns, so AutoMigrate doesn't add a duplicate of it
 func (m Migrator) HasIndex(value interface{}, name string) bool {
 	var count int
 	m.RunWithValue(value, func(stmt *gorm.Statement) error {
-		if idx := stmt.Schema.LookIndex(name); idx != nil {
-			name = idx.Name
+		var declared *schema.Index
+		if stmt.Schema != nil {
+			if declared = stmt.Schema.LookIndex(name); declared != nil {
+				name = declared.Name
+			}
 		}
 
 		if name != "" {
+			schema, table := splitQualifiedTable(statementTable(stmt))
 			m.DB.Raw(
-				"SELECT count(*) FROM sqlite_master WHERE type = ? AND tbl_name = ? AND name = ?", "index", stmt.Table, name,
+				"SELECT count(*) FROM "+masterTable(schema)+" WHERE type = ? AND tbl_name = ? COLLATE NOCASE AND name = ? COLLATE NOCASE",
+				"index", table, unquoteIdentifier(name),
 			).Row().Scan(&count)
 		}
+
+		if count == 0 && declared != nil && declared.Class == "UNIQUE" {
+			indexes, err := m.GetIndexes(value)
+			if err != nil {
+				return err
+			}
+			for _, idx := range indexes {
+				if idx.implements(declared) {
+					count++
+				}
+			}
+		}
 		return nil
 	})
 	return count > 0
@@ -312,11 +1023,22 @@ func (m Migrator) HasIndex(value interface{}, name string) bool {
 func (m Migrator) RenameIndex(value interface{}, oldName, newName string) error {
 	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
 		var sql string
-		m.DB.Raw("SELECT sql FROM sqlite_master WHERE type = ? AND tbl_name = ? AND name = ?", "index", stmt.Table, oldName).Row().Scan(&sql)
-		if sql != "" {
-			return m.DB.Exec(strings.Replace(sql, oldName, newName, 1)).Error
+		schema, table := splitQualifiedTable(statementTable(stmt))
+		m.DB.Raw("SELECT sql FROM "+masterTable(schema)+" WHERE type = ? AND tbl_name = ? COLLATE NOCASE AND name = ? COLLATE NOCASE", "index", table, oldName).Row().Scan(&sql)
+		if sql == "" {
+			return fmt.Errorf("failed to find index with name %v", oldName)
 		}
-		return fmt.Errorf("failed to find index with name %v", oldName)
+
+		createSQL, err := renameIndexDDL(sql, schema, newName)
+		if err != nil {
+			return err
+		}
+		return m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP INDEX ?", clause.Table{Name: qualifyName(schema, oldName)}).Error; err != nil {
+				return err
+			}
+			return tx.Exec(createSQL).Error
+		})
 	})
 }
 
@@ -326,10 +1048,94 @@ func (m Migrator) DropIndex(value interface{}, name string) error {
 			name = idx.Name
 		}
 
-		return m.DB.Exec("DROP INDEX ?", clause.Column{Name: name}).Error
+		schema, _ := splitQualifiedTable(statementTable(stmt))
+		return m.DB.Exec("DROP INDEX ?", clause.Table{Name: qualifyName(schema, name)}).Error
 	})
 }
 
+// GetIndexes returns the indexes of value's table, see https://www.sqlite.org/pragma.html#pragma_index_list
+func (m Migrator) GetIndexes(value interface{}) ([]Index, error) {
+	indexes := make([]Index, 0)
+	err := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		var indexList []struct {
+			Seq     int
+			Name    string
+			Unique  bool
+			Origin  string
+			Partial bool
+		}
+		schema, table := splitQualifiedTable(statementTable(stmt))
+		if err := m.DB.Raw("SELECT seq, name, `unique`, origin, partial FROM pragma_index_list(?, ?) ORDER BY seq", table, schemaName(schema)).Scan(&indexList).Error; err != nil {
+			return err
+		}
+
+		for _, il := range indexList {
+			var columns []sql.NullString
+			if err := m.DB.Raw("SELECT name FROM pragma_index_info(?, ?) ORDER BY seqno", il.Name, schemaName(schema)).Scan(&columns).Error; err != nil {
+				return err
+			}
+
+			index := Index{
+				TableName:       table,
+				NameValue:       il.Name,
+				PrimaryKeyValue: sql.NullBool{Bool: il.Origin == "pk", Valid: true},
+				UniqueValue:     sql.NullBool{Bool: il.Unique, Valid: true},
+				origin:          il.Origin,
+			}
+
+			// indexes created by CREATE INDEX keep their DDL, which holds expressions and WHERE clauses
+			var (
+				parsed *indexDDL
+				err    error
+			)
+			if il.Origin == "c" {
+				var createSQL string
+				if err := m.DB.Raw("SELECT sql FROM "+masterTable(schema)+" WHERE type = ? AND name = ?", "index", il.Name).Row().Scan(&createSQL); err != nil {
+					return err
+				}
+				if parsed, err = parseIndexDDL(createSQL); err != nil {
+					return err
+				}
+				if parsed.where != "" {
+					index.WhereValue = sql.NullString{String: parsed.where, Valid: true}
+				}
+			}
+
+			for i, column := range columns {
+				if !column.Valid && parsed != nil && i < len(parsed.columns) {
+					column.String = parsed.columns[i]
+				}
+				index.ColumnList = append(index.ColumnList, column.String)
+			}
+
+			indexes = append(indexes, index)
+		}
+		return nil
+	})
+	return indexes, err
+}
+
+func isVirtualTable(value interface{}) bool {
+	switch value.(type) {
+	case FTSTabler, RTreeTabler, VectorTabler:
+		return true
+	}
+	return false
+}
+
+// virtualTableSQLs returns the statements creating value's virtual table
+func virtualTableSQLs(value interface{}, stmt *gorm.Statement) ([]string, error) {
+	switch v := value.(type) {
+	case FTSTabler:
+		return ftsTableSQLs(stmt, v.FTSTable()), nil
+	case RTreeTabler:
+		return rtreeTableSQLs(stmt, v.RTreeTable())
+	case VectorTabler:
+		return vectorTableSQLs(stmt, v.VectorTable())
+	}
+	return nil, fmt.Errorf("%T is not a virtual table", value)
+}
+
 func buildConstraint(constraint *schema.Constraint) (sql string, results []interface{}) {
 	sql = "CONSTRAINT ? FOREIGN KEY ? REFERENCES ??"
 	if constraint.OnDelete != "" {
@@ -354,7 +1160,8 @@ func buildConstraint(constraint *schema.Constraint) (sql string, results []inter
 
 func (m Migrator) getRawDDL(table string) (string, error) {
 	var createSQL string
-	m.DB.Raw("SELECT sql FROM sqlite_master WHERE type = ? AND tbl_name = ? AND name = ?", "table", table, table).Row().Scan(&createSQL)
+	schema, table := splitQualifiedTable(table)
+	m.DB.Raw("SELECT sql FROM "+masterTable(schema)+" WHERE type = ? AND name = ? COLLATE NOCASE", "table", table).Row().Scan(&createSQL)
 
 	if m.DB.Error != nil {
 		return "", m.DB.Error
@@ -362,20 +1169,75 @@ func (m Migrator) getRawDDL(table string) (string, error) {
 	return createSQL, nil
 }
 
+// getIndexSQLs returns the DDL of table's indexes that are still valid for columns, so they can be re-created after a table rebuild
+func (m Migrator) getIndexSQLs(table string, columns []string) ([]string, error) {
+	var sqls, results []string
+	schema, table := splitQualifiedTable(table)
+	if err := m.DB.Raw("SELECT sql FROM "+masterTable(schema)+" WHERE type = ? AND tbl_name = ? COLLATE NOCASE AND sql IS NOT NULL", "index", table).Scan(&sqls).Error; err != nil {
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+	for _, column := range columns {
+		existing[strings.ToLower(unquoteIdentifier(column))] = true
+	}
+
+	for _, sql := range sqls {
+		idx, err := parseIndexDDL(sql)
+		if err != nil {
+			return nil, err
+		}
+
+		valid := true
+		for _, column := range idx.columns {
+			if name, ok := indexColumnName(column); ok && !existing[strings.ToLower(name)] {
+				valid = false
+				break
+			}
+		}
+
+		if valid {
+			if schema != "" {
+				if sql, err = renameIndexDDL(sql, schema, ""); err != nil {
+					return nil, err
+				}
+			}
+			results = append(results, sql)
+		}
+	}
+	return results, nil
+}
+
 func (m Migrator) recreateTable(value interface{}, tablePtr *string,
+	getCreateSQL func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error)) error {
+	return m.RunWithoutForeignKey(func() error {
+		return m.rebuildTable(value, tablePtr, nil, getCreateSQL)
+	})
+}
+
+// rebuildTable re-creates the table of value with the DDL of getCreateSQL and copies its data, renamed maps
+// old column names to new ones for columns renamed by the new DDL
+func (m Migrator) rebuildTable(value interface{}, tablePtr *string, renamed map[string]string,
 	getCreateSQL func(rawDDL string, stmt *gorm.Statement) (sql string, sqlArgs []interface{}, err error)) error {
 	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
-		table := stmt.Table
+		table := statementTable(stmt)
 		if tablePtr != nil {
 			table = *tablePtr
 		}
 
-		rawDDL, err := m.getRawDDL(table)
+		schema, name := splitQualifiedTable(table)
+		newTableName := qualifyName(schema, name+"__temp")
+
+		// the schema version read before the DDL detects changes of other connections until the rebuild locks the database
+		schemaVersion, err := m.schemaVersion(m.DB, schema)
 		if err != nil {
 			return err
 		}
 
-		newTableName := table + "__temp"
+		rawDDL, err := m.getRawDDL(table)
+		if err != nil {
+			return err
+		}
 
 		createSQL, sqlArgs, err := getCreateSQL(rawDDL, stmt)
 		if err != nil {
@@ -385,34 +1247,156 @@ func (m Migrator) recreateTable(value interface{}, tablePtr *string,
 			return nil
 		}
 
-		tableReg, err := regexp.Compile(" ('|`|\"| )" + table + "('|`|\"| ) ")
+		createDDL, err := parseDDL(createSQL)
 		if err != nil {
 			return err
 		}
-		createSQL = tableReg.ReplaceAllString(createSQL, fmt.Sprintf(" `%v` ", newTableName))
+		columns := createDDL.getColumns()
+		selectColumns := make([]string, len(columns))
+		copy(selectColumns, columns)
+		for oldName, newName := range renamed {
+			for i, column := range columns {
+				if strings.EqualFold(unquoteIdentifier(column), newName) {
+					selectColumns[i] = quoteIdentifier(oldName)
+				}
+			}
+		}
 
-		createDDL, err := parseDDL(createSQL)
+		createSQL = strings.Replace(createSQL, createDDL.head, "CREATE TABLE "+m.DB.Statement.Quote(newTableName), 1)
+
+		indexSQLs, err := m.getIndexSQLs(table, selectColumns)
 		if err != nil {
 			return err
 		}
-		columns := createDDL.getColumns()
+		triggerSQLs, err := m.getTriggerSQLs(table)
+		if err != nil {
+			return err
+		}
+		for oldName, newName := range renamed {
+			for i, sql := range indexSQLs {
+				indexSQLs[i] = renameIdentifier(sql, oldName, newName)
+			}
+			for i, sql := range triggerSQLs {
+				triggerSQLs[i] = renameTriggerColumn(sql, oldName, newName)
+			}
+		}
+
+		checkForeignKeys := m.foreignKeysEnforced()
+		return m.exclusiveTransaction(func(tx *gorm.DB) error {
+			if version, err := m.schemaVersion(tx, schema); err != nil {
+				return err
+			} else if version != schemaVersion {
+				return fmt.Errorf("%w: rebuilding table %v, schema version %d changed to %d", ErrSchemaChanged, table, schemaVersion, version)
+			}
+
+			// views referring to the table are invalid between dropping the table and renaming the new one,
+			// which fails the schema check of ALTER TABLE ... RENAME TO unless it runs in legacy mode
+			if err := tx.Exec("PRAGMA legacy_alter_table = ON").Error; err != nil {
+				return err
+			}
+			defer tx.Exec("PRAGMA legacy_alter_table = OFF")
 
-		return m.DB.Transaction(func(tx *gorm.DB) error {
 			if err := tx.Exec(createSQL, sqlArgs...).Error; err != nil {
 				return err
 			}
 
 			queries := []string{
-				fmt.Sprintf("INSERT INTO `%v`(%v) SELECT %v FROM `%v`", newTableName, strings.Join(columns, ","), strings.Join(columns, ","), table),
-				fmt.Sprintf("DROP TABLE `%v`", table),
-				fmt.Sprintf("ALTER TABLE `%v` RENAME TO `%v`", newTableName, table),
+				fmt.Sprintf("INSERT INTO %v(%v) SELECT %v FROM %v", tx.Statement.Quote(newTableName), strings.Join(columns, ","), strings.Join(selectColumns, ","), tx.Statement.Quote(table)),
+				fmt.Sprintf("DROP TABLE %v", tx.Statement.Quote(table)),
+				// the new name of ALTER TABLE ... RENAME TO can't be schema qualified
+				fmt.Sprintf("ALTER TABLE %v RENAME TO %v", tx.Statement.Quote(newTableName), quoteIdentifier(name)),
 			}
+			queries = append(queries, indexSQLs...)
+			queries = append(queries, triggerSQLs...)
 			for _, query := range queries {
 				if err := tx.Exec(query).Error; err != nil {
 					return err
 				}
 			}
+
+			if checkForeignKeys {
+				return m.checkForeignKeys(tx, schema, "rebuilding table "+table)
+			}
 			return nil
 		})
 	})
 }
+
+// exclusiveTransaction runs fc in an exclusive transaction when m.DB is pinned to a connection, so other connections
+// can't change the schema while fc rebuilds a table, otherwise in a transaction or savepoint of m.DB
+func (m Migrator) exclusiveTransaction(fc func(tx *gorm.DB) error) (err error) {
+	if _, ok := m.DB.Statement.ConnPool.(*sql.Conn); !ok {
+		return m.DB.Transaction(fc)
+	}
+
+	tx := m.DB.Session(&gorm.Session{})
+	if err := tx.Exec("BEGIN EXCLUSIVE").Error; err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil || err != nil {
+			tx.Exec("ROLLBACK")
+			if r != nil {
+				panic(r)
+			}
+		}
+	}()
+
+	if err = fc(tx); err == nil {
+		err = tx.Exec("COMMIT").Error
+	}
+	return
+}
+
+// schemaVersion returns the schema version of schema, SQLite increments it on every schema change
+func (m Migrator) schemaVersion(db *gorm.DB, schema string) (version int64, err error) {
+	err = db.Raw(fmt.Sprintf("PRAGMA %s.schema_version", quoteIdentifier(schemaName(schema)))).Row().Scan(&version)
+	return
+}
+
+// checkForeignKeys returns an error for the first row of schema violating a foreign key after operation, e.g. a table rebuild
+func (m Migrator) checkForeignKeys(tx *gorm.DB, schema, operation string) error {
+	rows, err := tx.Raw(fmt.Sprintf("PRAGMA %s.foreign_key_check", quoteIdentifier(schemaName(schema)))).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var (
+			child, parent string
+			rowID         sql.NullInt64
+			id            int
+		)
+		if err := rows.Scan(&child, &rowID, &parent, &id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %v, row %v of %v refers to a missing row of %v", ErrForeignKeyViolation, operation, rowID.Int64, child, parent)
+	}
+	return rows.Err()
+}
+
+// statementTable returns the table of stmt, schema qualified for tables of attached databases,
+// gorm keeps only the table part of a qualified model table name in stmt.Table
+func statementTable(stmt *gorm.Statement) string {
+	if stmt.TableExpr != nil && stmt.Schema != nil && strings.HasSuffix(stmt.Schema.Table, "."+stmt.Table) {
+		return stmt.Schema.Table
+	}
+	return stmt.Table
+}
+
+// config returns the dialector config of m
+func (m Migrator) config() Config {
+	if dialector, ok := asDialector(m.Dialector); ok {
+		return dialector.Config
+	}
+	return Config{}
+}
+
+// version returns the SQLite version detected by the dialector when it was initialized
+func (m Migrator) version() (string, error) {
+	if dialector, ok := asDialector(m.Dialector); ok && dialector.version != "" {
+		return dialector.version, nil
+	}
+	return "", ErrUnknownVersion
+}
@@ -0,0 +1,794 @@
+package sqlite
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	return db
+}
+
+// tempDir returns a temporary directory removed once the test finished, like t.TempDir of go 1.15
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gorm-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestGetIndexes(t *testing.T) {
+	db := openTestDB(t, "get_indexes")
+
+	for _, sql := range []string{
+		"CREATE TABLE `users` (`id` integer,`name` text,`email` text,`age` integer,`deleted` numeric,PRIMARY KEY (`id`),UNIQUE (`name`))",
+		"CREATE UNIQUE INDEX `idx_users_email_age` ON `users`(`email`,`age` DESC)",
+		"CREATE INDEX `idx_users_active` ON `users`(`name`) WHERE deleted = 0",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("failed to execute %v: %v", sql, err)
+		}
+	}
+
+	indexes, err := db.Migrator().(Migrator).GetIndexes("users")
+	if err != nil {
+		t.Fatalf("failed to get indexes: %v", err)
+	}
+
+	byName := map[string]Index{}
+	for _, idx := range indexes {
+		assert.Equal(t, "users", idx.Table())
+		byName[idx.Name()] = idx
+	}
+	assert.Len(t, byName, 3)
+
+	composite := byName["idx_users_email_age"]
+	assert.Equal(t, []string{"email", "age"}, composite.Columns())
+	unique, _ := composite.Unique()
+	assert.True(t, unique)
+	_, partial := composite.Where()
+	assert.False(t, partial)
+
+	active := byName["idx_users_active"]
+	where, partial := active.Where()
+	assert.True(t, partial)
+	assert.Equal(t, "deleted = 0", where)
+	unique, _ = active.Unique()
+	assert.False(t, unique)
+
+	autoIndex := byName["sqlite_autoindex_users_1"]
+	assert.Equal(t, []string{"name"}, autoIndex.Columns())
+	primaryKey, _ := autoIndex.PrimaryKey()
+	assert.False(t, primaryKey)
+}
+
+func TestPartialIndex(t *testing.T) {
+	type Member struct {
+		ID      uint
+		Email   string `gorm:"index:idx_members_email,unique,where:deleted = 0"`
+		Name    string
+		Deleted bool
+	}
+
+	db := openTestDB(t, "partial_index")
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&Member{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+
+	if err := db.Migrator().AlterColumn(&Member{}, "Name"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+
+	indexes, err := db.Migrator().(Migrator).GetIndexes(&Member{})
+	if assert.NoError(t, err) && assert.Len(t, indexes, 1) {
+		where, ok := indexes[0].Where()
+		assert.True(t, ok)
+		assert.Equal(t, "deleted = 0", where)
+	}
+
+	if err := db.Migrator().DropColumn(&Member{}, "Email"); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+	assert.False(t, db.Migrator().HasIndex(&Member{}, "idx_members_email"))
+}
+
+func TestExpressionIndex(t *testing.T) {
+	type Account struct {
+		ID    uint
+		Email string `gorm:"index:idx_accounts_email,unique,expression:lower(email)"`
+		Data  string `gorm:"index:idx_accounts_data_id,expression:substr(data\\,1\\,8)"`
+	}
+
+	db := openTestDB(t, "expression_index")
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&Account{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+
+	if err := db.Create(&Account{Email: "Jinzhu@example.com"}).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if err := db.Create(&Account{Email: "jinzhu@EXAMPLE.com"}).Error; err == nil {
+		t.Errorf("Expected unique expression index to reject case-insensitive duplicate")
+	}
+
+	indexes, err := db.Migrator().(Migrator).GetIndexes(&Account{})
+	if err != nil {
+		t.Fatalf("failed to get indexes: %v", err)
+	}
+
+	columns := map[string][]string{}
+	for _, idx := range indexes {
+		columns[idx.Name()] = idx.Columns()
+	}
+	assert.Equal(t, []string{"lower(email)"}, columns["idx_accounts_email"])
+	assert.Equal(t, []string{"substr(data,1,8)"}, columns["idx_accounts_data_id"])
+
+	if err := db.Migrator().AlterColumn(&Account{}, "Email"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	assert.True(t, db.Migrator().HasIndex(&Account{}, "idx_accounts_email"))
+	assert.True(t, db.Migrator().HasIndex(&Account{}, "idx_accounts_data_id"))
+}
+
+func TestCompositeUniqueIndex(t *testing.T) {
+	type Member struct {
+		ID    uint
+		OrgID uint   `gorm:"uniqueIndex:idx_members_org_email"`
+		Email string `gorm:"uniqueIndex:idx_members_org_email"`
+		Name  string
+	}
+
+	for _, ddl := range []string{
+		"CREATE TABLE `members` (`id` integer PRIMARY KEY,`org_id` integer,`email` text,`name` text,UNIQUE (`org_id`, `email`))",
+		"CREATE TABLE `members` (`id` integer PRIMARY KEY,`org_id` integer,`email` text,`name` text,CONSTRAINT `uq_org_email` UNIQUE (org_id,EMAIL))",
+		"CREATE TABLE `members` (`id` integer PRIMARY KEY,`org_id` integer,`email` text,`name` text);CREATE UNIQUE INDEX `members_org_email` ON `members`(`org_id`,`email`)",
+	} {
+		db := openTestDB(t, "composite_unique_index")
+		db.Migrator().DropTable(&Member{})
+		if err := db.Exec(ddl).Error; err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := db.AutoMigrate(&Member{}); err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+		}
+		assert.True(t, db.Migrator().HasIndex(&Member{}, "idx_members_org_email"))
+
+		indexes, err := db.Migrator().(Migrator).GetIndexes(&Member{})
+		if err != nil {
+			t.Fatalf("failed to get indexes: %v", err)
+		}
+		assert.Len(t, indexes, 1, "duplicate index for %v", ddl)
+	}
+
+	// unique indexes on other columns don't count
+	db := openTestDB(t, "composite_unique_index_other")
+	if err := db.Exec("CREATE TABLE `members` (`id` integer PRIMARY KEY,`org_id` integer,`email` text,`name` text,UNIQUE (`email`, `org_id`))").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if err := db.AutoMigrate(&Member{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	indexes, err := db.Migrator().(Migrator).GetIndexes(&Member{})
+	if err != nil {
+		t.Fatalf("failed to get indexes: %v", err)
+	}
+	assert.Len(t, indexes, 2)
+}
+
+func TestForeignKeys(t *testing.T) {
+	type Company struct {
+		ID   uint
+		Name string
+	}
+	type Employee struct {
+		ID        uint
+		Name      string
+		CompanyID uint
+		Company   Company `gorm:"constraint:OnDelete:CASCADE"`
+	}
+
+	db, err := gorm.Open(New("file:foreign_keys?mode=memory&cache=shared", Config{ForeignKeys: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Company{}, &Employee{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	if err := db.Create(&Employee{Name: "orphan", CompanyID: 42}).Error; err == nil {
+		t.Errorf("Expected foreign key violation when foreign keys are enabled")
+	}
+
+	foreignKeys, err := db.Migrator().(Migrator).GetForeignKeys(&Employee{})
+	if err != nil {
+		t.Fatalf("failed to get foreign keys: %v", err)
+	}
+	if assert.Len(t, foreignKeys, 1) {
+		assert.Equal(t, "companies", foreignKeys[0].ReferenceTable)
+		assert.Equal(t, []string{"company_id"}, foreignKeys[0].Columns)
+		assert.Equal(t, []string{"id"}, foreignKeys[0].References)
+		assert.Equal(t, "CASCADE", foreignKeys[0].OnDelete)
+	}
+
+	assert.True(t, db.Migrator().HasConstraint(&Employee{}, "Company"))
+	assert.True(t, db.Migrator().HasConstraint(&Employee{}, "fk_employees_company"))
+
+	if err := db.Migrator().DropConstraint(&Employee{}, "Company"); err != nil {
+		t.Fatalf("failed to drop constraint: %v", err)
+	}
+	assert.False(t, db.Migrator().HasConstraint(&Employee{}, "Company"))
+}
+
+func TestConstraintRebuild(t *testing.T) {
+	type Owner struct {
+		ID   uint
+		Name string
+	}
+	type Pet struct {
+		ID      uint
+		Name    string
+		Age     int
+		OwnerID uint
+		Owner   Owner `gorm:"constraint:OnDelete:CASCADE"`
+	}
+
+	db, err := gorm.Open(New("file:constraint_rebuild?mode=memory&cache=shared", Config{ForeignKeys: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Owner{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE `pets` (`id` integer PRIMARY KEY,`name` text,`age` integer,`owner_id` integer REFERENCES `owners`(`id`) ON DELETE CASCADE,CHECK (age >= 0),CONSTRAINT `chk_name` CHECK (name <> ''))").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	owner := Owner{Name: "jinzhu"}
+	db.Create(&owner)
+	db.Create(&Pet{Name: "pet", OwnerID: owner.ID})
+
+	// rebuilding the referenced table must not cascade deletes into pets
+	if err := db.Migrator().AlterColumn(&Owner{}, "Name"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	var count int64
+	db.Model(&Pet{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	if err := db.Migrator().DropConstraint(&Pet{}, "Owner"); err != nil {
+		t.Fatalf("failed to drop unnamed foreign key: %v", err)
+	}
+	assert.False(t, db.Migrator().HasConstraint(&Pet{}, "Owner"))
+
+	if err := db.Migrator().(Migrator).RenameConstraint(&Pet{}, "chk_name", "chk_pet_name"); err != nil {
+		t.Fatalf("failed to rename constraint: %v", err)
+	}
+	assert.False(t, db.Migrator().HasConstraint(&Pet{}, "chk_name"))
+	assert.True(t, db.Migrator().HasConstraint(&Pet{}, "chk_pet_name"))
+
+	if err := db.Migrator().DropConstraint(&Pet{}, "chk_pet_name"); err != nil {
+		t.Fatalf("failed to drop check: %v", err)
+	}
+	if err := db.Migrator().DropConstraint(&Pet{}, "chk_pet_name"); err == nil {
+		t.Errorf("Expected dropping a missing constraint to fail")
+	}
+
+	if err := db.Create(&Pet{Name: "negative", Age: -1}).Error; err == nil {
+		t.Errorf("Expected unnamed check to be kept")
+	}
+	db.Model(&Pet{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestColumnTypes(t *testing.T) {
+	db := openTestDB(t, "column_types")
+	if err := db.Exec("CREATE TABLE `products` (" +
+		"`id` integer PRIMARY KEY, -- row id\n" +
+		"`code` varchar(32) NOT NULL COLLATE NOCASE UNIQUE,\n" +
+		"`price` decimal(10, 2) CHECK (price >= 0) DEFAULT 1.5,\n" +
+		"`name` text DEFAULT \"unnamed\" /* display name */,\n" +
+		"`note` text)").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	columnTypes, err := db.Migrator().ColumnTypes("products")
+	if err != nil {
+		t.Fatalf("failed to get column types: %v", err)
+	}
+
+	type expected struct {
+		name, dataType, defaultValue string
+		primaryKey, nullable, unique bool
+	}
+	expects := []expected{
+		{"id", "integer", "", true, false, false},
+		{"code", "varchar(32)", "", false, false, true},
+		{"price", "decimal(10, 2)", "1.5", false, true, false},
+		{"name", "text", "unnamed", false, true, false},
+		{"note", "text", "", false, true, false},
+	}
+
+	if assert.Len(t, columnTypes, len(expects)) {
+		for idx, e := range expects {
+			c := columnTypes[idx]
+			assert.Equal(t, e.name, c.Name())
+			assert.Equal(t, e.dataType, c.DatabaseTypeName())
+			primaryKey, _ := c.PrimaryKey()
+			assert.Equal(t, e.primaryKey, primaryKey, e.name)
+			nullable, _ := c.Nullable()
+			assert.Equal(t, e.nullable, nullable, e.name)
+			unique, _ := c.Unique()
+			assert.Equal(t, e.unique, unique, e.name)
+			defaultValue, _ := c.DefaultValue()
+			assert.Equal(t, e.defaultValue, defaultValue, e.name)
+		}
+
+		autoIncrement, _ := columnTypes[0].AutoIncrement()
+		assert.True(t, autoIncrement)
+	}
+}
+
+func TestComments(t *testing.T) {
+	type Post struct {
+		ID    uint
+		Title string `gorm:"comment:the post's title, shown in lists"`
+		Body  string
+	}
+
+	type PostV2 struct {
+		ID     uint
+		Title  string `gorm:"comment:headline (max 80 chars)"`
+		Body   string
+		Rating int `gorm:"comment:-- stars"`
+	}
+
+	db, err := gorm.Open(New("file:comments?mode=memory&cache=shared", Config{Comments: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Post{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&Post{Title: "hello", Body: "world"})
+
+	comments := func() map[string]string {
+		columnTypes, err := db.Migrator().ColumnTypes("posts")
+		if err != nil {
+			t.Fatalf("failed to get column types: %v", err)
+		}
+		results := map[string]string{}
+		for _, columnType := range columnTypes {
+			comment, ok := columnType.Comment()
+			assert.True(t, ok)
+			results[columnType.Name()] = comment
+		}
+		return results
+	}
+	assert.Equal(t, map[string]string{"id": "", "title": "the post's title, shown in lists", "body": ""}, comments())
+
+	var rawDDL string
+	db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "posts").Scan(&rawDDL)
+	if err := db.AutoMigrate(&Post{}); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+	var newDDL string
+	db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "posts").Scan(&newDDL)
+	assert.Equal(t, rawDDL, newDDL, "unchanged comments should not rebuild the table")
+
+	if err := db.Table("posts").AutoMigrate(&PostV2{}); err != nil {
+		t.Fatalf("failed to migrate changed comments: %v", err)
+	}
+	assert.Equal(t, map[string]string{"id": "", "title": "headline (max 80 chars)", "body": "", "rating": "-- stars"}, comments())
+
+	if err := db.Table("posts").Migrator().DropColumn(&PostV2{}, "Title"); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+	assert.Equal(t, map[string]string{"id": "", "body": "", "rating": "-- stars"}, comments())
+
+	var post PostV2
+	if err := db.Table("posts").First(&post).Error; err != nil || post.Body != "world" {
+		t.Errorf("Expected data to survive the rebuilds, got %+v, %v", post, err)
+	}
+}
+
+func TestRenameColumn(t *testing.T) {
+	db := openTestDB(t, "rename_column")
+
+	for _, sql := range []string{
+		"CREATE TABLE `users` (`id` integer,`name` text,`age` integer,PRIMARY KEY (`id`),UNIQUE (`name`,`age`))",
+		"CREATE INDEX `idx_users_name` ON `users`(`name`)",
+		"INSERT INTO `users` (`name`,`age`) VALUES ('jinzhu', 18)",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("failed to execute %v: %v", sql, err)
+		}
+	}
+
+	// native ALTER TABLE ... RENAME COLUMN
+	m := db.Migrator().(Migrator)
+	if err := m.RenameColumn("users", "name", "nickname"); err != nil {
+		t.Fatalf("failed to rename column: %v", err)
+	}
+	assert.True(t, m.HasColumn("users", "nickname"))
+	assert.False(t, m.HasColumn("users", "name"))
+
+	// table rebuild of SQLite before 3.25
+	m.Dialector = Dialector{version: "3.24.0"}
+	if err := m.RenameColumn("users", "nickname", "username"); err != nil {
+		t.Fatalf("failed to rename column by rebuilding the table: %v", err)
+	}
+	assert.True(t, m.HasColumn("users", "username"))
+	assert.False(t, m.HasColumn("users", "nickname"))
+	if err := m.RenameColumn("users", "nickname", "username"); err == nil {
+		t.Errorf("Expected renaming a missing column to fail")
+	}
+
+	indexes, err := m.GetIndexes("users")
+	if err != nil {
+		t.Fatalf("failed to get indexes: %v", err)
+	}
+	byName := map[string]Index{}
+	for _, idx := range indexes {
+		byName[idx.Name()] = idx
+	}
+	assert.Equal(t, []string{"username"}, byName["idx_users_name"].Columns())
+	assert.Equal(t, []string{"username", "age"}, byName["sqlite_autoindex_users_1"].Columns())
+
+	var name string
+	if err := db.Raw("SELECT `username` FROM `users` WHERE `age` = 18").Scan(&name).Error; err != nil || name != "jinzhu" {
+		t.Errorf("Expected data to survive the rename, got %v, %v", name, err)
+	}
+}
+
+func TestDropColumn(t *testing.T) {
+	db := openTestDB(t, "drop_column")
+
+	for _, sql := range []string{
+		"CREATE TABLE `users` (`id` integer,`name` text,`age` integer,`nickname` text,`email` text,`score` integer,`bonus` integer GENERATED ALWAYS AS (`score` * 2),PRIMARY KEY (`id`))",
+		"CREATE INDEX `idx_users_name` ON `users`(`name`)",
+		"CREATE VIEW `adults` AS SELECT `id`, `email` FROM `users` WHERE `age` >= 18",
+		"INSERT INTO `users` (`name`,`age`,`nickname`,`email`,`score`) VALUES ('jinzhu', 18, 'jz', 'jinzhu@example.org', 10)",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("failed to execute %v: %v", sql, err)
+		}
+	}
+
+	m := db.Migrator().(Migrator)
+	dependencies, err := m.dropColumnDependencies("users", "name")
+	if err != nil {
+		t.Fatalf("failed to get dependencies: %v", err)
+	}
+	assert.Equal(t, []string{"index idx_users_name"}, dependencies)
+	dependencies, _ = m.dropColumnDependencies("users", "score")
+	assert.Equal(t, []string{"generated column bonus"}, dependencies)
+	dependencies, _ = m.dropColumnDependencies("users", "age")
+	assert.Equal(t, []string{"view adults"}, dependencies)
+	dependencies, _ = m.dropColumnDependencies("users", "nickname")
+	assert.Empty(t, dependencies)
+
+	if err := m.DropColumn("users", "nickname"); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+	assert.False(t, m.HasColumn("users", "nickname"))
+
+	strict := m
+	strict.Dialector = Dialector{Config: Config{DisableDropColumnRebuild: true}, version: "3.35.0"}
+	if err := strict.DropColumn("users", "name"); !errors.Is(err, ErrColumnHasDependencies) || !strings.Contains(err.Error(), "index idx_users_name") {
+		t.Errorf("Expected dropping an indexed column without rebuild to fail, got %v", err)
+	}
+	assert.True(t, m.HasColumn("users", "name"))
+
+	if err := m.DropColumn("users", "name"); err != nil {
+		t.Fatalf("failed to drop indexed column: %v", err)
+	}
+	assert.False(t, m.HasColumn("users", "name"))
+	assert.False(t, m.HasIndex("users", "idx_users_name"))
+
+	if err := m.DropColumn("users", "missing"); err == nil {
+		t.Errorf("Expected dropping a missing column to fail")
+	}
+
+	var email string
+	if err := db.Raw("SELECT `email` FROM `adults`").Scan(&email).Error; err != nil || email != "jinzhu@example.org" {
+		t.Errorf("Expected data to survive dropping columns, got %v, %v", email, err)
+	}
+}
+
+func TestAutoIncrement(t *testing.T) {
+	type Post struct {
+		ID    uint
+		Title string
+	}
+
+	type Translation struct {
+		ID     uint   `gorm:"primaryKey"`
+		Locale string `gorm:"primaryKey"`
+		Title  string
+	}
+
+	type CompositeCounter struct {
+		ID     uint   `gorm:"primaryKey;autoIncrement"`
+		Locale string `gorm:"primaryKey"`
+	}
+
+	type SecondCounter struct {
+		ID  uint
+		Seq uint `gorm:"autoIncrement"`
+	}
+
+	type TextCounter struct {
+		Code string `gorm:"primaryKey;autoIncrement"`
+	}
+
+	db := openTestDB(t, "auto_increment")
+	m := db.Migrator().(Migrator)
+	if err := db.AutoMigrate(&Post{}, &Translation{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	ddl, _ := m.getRawDDL("posts")
+	assert.Equal(t, `CREATE TABLE "posts" ("id" integer,"title" text,PRIMARY KEY ("id"))`, ddl)
+	ddl, _ = m.getRawDDL("translations")
+	assert.Equal(t, `CREATE TABLE "translations" ("id" integer,"locale" text,"title" text,PRIMARY KEY ("id","locale"))`, ddl)
+
+	for _, value := range []interface{}{&CompositeCounter{}, &SecondCounter{}, &TextCounter{}} {
+		if err := db.Migrator().CreateTable(value); !errors.Is(err, ErrUnsupportedAutoIncrement) {
+			t.Errorf("Expected creating %T to fail with ErrUnsupportedAutoIncrement, got %v", value, err)
+		}
+	}
+
+	db, err := gorm.Open(New("file:autoincrement_keyword?mode=memory&cache=shared", Config{Autoincrement: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Post{}, &Translation{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	ddl, _ = db.Migrator().(Migrator).getRawDDL("posts")
+	assert.Equal(t, `CREATE TABLE "posts" ("id" integer PRIMARY KEY AUTOINCREMENT,"title" text)`, ddl)
+	ddl, _ = db.Migrator().(Migrator).getRawDDL("translations")
+	assert.Equal(t, `CREATE TABLE "translations" ("id" integer,"locale" text,"title" text,PRIMARY KEY ("id","locale"))`, ddl)
+
+	// a second migration must not see any changes
+	if err := db.AutoMigrate(&Post{}); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+
+	post := Post{Title: "first"}
+	db.Create(&post)
+	db.Delete(&post)
+	next := Post{Title: "second"}
+	db.Create(&next)
+	assert.Equal(t, post.ID+1, next.ID, "ids of deleted rows must not be reused")
+}
+
+func TestRebuildTableChecks(t *testing.T) {
+	type Team struct {
+		ID   uint
+		Name string
+	}
+	type Player struct {
+		ID     uint
+		Name   string
+		TeamID uint
+		Team   Team
+	}
+
+	db, err := gorm.Open(New(filepath.Join(tempDir(t), "rebuild.db"), Config{ForeignKeys: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Team{}, &Player{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&Player{Name: "jinzhu", Team: Team{Name: "gorm"}})
+
+	// another connection changes the schema after the rebuild read the DDL
+	m := db.Migrator().(Migrator)
+	err = m.recreateTable(&Player{}, nil, func(rawDDL string, stmt *gorm.Statement) (string, []interface{}, error) {
+		if err := db.Exec("CREATE TABLE concurrent (id integer)").Error; err != nil {
+			return "", nil, err
+		}
+		return rawDDL, nil, nil
+	})
+	assert.True(t, errors.Is(err, ErrSchemaChanged), "got %v", err)
+	assert.True(t, m.HasTable(&Player{}))
+	assert.False(t, m.HasTable("players__temp"))
+
+	// rows violating enforced foreign keys abort the rebuild
+	err = db.Connection(func(tx *gorm.DB) error {
+		tx = tx.Session(&gorm.Session{})
+		tx.Exec("PRAGMA foreign_keys = OFF")
+		defer tx.Exec("PRAGMA foreign_keys = ON")
+		return tx.Create(&Player{Name: "orphan", TeamID: 42}).Error
+	})
+	assert.NoError(t, err)
+	err = db.Migrator().AlterColumn(&Player{}, "Name")
+	assert.True(t, errors.Is(err, ErrForeignKeyViolation), "got %v", err)
+	assert.Contains(t, err.Error(), "players refers to a missing row of teams")
+
+	var count int64
+	db.Model(&Player{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	db.Where("name = ?", "orphan").Delete(&Player{})
+	assert.NoError(t, db.Migrator().AlterColumn(&Player{}, "Name"))
+}
+
+func TestAutoMigrateTransaction(t *testing.T) {
+	type Book struct {
+		ID    uint
+		Title string
+	}
+	type Publisher struct {
+		ID   uint
+		Name string
+	}
+
+	db, err := gorm.Open(New(filepath.Join(tempDir(t), "migrate.db"), Config{ForeignKeys: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Book{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&[]Book{{Title: "gorm"}, {Title: "gorm"}})
+
+	// the unique index fails on duplicated titles, the publishers table is rolled back
+	{
+		type Book struct {
+			ID    uint
+			Title string `gorm:"uniqueIndex"`
+		}
+		assert.Error(t, db.AutoMigrate(&Publisher{}, &Book{}))
+	}
+	assert.False(t, db.Migrator().HasTable(&Publisher{}))
+	assert.True(t, db.Migrator().HasTable(&Book{}))
+
+	// rows violating foreign keys roll back the migration
+	type Shelf struct {
+		ID   uint
+		Name string
+	}
+	type ShelfBook struct {
+		ID      uint
+		ShelfID uint
+		Shelf   Shelf
+	}
+	db.Exec(`CREATE TABLE "shelf_books" ("id" integer PRIMARY KEY, "shelf_id" integer)`)
+	db.Exec(`INSERT INTO "shelf_books" ("shelf_id") VALUES (42)`)
+	err = db.AutoMigrate(&Shelf{}, &ShelfBook{})
+	assert.True(t, errors.Is(err, ErrForeignKeyViolation), "got %v", err)
+	assert.Contains(t, err.Error(), "row 1 of shelf_books refers to a missing row of shelves")
+	assert.False(t, db.Migrator().HasTable(&Shelf{}))
+}
+
+func TestDefaultExpressions(t *testing.T) {
+	type Event struct {
+		ID        uint
+		Name      string    `gorm:"default:it's"`
+		Keyword   string    `gorm:"default:CURRENT_TIMESTAMP"`
+		Stamp     string    `gorm:"default:(CURRENT_TIMESTAMP)"`
+		Day       string    `gorm:"default:(current_date)"`
+		CreatedAt time.Time `gorm:"default:current_timestamp"`
+		Unix      int64     `gorm:"default:(strftime('%s','now'))"`
+		Lowercase string    `gorm:"default:lower('ABC')"`
+	}
+
+	db := openTestDB(t, "default_expressions")
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&Event{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+
+	rawDDL, _ := db.Migrator().(Migrator).getRawDDL("events")
+	assert.Contains(t, rawDDL, `"name" text DEFAULT 'it''s'`)
+	assert.Contains(t, rawDDL, `"keyword" text DEFAULT 'CURRENT_TIMESTAMP'`)
+	assert.Contains(t, rawDDL, `"stamp" text DEFAULT (CURRENT_TIMESTAMP)`)
+	assert.Contains(t, rawDDL, `"day" text DEFAULT (current_date)`)
+	assert.Contains(t, rawDDL, `"created_at" datetime DEFAULT current_timestamp`)
+	assert.Contains(t, rawDDL, `"unix" integer DEFAULT (strftime('%s','now'))`)
+	assert.Contains(t, rawDDL, `"lowercase" text DEFAULT (lower('ABC'))`)
+
+	plan, err := db.Migrator().(Migrator).PlanAutoMigrate(&Event{})
+	assert.NoError(t, err)
+	assert.Empty(t, plan)
+
+	event := Event{}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	var result Event
+	db.First(&result, event.ID)
+	assert.Equal(t, "it's", result.Name)
+	assert.Equal(t, "CURRENT_TIMESTAMP", result.Keyword)
+	assert.False(t, result.CreatedAt.IsZero())
+	assert.Len(t, result.Stamp, len("2006-01-02 15:04:05"))
+	assert.Equal(t, result.Stamp[:10], result.Day)
+	assert.Greater(t, result.Unix, int64(0))
+	assert.Equal(t, "abc", result.Lowercase)
+
+	// expression defaults survive rebuilds of other columns
+	if err := db.Migrator().AlterColumn(&Event{}, "Name"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	rawDDL, _ = db.Migrator().(Migrator).getRawDDL("events")
+	assert.Contains(t, rawDDL, `"unix" integer DEFAULT (strftime('%s','now'))`)
+
+	columnTypes, _ := db.Migrator().ColumnTypes(&Event{})
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "unix" {
+			defaultValue, _ := columnType.DefaultValue()
+			assert.Equal(t, "(strftime('%s','now'))", defaultValue)
+		}
+	}
+}
+
+func TestCaseInsensitiveIdentifiers(t *testing.T) {
+	type CaseUser struct {
+		ID    uint
+		Name  string
+		Email string `gorm:"index:idx_case_users_email"`
+		Age   int    `gorm:"check:chk_case_users_age,age >= 0"`
+		Note  string
+	}
+
+	db := openTestDB(t, "case_insensitive")
+	for _, sql := range []string{
+		"CREATE TABLE \"Case_Users\" (\"ID\" integer PRIMARY KEY, [Name] text, `EMAIL` text, Age integer, CONSTRAINT [CHK_Case_Users_Age] CHECK (age >= 0))",
+		"CREATE INDEX \"IDX_Case_Users_Email\" ON \"Case_Users\"(`EMAIL`)",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("failed to execute %v: %v", sql, err)
+		}
+	}
+
+	m := db.Migrator()
+	assert.True(t, m.HasTable(&CaseUser{}))
+	assert.True(t, m.HasTable("`case_users`"))
+	assert.True(t, m.HasColumn(&CaseUser{}, "Name"))
+	assert.True(t, m.HasColumn(&CaseUser{}, "email"))
+	assert.True(t, m.HasColumn(&CaseUser{}, `"EMAIL"`))
+	assert.False(t, m.HasColumn(&CaseUser{}, "note"))
+	assert.True(t, m.HasIndex(&CaseUser{}, "idx_case_users_email"))
+	assert.True(t, m.HasIndex(&CaseUser{}, "[IDX_CASE_USERS_EMAIL]"))
+	assert.True(t, m.HasConstraint(&CaseUser{}, "chk_case_users_age"))
+	assert.False(t, m.HasConstraint(&CaseUser{}, "chk_case_users_name"))
+
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&CaseUser{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+	assert.True(t, m.HasColumn(&CaseUser{}, "note"))
+
+	var indexes int
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'Case_Users'").Scan(&indexes)
+	assert.Equal(t, 1, indexes)
+
+	assert.NoError(t, db.Create(&CaseUser{Name: "jinzhu", Email: "jinzhu@example.com", Age: 18}).Error)
+}
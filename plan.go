@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+var savePointRegexp = regexp.MustCompile(`(?i)^\s*(?:SAVEPOINT|RELEASE|ROLLBACK)\b`)
+
+// PlanAutoMigrate returns the statements AutoMigrate would run for values without changing the database.
+// AutoMigrate runs in a transaction that is rolled back, so every statement is planned against the schema changed
+// by the previous ones. Variables are written as SQL literals, pragmas of table rebuilds are kept and their savepoints left out
+func (m Migrator) PlanAutoMigrate(values ...interface{}) (plan []string, err error) {
+	err = m.DB.Connection(func(conn *gorm.DB) error {
+		// like RunWithoutForeignKey, rebuilding a table drops it, which foreign keys may forbid
+		var enabled int
+		conn.Raw("PRAGMA foreign_keys").Scan(&enabled)
+		if enabled == 1 {
+			if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+				return err
+			}
+			defer conn.Exec("PRAGMA foreign_keys = ON")
+		}
+
+		tx := conn.Begin()
+		if tx.Error != nil {
+			return tx.Error
+		}
+		defer tx.Rollback()
+
+		ctx := tx.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		// a session with its own statement, so the recording pool isn't used to roll back
+		planDB := tx.Session(&gorm.Session{Context: ctx})
+		pool := &planPool{ConnPool: tx.Statement.ConnPool, format: m.config().TimeFormat}
+		planDB.Statement.ConnPool = pool
+
+		if err := planDB.Migrator().AutoMigrate(values...); err != nil {
+			return err
+		}
+		plan = pool.statements
+		return nil
+	})
+	return
+}
+
+// planPool records the statements executed with ConnPool, a transaction
+type planPool struct {
+	gorm.ConnPool
+	format     TimeFormat
+	statements []string
+}
+
+func (p *planPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !savePointRegexp.MatchString(query) {
+		statement, err := inlineVars(query, args, p.format)
+		if err != nil {
+			return nil, err
+		}
+		p.statements = append(p.statements, statement)
+	}
+	return p.ConnPool.ExecContext(ctx, query, args...)
+}
+
+// Commit and Rollback make nested transactions use savepoints of the planned transaction
+func (p *planPool) Commit() error {
+	if committer, ok := p.ConnPool.(gorm.TxCommitter); ok {
+		return committer.Commit()
+	}
+	return gorm.ErrInvalidTransaction
+}
+
+func (p *planPool) Rollback() error {
+	if committer, ok := p.ConnPool.(gorm.TxCommitter); ok {
+		return committer.Rollback()
+	}
+	return gorm.ErrInvalidTransaction
+}
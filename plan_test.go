@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanAutoMigrate(t *testing.T) {
+	type Author struct {
+		ID   uint
+		Name string `gorm:"index"`
+		Age  int
+	}
+
+	type Book struct {
+		ID    uint
+		Title string
+	}
+
+	db := openTestDB(t, "plan_auto_migrate")
+	if err := db.Exec("CREATE TABLE `authors` (`id` integer,`name` text,PRIMARY KEY (`id`))").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	m := db.Migrator().(Migrator)
+	plan, err := m.PlanAutoMigrate(&Author{}, &Book{})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	assert.Equal(t, []string{
+		`ALTER TABLE "authors" ADD "age" integer`,
+		`CREATE INDEX "idx_authors_name" ON "authors"("name")`,
+		`CREATE TABLE "books" ("id" integer,"title" text,PRIMARY KEY ("id"))`,
+	}, plan)
+
+	// planning doesn't change the database
+	assert.False(t, m.HasColumn(&Author{}, "Age"))
+	assert.False(t, m.HasIndex(&Author{}, "Name"))
+	assert.False(t, m.HasTable(&Book{}))
+
+	if err := db.AutoMigrate(&Author{}, &Book{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	plan, err = m.PlanAutoMigrate(&Author{}, &Book{})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	assert.Empty(t, plan)
+
+	// making a column not null plans a table rebuild
+	type Book2 struct {
+		ID    uint
+		Title string `gorm:"not null"`
+	}
+	plan, err = db.Table("books").Migrator().(Migrator).PlanAutoMigrate(&Book2{})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	assert.Contains(t, plan, `CREATE TABLE "books__temp" ("id" integer,"title" text NOT NULL,PRIMARY KEY ("id"))`)
+	for _, statement := range plan {
+		assert.NotRegexp(t, `^(?i)(SAVEPOINT|RELEASE)`, statement)
+	}
+	columnTypes, _ := m.ColumnTypes(&Book{})
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "title" {
+			nullable, _ := columnType.Nullable()
+			assert.True(t, nullable)
+		}
+	}
+}
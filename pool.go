@@ -0,0 +1,268 @@
+package sqlite
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// defaultPoolMaxOpen tenant databases a pool keeps open by default
+const defaultPoolMaxOpen = 100
+
+// PoolConfig settings of a Pool
+type PoolConfig struct {
+	// Dir directory of the tenant databases, tenant t is stored in Dir/t.db
+	Dir string
+	// DSN returns the DSN of tenant instead of a file in Dir, e.g. to add URI parameters
+	DSN func(tenant string) string
+	// Config dialector config shared by all tenant databases
+	Config Config
+	// GormConfig gorm config of the tenant databases, each database gets a copy
+	GormConfig *gorm.Config
+	// MaxOpen tenant databases kept open, the least recently used idle ones are closed beyond it, 100 by default
+	MaxOpen int
+	// MaxConnsPerTenant limits the pooled connections of each tenant database, 0 keeps database/sql's defaults
+	MaxConnsPerTenant int
+	// Models migrated with AutoMigrate when the pool opens a tenant database for the first time
+	Models []interface{}
+}
+
+// Pool manages the databases of many tenants, e.g. a database file per customer, databases are opened on first use
+// and the least recently used idle ones are closed, so thousands of tenants don't exhaust file descriptors
+type Pool struct {
+	config PoolConfig
+
+	mu       sync.Mutex
+	entries  map[string]*poolEntry
+	lru      *list.List
+	migrated map[string]bool
+	closed   bool
+}
+
+type poolEntry struct {
+	tenant string
+	db     *gorm.DB
+	err    error
+	// ready is closed once db is opened and migrated
+	ready chan struct{}
+	// refs acquired handles, entries in use are never closed by the LRU
+	refs int
+	elem *list.Element
+}
+
+// NewPool returns a pool of tenant databases, no database is opened until it is acquired
+func NewPool(config PoolConfig) *Pool {
+	if config.MaxOpen <= 0 {
+		config.MaxOpen = defaultPoolMaxOpen
+	}
+	return &Pool{config: config, entries: map[string]*poolEntry{}, lru: list.New(), migrated: map[string]bool{}}
+}
+
+// Acquire returns the database of tenant, opening it if needed, the database stays open until release is called,
+// e.g.
+//
+//	db, release, err := pool.Acquire("acme")
+//	if err != nil {
+//		return err
+//	}
+//	defer release()
+func (p *Pool) Acquire(tenant string) (db *gorm.DB, release func(), err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, nil, ErrPoolClosed
+	}
+
+	entry, ok := p.entries[tenant]
+	if ok {
+		entry.refs++
+		p.lru.MoveToFront(entry.elem)
+		p.mu.Unlock()
+		<-entry.ready
+	} else {
+		entry = &poolEntry{tenant: tenant, ready: make(chan struct{}), refs: 1}
+		entry.elem = p.lru.PushFront(entry)
+		p.entries[tenant] = entry
+		p.mu.Unlock()
+
+		// other tenants are opened concurrently, callers of the same tenant wait for ready
+		entry.db, entry.err = p.open(tenant)
+		close(entry.ready)
+		p.evict()
+	}
+
+	if entry.err != nil {
+		p.release(entry)
+		return nil, nil, entry.err
+	}
+
+	var once sync.Once
+	return entry.db, func() { once.Do(func() { p.release(entry) }) }, nil
+}
+
+// Do runs fc with the database of tenant, the database stays open until fc returns
+func (p *Pool) Do(tenant string, fc func(db *gorm.DB) error) error {
+	db, release, err := p.Acquire(tenant)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fc(db)
+}
+
+// Len returns the number of open tenant databases
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lru.Len()
+}
+
+// Close closes all tenant databases, including acquired ones, Acquire fails with ErrPoolClosed afterwards
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	entries := make([]*poolEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		entries = append(entries, entry)
+	}
+	p.entries, p.lru = map[string]*poolEntry{}, list.New()
+	p.mu.Unlock()
+
+	var err error
+	for _, entry := range entries {
+		<-entry.ready
+		if closeErr := entry.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// dsn returns the DSN of tenant
+func (p *Pool) dsn(tenant string) (string, error) {
+	if p.config.DSN != nil {
+		return p.config.DSN(tenant), nil
+	}
+	if tenant == "" || tenant == "." || tenant == ".." || filepath.Base(tenant) != tenant {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, tenant)
+	}
+	return filepath.Join(p.config.Dir, tenant+".db"), nil
+}
+
+// open opens the database of tenant and migrates it the first time
+func (p *Pool) open(tenant string) (*gorm.DB, error) {
+	dsn, err := p.dsn(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	gormConfig := &gorm.Config{}
+	if p.config.GormConfig != nil {
+		config := *p.config.GormConfig
+		gormConfig = &config
+	}
+	db, err := gorm.Open(New(dsn, p.config.Config), gormConfig)
+	if err != nil {
+		if db != nil {
+			closeDB(db)
+		}
+		return nil, fmt.Errorf("sqlite: failed to open database of tenant %v: %w", tenant, err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		closeDB(db)
+		return nil, err
+	}
+	if n := p.config.MaxConnsPerTenant; n > 0 {
+		sqlDB.SetMaxOpenConns(n)
+		sqlDB.SetMaxIdleConns(n)
+	}
+
+	p.mu.Lock()
+	migrated := p.migrated[tenant]
+	p.mu.Unlock()
+	if !migrated && len(p.config.Models) > 0 {
+		if err := db.AutoMigrate(p.config.Models...); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("sqlite: failed to migrate database of tenant %v: %w", tenant, err)
+		}
+	}
+
+	p.mu.Lock()
+	p.migrated[tenant] = true
+	p.mu.Unlock()
+	return db, nil
+}
+
+// release releases a handle of entry, failed entries are dropped once released by all their callers
+func (p *Pool) release(entry *poolEntry) {
+	p.mu.Lock()
+	entry.refs--
+	if entry.err != nil && entry.refs == 0 && p.entries[entry.tenant] == entry {
+		delete(p.entries, entry.tenant)
+		p.lru.Remove(entry.elem)
+	}
+	p.mu.Unlock()
+
+	p.evict()
+}
+
+// evict closes the least recently used idle databases beyond MaxOpen
+func (p *Pool) evict() {
+	var closing []*poolEntry
+
+	p.mu.Lock()
+	for elem := p.lru.Back(); elem != nil && p.lru.Len() > p.config.MaxOpen; {
+		prev := elem.Prev()
+		if idle := elem.Value.(*poolEntry); idle.refs == 0 {
+			delete(p.entries, idle.tenant)
+			p.lru.Remove(elem)
+			closing = append(closing, idle)
+		}
+		elem = prev
+	}
+	p.mu.Unlock()
+
+	for _, idle := range closing {
+		idle.close()
+	}
+}
+
+// closeDB closes the database opened for db, also when db.DB() fails, e.g. because a plugin wrapped its ConnPool,
+// the statement of db keeps the ConnPool of the dialector
+func closeDB(db *gorm.DB) {
+	pools := []gorm.ConnPool{db.ConnPool}
+	if db.Statement != nil {
+		pools = append(pools, db.Statement.ConnPool)
+	}
+
+	for _, pool := range pools {
+		if connector, ok := pool.(gorm.GetDBConnector); ok {
+			if sqlDB, err := connector.GetDBConn(); err == nil {
+				sqlDB.Close()
+				return
+			}
+		}
+		if closer, ok := pool.(io.Closer); ok {
+			closer.Close()
+			return
+		}
+	}
+}
+
+// close closes the database of entry, if it was opened
+func (e *poolEntry) close() error {
+	if e.db == nil {
+		return nil
+	}
+	sqlDB, err := e.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
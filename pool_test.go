@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type TenantNote struct {
+	ID   uint
+	Body string
+}
+
+func TestPool(t *testing.T) {
+	dir := tempDir(t)
+	pool := NewPool(PoolConfig{
+		Dir:               dir,
+		Config:            Config{ForeignKeys: true},
+		GormConfig:        &gorm.Config{Logger: logger.Discard},
+		MaxOpen:           2,
+		MaxConnsPerTenant: 1,
+		Models:            []interface{}{&TenantNote{}},
+	})
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				err := pool.Do(tenant, func(db *gorm.DB) error {
+					return db.Create(&TenantNote{Body: tenant}).Error
+				})
+				assert.NoError(t, err)
+			}(fmt.Sprintf("tenant%d", i))
+		}
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, pool.Len(), 2)
+	for i := 0; i < 5; i++ {
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("tenant%d.db", i)))
+		assert.NoError(t, err)
+	}
+
+	// acquired databases stay open beyond MaxOpen
+	db0, release0, err := pool.Acquire("tenant0")
+	if err != nil {
+		t.Fatalf("failed to acquire tenant: %v", err)
+	}
+	db1, release1, _ := pool.Acquire("tenant1")
+	_, release2, _ := pool.Acquire("tenant2")
+	assert.Equal(t, 3, pool.Len())
+
+	var count int64
+	assert.NoError(t, db0.Model(&TenantNote{}).Where("body = ?", "tenant0").Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	release0()
+	release0()
+	assert.Equal(t, 2, pool.Len())
+
+	assert.NoError(t, db1.Model(&TenantNote{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	release1()
+	release2()
+
+	// tenants are migrated once, AutoMigrate doesn't run again after an eviction
+	db0, release0, err = pool.Acquire("tenant0")
+	if err != nil {
+		t.Fatalf("failed to acquire tenant: %v", err)
+	}
+	assert.NoError(t, db0.Migrator().DropTable(&TenantNote{}))
+	release0()
+	for _, tenant := range []string{"tenant1", "tenant2"} {
+		assert.NoError(t, pool.Do(tenant, func(db *gorm.DB) error { return nil }))
+	}
+	assert.NoError(t, pool.Do("tenant0", func(db *gorm.DB) error {
+		assert.False(t, db.Migrator().HasTable(&TenantNote{}))
+		return nil
+	}))
+
+	for _, tenant := range []string{"", "..", "../escape", "a/b"} {
+		_, _, err := pool.Acquire(tenant)
+		assert.True(t, errors.Is(err, ErrInvalidTenant), tenant)
+	}
+
+	assert.NoError(t, pool.Close())
+	assert.Equal(t, 0, pool.Len())
+	_, _, err = pool.Acquire("tenant0")
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+}
+
+// wrapPoolPlugin wraps the ConnPool of a database, so db.DB() can't return it
+type wrapPoolPlugin struct {
+	opened []*sql.DB
+}
+
+func (p *wrapPoolPlugin) Name() string {
+	return "wrap_pool"
+}
+
+func (p *wrapPoolPlugin) Initialize(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	p.opened = append(p.opened, sqlDB)
+	db.ConnPool = struct{ gorm.ConnPool }{db.ConnPool}
+	return nil
+}
+
+func TestPoolClosesUnusableDatabase(t *testing.T) {
+	plugin := &wrapPoolPlugin{}
+	pool := NewPool(PoolConfig{
+		Dir:        tempDir(t),
+		GormConfig: &gorm.Config{Logger: logger.Discard, Plugins: map[string]gorm.Plugin{plugin.Name(): plugin}},
+	})
+	defer pool.Close()
+
+	err := pool.Do("tenant", func(db *gorm.DB) error { return nil })
+	assert.True(t, errors.Is(err, gorm.ErrInvalidDB), err)
+	if assert.Len(t, plugin.opened, 1) {
+		assert.Error(t, plugin.opened[0].Ping(), "the database is closed")
+	}
+}
@@ -0,0 +1,40 @@
+//go:build sqlite_preupdate_hook
+// +build sqlite_preupdate_hook
+
+package sqlite
+
+import (
+	"database/sql/driver"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// preUpdateHooks old values of changes are captured
+const preUpdateHooks = true
+
+// registerPreUpdateHook reports the old values of updated and deleted rows of conn to fc
+func registerPreUpdateHook(conn driver.Conn, fc func(update *preUpdate)) {
+	sqliteConn, ok := conn.(*sqlite3.SQLiteConn)
+	if !ok {
+		return
+	}
+
+	sqliteConn.RegisterPreUpdateHook(func(data sqlite3.SQLitePreUpdateData) {
+		if data.Op == sqlite3.SQLITE_INSERT {
+			fc(nil)
+			return
+		}
+
+		values := make([]interface{}, data.Count())
+		if err := data.Old(values...); err != nil {
+			fc(nil)
+			return
+		}
+		// the update hook reports the rowid a row is updated to
+		rowid := data.NewRowID
+		if data.Op == sqlite3.SQLITE_DELETE {
+			rowid = data.OldRowID
+		}
+		fc(&preUpdate{op: data.Op, db: data.DatabaseName, table: data.TableName, rowid: rowid, values: values})
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !sqlite_preupdate_hook
+// +build !sqlite_preupdate_hook
+
+package sqlite
+
+import "database/sql/driver"
+
+// preUpdateHooks old values of changes are captured
+const preUpdateHooks = false
+
+// registerPreUpdateHook does nothing, mattn/go-sqlite3 has pre-update hooks with the sqlite_preupdate_hook build tag
+func registerPreUpdateHook(conn driver.Conn, fc func(update *preUpdate)) {}
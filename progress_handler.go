@@ -0,0 +1,99 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+/*
+#include <stdint.h>
+
+// declared instead of included, SQLite is linked by github.com/mattn/go-sqlite3
+void sqlite3_progress_handler(void*, int, int(*)(void*), void*);
+
+extern int progressHandlerCallback(uintptr_t);
+
+static int progress_handler(void *handle) {
+	return progressHandlerCallback((uintptr_t)handle);
+}
+
+static void set_progress_handler(void *db, int steps, uintptr_t handle) {
+	if (steps < 1) {
+		sqlite3_progress_handler(db, 0, 0, 0);
+	} else {
+		sqlite3_progress_handler(db, steps, progress_handler, (void*)handle);
+	}
+}
+*/
+import "C"
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// progressHandlers callbacks of the registered progress handlers by their handle passed to SQLite
+var progressHandlers = struct {
+	sync.Mutex
+	last      uintptr
+	callbacks map[uintptr]func() bool
+}{callbacks: map[uintptr]func() bool{}}
+
+//export progressHandlerCallback
+func progressHandlerCallback(handle C.uintptr_t) C.int {
+	progressHandlers.Lock()
+	callback := progressHandlers.callbacks[uintptr(handle)]
+	progressHandlers.Unlock()
+
+	if callback != nil && callback() {
+		return 1
+	}
+	return 0
+}
+
+// progressHandler registers progress handlers of a mattn/go-sqlite3 connection, the driver doesn't, so
+// sqlite3_progress_handler is called with the database handle of the connection
+type progressHandler struct {
+	db     unsafe.Pointer
+	handle uintptr
+}
+
+// sqliteProgressHandler returns the progress handler registerer of conn, false if it isn't a mattn/go-sqlite3 connection
+func sqliteProgressHandler(conn driver.Conn) (ProgressHandlerRegisterer, bool) {
+	sqliteConn, ok := conn.(*sqlite3.SQLiteConn)
+	if !ok || sqliteConn == nil {
+		return nil, false
+	}
+
+	// the database handle is the unexported field db of SQLiteConn
+	field := reflect.ValueOf(sqliteConn).Elem().FieldByName("db")
+	if !field.IsValid() || field.Kind() != reflect.Ptr {
+		return nil, false
+	}
+	db := *(*unsafe.Pointer)(unsafe.Pointer(field.UnsafeAddr()))
+	if db == nil {
+		return nil, false
+	}
+	return &progressHandler{db: db}, true
+}
+
+func (h *progressHandler) RegisterProgressHandler(steps int, callback func() bool) {
+	progressHandlers.Lock()
+	defer progressHandlers.Unlock()
+
+	if h.handle != 0 {
+		delete(progressHandlers.callbacks, h.handle)
+		h.handle = 0
+	}
+	if steps < 1 || callback == nil {
+		C.set_progress_handler(h.db, 0, 0)
+		return
+	}
+
+	progressHandlers.last++
+	h.handle = progressHandlers.last
+	progressHandlers.callbacks[h.handle] = callback
+	C.set_progress_handler(h.db, C.int(steps), C.uintptr_t(h.handle))
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package sqlite
+
+import "database/sql/driver"
+
+// sqliteProgressHandler returns false, connections of mattn/go-sqlite3 need cgo to register progress handlers
+func sqliteProgressHandler(conn driver.Conn) (ProgressHandlerRegisterer, bool) {
+	return nil, false
+}
@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	queryPlanStepRegexp  = regexp.MustCompile(`(?i)^(SCAN|SEARCH)\s+(?:TABLE\s+)?(CONSTANT\s+ROW|SUBQUERY\s+\d+|\S+)(?:\s+AS\s+\S+)?(?:\s+USING\s+(.+?))?(?:\s+\(.*\))?$`)
+	queryPlanIndexRegexp = regexp.MustCompile(`(?i)\bINDEX\s+([^\s(]\S*)`)
+)
+
+// QueryPlanNode a step of a query plan, https://www.sqlite.org/eqp.html
+type QueryPlanNode struct {
+	ID     int
+	Parent int
+	// Detail description of the step, e.g. SEARCH users USING INDEX idx_users_name (name=?)
+	Detail string
+	// Scan the step reads a whole table or index, Search the step looks rows up with an index or the primary key
+	Scan   bool
+	Search bool
+	// Table table or subquery read by a scan or search step
+	Table string
+	// Index index used by the step, empty for automatic indexes and the primary key
+	Index string
+	// CoveringIndex the index has all columns the step needs, so the table isn't read
+	CoveringIndex bool
+	// AutomaticIndex the step uses a transient index SQLite builds while running the query
+	AutomaticIndex bool
+	// PrimaryKey the step looks rows up by the rowid or primary key
+	PrimaryKey bool
+	// TempBTree the step sorts or de-duplicates rows with a temporary b-tree, e.g. USE TEMP B-TREE FOR ORDER BY
+	TempBTree bool
+	Children  []*QueryPlanNode
+}
+
+// QueryPlan top level steps of a query plan
+type QueryPlan []*QueryPlanNode
+
+// String formats the plan like the sqlite3 shell
+func (plan QueryPlan) String() string {
+	var builder strings.Builder
+	builder.WriteString("QUERY PLAN")
+
+	var write func(nodes []*QueryPlanNode, prefix string)
+	write = func(nodes []*QueryPlanNode, prefix string) {
+		for i, node := range nodes {
+			branch, indent := "|--", "|  "
+			if i == len(nodes)-1 {
+				branch, indent = "`--", "   "
+			}
+			builder.WriteString("\n" + prefix + branch + node.Detail)
+			write(node.Children, prefix+indent)
+		}
+	}
+	write(plan, "")
+	return builder.String()
+}
+
+// ExplainQueryPlan returns the query plan SQLite uses to run query with args
+func ExplainQueryPlan(db *gorm.DB, query string, args ...interface{}) (QueryPlan, error) {
+	rows, err := db.Raw("EXPLAIN QUERY PLAN "+query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		plan  QueryPlan
+		nodes = map[int]*QueryPlanNode{}
+	)
+	for rows.Next() {
+		var (
+			node    = &QueryPlanNode{}
+			notUsed int
+		)
+		if err := rows.Scan(&node.ID, &node.Parent, &notUsed, &node.Detail); err != nil {
+			return nil, err
+		}
+		parseQueryPlanDetail(node)
+
+		nodes[node.ID] = node
+		if parent, ok := nodes[node.Parent]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			plan = append(plan, node)
+		}
+	}
+	return plan, rows.Err()
+}
+
+func parseQueryPlanDetail(node *QueryPlanNode) {
+	detail := strings.ToUpper(node.Detail)
+	node.TempBTree = strings.Contains(detail, "TEMP B-TREE")
+
+	matches := queryPlanStepRegexp.FindStringSubmatch(node.Detail)
+	if matches == nil {
+		return
+	}
+
+	node.Scan = strings.EqualFold(matches[1], "SCAN")
+	node.Search = !node.Scan
+	if upper := strings.ToUpper(matches[2]); !strings.HasPrefix(upper, "CONSTANT") && !strings.HasPrefix(upper, "SUBQUERY") {
+		node.Table = matches[2]
+	}
+
+	using := strings.ToUpper(matches[3])
+	node.CoveringIndex = strings.Contains(using, "COVERING INDEX")
+	node.AutomaticIndex = strings.Contains(using, "AUTOMATIC")
+	node.PrimaryKey = strings.Contains(using, "PRIMARY KEY")
+	if index := queryPlanIndexRegexp.FindStringSubmatch(matches[3]); index != nil && !node.AutomaticIndex {
+		node.Index = index[1]
+	}
+}
+
+// QueryPlanLogger a gorm plugin logging the query plans of queries slower than SlowThreshold, e.g.
+// db.Use(&QueryPlanLogger{SlowThreshold: 100 * time.Millisecond}), it explains the queries of Find, First and the like
+type QueryPlanLogger struct {
+	SlowThreshold time.Duration
+	// Log receives the plans of slow queries, defaults to a warning of the db's logger
+	Log func(ctx context.Context, sql string, elapsed time.Duration, plan QueryPlan)
+}
+
+const queryPlanStartKey = "sqlite:query_plan_start"
+
+func (l *QueryPlanLogger) Name() string {
+	return "sqlite:query_plan_logger"
+}
+
+func (l *QueryPlanLogger) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("sqlite:query_plan_start", func(db *gorm.DB) {
+		db.InstanceSet(queryPlanStartKey, time.Now())
+	}); err != nil {
+		return err
+	}
+	return db.Callback().Query().After("gorm:query").Register("sqlite:query_plan", l.explain)
+}
+
+func (l *QueryPlanLogger) explain(db *gorm.DB) {
+	start, ok := db.InstanceGet(queryPlanStartKey)
+	if !ok || db.Error != nil || db.DryRun || db.Statement.SQL.Len() == 0 {
+		return
+	}
+	elapsed := time.Since(start.(time.Time))
+	if elapsed < l.SlowThreshold {
+		return
+	}
+
+	sql := db.Statement.SQL.String()
+	plan, err := ExplainQueryPlan(db.Session(&gorm.Session{NewDB: true}), sql, db.Statement.Vars...)
+	if err != nil {
+		db.Logger.Error(db.Statement.Context, "failed to explain query plan of %s: %v", sql, err)
+		return
+	}
+
+	if l.Log != nil {
+		l.Log(db.Statement.Context, sql, elapsed, plan)
+	} else {
+		db.Logger.Warn(db.Statement.Context, "slow query %v: %s\n%v", elapsed, sql, plan)
+	}
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestExplainQueryPlan(t *testing.T) {
+	type PlanUser struct {
+		ID   uint
+		Name string `gorm:"index"`
+		Age  int
+	}
+
+	db, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&PlanUser{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	plan, err := ExplainQueryPlan(db, "SELECT * FROM plan_users WHERE name = ?", "jinzhu")
+	if err != nil {
+		t.Fatalf("failed to explain: %v", err)
+	}
+	if assert.Len(t, plan, 1) {
+		assert.True(t, plan[0].Search)
+		assert.Equal(t, "plan_users", plan[0].Table)
+		assert.Equal(t, "idx_plan_users_name", plan[0].Index)
+		assert.False(t, plan[0].CoveringIndex)
+	}
+
+	plan, _ = ExplainQueryPlan(db, "SELECT name FROM plan_users WHERE name > ?", "a")
+	if assert.Len(t, plan, 1) {
+		assert.True(t, plan[0].CoveringIndex)
+		assert.Equal(t, "idx_plan_users_name", plan[0].Index)
+	}
+
+	plan, _ = ExplainQueryPlan(db, "SELECT * FROM plan_users WHERE id = ?", 1)
+	if assert.Len(t, plan, 1) {
+		assert.True(t, plan[0].Search)
+		assert.True(t, plan[0].PrimaryKey)
+		assert.Empty(t, plan[0].Index)
+	}
+
+	plan, _ = ExplainQueryPlan(db, "SELECT * FROM plan_users ORDER BY age")
+	if assert.Len(t, plan, 2) {
+		assert.True(t, plan[0].Scan)
+		assert.Equal(t, "plan_users", plan[0].Table)
+		assert.True(t, plan[1].TempBTree)
+	}
+
+	plan, _ = ExplainQueryPlan(db, "SELECT * FROM plan_users WHERE age IN (SELECT age FROM plan_users WHERE name = ?)", "jinzhu")
+	if assert.Len(t, plan, 2) && assert.Len(t, plan[1].Children, 1) {
+		assert.Equal(t, "idx_plan_users_name", plan[1].Children[0].Index)
+	}
+	assert.Contains(t, plan.String(), "QUERY PLAN\n|--")
+
+	var logged []string
+	db.Use(&QueryPlanLogger{Log: func(ctx context.Context, sql string, elapsed time.Duration, plan QueryPlan) {
+		logged = append(logged, plan.String())
+	}})
+	var users []PlanUser
+	db.Where("name = ?", "jinzhu").Find(&users)
+	if assert.Len(t, logged, 1) {
+		assert.Contains(t, logged[0], "USING INDEX idx_plan_users_name")
+	}
+}
+
+func TestParseQueryPlanDetail(t *testing.T) {
+	params := []struct {
+		detail   string
+		expected QueryPlanNode
+	}{
+		{"SCAN TABLE users AS u", QueryPlanNode{Scan: true, Table: "users"}},
+		{"SCAN users USING COVERING INDEX idx_users_name", QueryPlanNode{Scan: true, Table: "users", Index: "idx_users_name", CoveringIndex: true}},
+		{"SEARCH users USING AUTOMATIC COVERING INDEX (name=?)", QueryPlanNode{Search: true, Table: "users", CoveringIndex: true, AutomaticIndex: true}},
+		{"SEARCH users USING PRIMARY KEY (id=?)", QueryPlanNode{Search: true, Table: "users", PrimaryKey: true}},
+		{"SCAN CONSTANT ROW", QueryPlanNode{Scan: true}},
+		{"SCAN SUBQUERY 1", QueryPlanNode{Scan: true}},
+		{"USE TEMP B-TREE FOR ORDER BY", QueryPlanNode{TempBTree: true}},
+	}
+
+	for _, p := range params {
+		node := QueryPlanNode{Detail: p.detail}
+		parseQueryPlanDetail(&node)
+		p.expected.Detail = p.detail
+		assert.Equal(t, p.expected, node, p.detail)
+	}
+}
@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// OpenReadOnly opens the database file path read only, e.g. a prebuilt dataset, write statements fail with ErrReadOnly.
+// Set Config.Immutable for files on read-only file systems
+func OpenReadOnly(path string, config ...Config) gorm.Dialector {
+	dialector := &Dialector{DSN: path}
+	if len(config) > 0 {
+		dialector.Config = config[0]
+	}
+	dialector.ReadOnly = true
+	return dialector
+}
+
+// readOnlyDSN returns dsn as an URI opening the database read only with mode=ro, and with immutable=1 if immutable,
+// other parameters are kept
+func readOnlyDSN(dsn string, immutable bool) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+
+	path, params := dsn, []string{}
+	if idx := strings.IndexByte(dsn, '?'); idx >= 0 {
+		path = dsn[:idx]
+		for _, param := range strings.Split(dsn[idx+1:], "&") {
+			if key := strings.SplitN(param, "=", 2)[0]; param != "" && key != "mode" && key != "immutable" {
+				params = append(params, param)
+			}
+		}
+	}
+
+	params = append(params, "mode=ro")
+	if immutable {
+		params = append(params, "immutable=1")
+	}
+	return path + "?" + strings.Join(params, "&")
+}
+
+// isReadOnlyQuery reports whether query can run on a read only database: reads, transaction control, detaching
+// databases, pragmas reading values and VACUUM INTO. ATTACH is rejected, it creates missing database files
+func isReadOnlyQuery(query string) bool {
+	for _, tokens := range sqlStatements(query) {
+		if isWriteStatement(tokens) && !isReadOnlyStatement(tokens) {
+			return false
+		}
+	}
+	return true
+}
+
+// isReadOnlyStatement reports whether the statement of tokens, which isn't a read, can run on a read only database
+func isReadOnlyStatement(tokens []string) bool {
+	switch tokens[0] {
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE", "DETACH":
+		return true
+	case "PRAGMA":
+		return isReadOnlyPragma(tokens[1:])
+	case "VACUUM":
+		// VACUUM INTO or VACUUM schema INTO writes a copy of the database
+		return len(tokens) > 1 && tokens[1] == "INTO" || len(tokens) > 2 && tokens[2] == "INTO"
+	}
+	return false
+}
+
+var (
+	// readOnlyPragmas pragmas reading values with an argument, e.g. PRAGMA table_info(users)
+	readOnlyPragmas = map[string]bool{
+		"TABLE_INFO": true, "TABLE_XINFO": true, "TABLE_LIST": true, "INDEX_INFO": true, "INDEX_XINFO": true,
+		"INDEX_LIST": true, "FOREIGN_KEY_LIST": true, "FOREIGN_KEY_CHECK": true, "INTEGRITY_CHECK": true, "QUICK_CHECK": true,
+	}
+	// writePragmas pragmas writing the database without an argument
+	writePragmas = map[string]bool{"OPTIMIZE": true, "INCREMENTAL_VACUUM": true, "WAL_CHECKPOINT": true}
+)
+
+// isReadOnlyPragma reports whether the pragma of tokens only reads, assigning a value with = or the function call
+// form like PRAGMA journal_mode(WAL) writes
+func isReadOnlyPragma(tokens []string) bool {
+	if len(tokens) > 2 && tokens[1] == "." {
+		// PRAGMA schema.name
+		tokens = tokens[2:]
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+
+	name, args := tokens[0], tokens[1:]
+	switch {
+	case len(args) == 0:
+		return !writePragmas[name]
+	case args[0] == "(":
+		return readOnlyPragmas[name]
+	}
+	return false
+}
+
+// readOnlyConn rejects write statements with ErrReadOnly before SQLite sees them, see Config.ReadOnly
+type readOnlyConn struct {
+	driver.Conn
+}
+
+// unwrap returns the driver connection
+func (c *readOnlyConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+func (c *readOnlyConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *readOnlyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, errNotImplemented
+}
+
+func (c *readOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if !isReadOnlyQuery(query) {
+		return nil, ErrReadOnly
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *readOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if !isReadOnlyQuery(query) {
+		return nil, ErrReadOnly
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext rejects write statements already when they are prepared, e.g. by gorm's PrepareStmt
+func (c *readOnlyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	if !isReadOnlyQuery(query) {
+		return nil, ErrReadOnly
+	}
+	return preparer.PrepareContext(ctx, query)
+}
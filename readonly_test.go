@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestReadOnlyDSN(t *testing.T) {
+	params := []struct {
+		dsn       string
+		immutable bool
+		expect    string
+	}{
+		{"data.db", false, "file:data.db?mode=ro"},
+		{"data.db", true, "file:data.db?mode=ro&immutable=1"},
+		{"file:data.db?cache=shared&mode=rwc", false, "file:data.db?cache=shared&mode=ro"},
+		{"file:data.db?immutable=0&_busy_timeout=100", true, "file:data.db?_busy_timeout=100&mode=ro&immutable=1"},
+	}
+	for _, p := range params {
+		assert.Equal(t, p.expect, readOnlyDSN(p.dsn, p.immutable))
+	}
+}
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	for _, query := range []string{
+		"SELECT * FROM users",
+		"WITH adults AS (SELECT * FROM users WHERE age >= 18) SELECT count(*) FROM adults",
+		"BEGIN", "COMMIT", "SAVEPOINT sp1", "RELEASE sp1",
+		"PRAGMA table_info(users)", "PRAGMA foreign_keys",
+		"VACUUM INTO '/tmp/copy.db'", "VACUUM main INTO ?",
+		"WITH t AS (SELECT replace(name, 'a', 'b') AS name FROM users) SELECT * FROM t",
+		"(SELECT 1)", "/* report */ SELECT 1", "-- report\nSELECT 1",
+		"PRAGMA main.table_info(users)", "PRAGMA integrity_check(users)", "PRAGMA journal_mode", "DETACH other",
+	} {
+		assert.True(t, isReadOnlyQuery(query), query)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO users (name) VALUES ('jinzhu')",
+		"WITH old AS (SELECT id FROM users) DELETE FROM users WHERE id IN old",
+		"CREATE TABLE books (id integer)",
+		"PRAGMA user_version = 2",
+		"VACUUM", "ANALYZE",
+		"PRAGMA journal_mode(WAL)", "PRAGMA main.journal_mode(WAL)", "PRAGMA optimize", "PRAGMA wal_checkpoint",
+		"ATTACH DATABASE 'other.db' AS other", "SELECT 1; DELETE FROM users",
+	} {
+		assert.False(t, isReadOnlyQuery(query), query)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	type Dataset struct {
+		ID   uint
+		Name string
+	}
+
+	path := filepath.Join(tempDir(t), "dataset.db")
+	db, err := gorm.Open(Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Dataset{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&Dataset{Name: "cities"})
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	for _, dialector := range []gorm.Dialector{OpenReadOnly(path), New(path, Config{Immutable: true})} {
+		db, err := gorm.Open(dialector, &gorm.Config{})
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+
+		var datasets []Dataset
+		assert.NoError(t, db.Find(&datasets).Error)
+		assert.Len(t, datasets, 1)
+		assert.NoError(t, db.AutoMigrate(&Dataset{}))
+		assert.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			return tx.First(&Dataset{}).Error
+		}))
+
+		err = db.Create(&Dataset{Name: "countries"}).Error
+		assert.True(t, errors.Is(err, ErrReadOnly), err)
+		err = db.Exec("DELETE FROM datasets").Error
+		assert.True(t, errors.Is(err, ErrReadOnly), err)
+		err = db.Session(&gorm.Session{PrepareStmt: true}).Model(&Dataset{}).Where("id = ?", 1).Update("name", "towns").Error
+		assert.True(t, errors.Is(err, ErrReadOnly), err)
+
+		var count int64
+		db.Model(&Dataset{}).Where("name = ?", "cities").Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"gorm.io/gorm/clause"
+)
+
+// maxCachedRegexps compiled patterns kept by the REGEXP function, the cache is cleared when it is full
+const maxCachedRegexps = 256
+
+var regexpCache = struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: map[string]*regexp.Regexp{}}
+
+// regexpFunction implements X REGEXP Y with Go's regexp syntax, SQLite runs it as regexp(Y, X)
+var regexpFunction = Function{Name: "regexp", Impl: regexpMatch, Pure: true}
+
+// Regexp returns a condition matching column against the Go regular expression pattern, it requires Config.Regexp,
+// e.g. db.Where(Regexp("name", "^jin")).Find(&users), which is the same as db.Where("name REGEXP ?", "^jin")
+func Regexp(column string, pattern string) clause.Expression {
+	return clause.Expr{SQL: "? REGEXP ?", Vars: []interface{}{clause.Column{Name: column}, pattern}}
+}
+
+// regexpMatch reports whether value matches pattern, numbers are matched as text and NULL never matches
+func regexpMatch(pattern string, value interface{}) (bool, error) {
+	var str string
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case []byte:
+		if v == nil {
+			return false, nil
+		}
+		str = string(v)
+	case string:
+		str = v
+	default:
+		str = fmt.Sprint(v)
+	}
+
+	regexpCache.Lock()
+	re, ok := regexpCache.patterns[pattern]
+	regexpCache.Unlock()
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
+
+		regexpCache.Lock()
+		if len(regexpCache.patterns) >= maxCachedRegexps {
+			regexpCache.patterns = map[string]*regexp.Regexp{}
+		}
+		regexpCache.patterns[pattern] = re
+		regexpCache.Unlock()
+	}
+	return re.MatchString(str), nil
+}
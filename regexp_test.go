@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRegexp(t *testing.T) {
+	type RegexpUser struct {
+		ID   uint
+		Name string
+		Code *int
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{Regexp: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&RegexpUser{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	code := 1234
+	db.Create(&[]RegexpUser{{Name: "jinzhu", Code: &code}, {Name: "Jinzhu2"}, {Name: "gorm"}})
+
+	var names []string
+	db.Model(&RegexpUser{}).Where("name REGEXP ?", "^jin").Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"jinzhu"}, names)
+
+	names = nil
+	db.Model(&RegexpUser{}).Where(Regexp("name", "(?i)^jin")).Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"jinzhu", "Jinzhu2"}, names)
+
+	// integers are matched as text, NULL never matches
+	names = nil
+	db.Model(&RegexpUser{}).Where(Regexp("code", `^\d{4}$`)).Pluck("name", &names)
+	assert.Equal(t, []string{"jinzhu"}, names)
+
+	assert.Error(t, db.Model(&RegexpUser{}).Where(Regexp("name", "(")).Pluck("name", &names).Error)
+
+	// REGEXP is opt-in
+	db, _ = gorm.Open(OpenInMemory(""), &gorm.Config{})
+	assert.Error(t, db.Exec("SELECT 'a' REGEXP 'a'").Error)
+}
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// TableRenamer is implemented by models whose table was renamed, AutoMigrate renames the first existing table
+// of RenamedFrom instead of creating an empty table, e.g.
+//
+//	func (Customer) RenamedFrom() []string {
+//		return []string{"clients"}
+//	}
+type TableRenamer interface {
+	RenamedFrom() []string
+}
+
+// renamedFrom returns the former names of the column of field, given by its renamedFrom tag, e.g.
+// `gorm:"renamedFrom:name,full_name"`
+func renamedFrom(field *schema.Field) (names []string) {
+	for _, name := range strings.Split(field.TagSettings["RENAMEDFROM"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// migrateRenames renames the table and columns of value from their former names, if they exist and the new
+// ones don't, so AutoMigrate keeps their data instead of adding new ones and leaving the old ones behind
+func (m Migrator) migrateRenames(value interface{}) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if renamer, ok := value.(TableRenamer); ok && !m.HasTable(value) {
+			schema, table := splitQualifiedTable(statementTable(stmt))
+			for _, oldName := range renamer.RenamedFrom() {
+				if !m.HasTable(qualifyName(schema, oldName)) {
+					continue
+				}
+				// the new name of ALTER TABLE ... RENAME TO can't be qualified, the table stays in its schema
+				if err := m.DB.Exec("ALTER TABLE ? RENAME TO ?", clause.Table{Name: qualifyName(schema, oldName)}, clause.Table{Name: quoteIdentifier(table)}).Error; err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		if stmt.Schema == nil || !m.HasTable(value) {
+			return nil
+		}
+		for _, field := range stmt.Schema.Fields {
+			oldNames := renamedFrom(field)
+			if len(oldNames) == 0 || field.DBName == "" || m.HasColumn(value, field.DBName) {
+				continue
+			}
+			for _, oldName := range oldNames {
+				if m.HasColumn(value, oldName) {
+					if err := m.RenameColumn(value, oldName, field.DBName); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type RenameClient struct {
+	ID   uint
+	Name string
+	Age  int
+}
+
+type RenameCustomer struct {
+	ID       uint
+	FullName string `gorm:"renamedFrom:name"`
+	Years    int    `gorm:"renamedFrom:age_in_years,age"`
+}
+
+func (RenameCustomer) RenamedFrom() []string {
+	return []string{"rename_clients"}
+}
+
+func TestAutoMigrateRenames(t *testing.T) {
+	db := openTestDB(t, "auto_migrate_renames")
+	if err := db.AutoMigrate(&RenameClient{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&RenameClient{Name: "jinzhu", Age: 18})
+
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&RenameCustomer{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+
+	assert.False(t, db.Migrator().HasTable("rename_clients"))
+	for _, column := range []string{"name", "age"} {
+		assert.False(t, db.Migrator().HasColumn(&RenameCustomer{}, column), "column %v should be renamed", column)
+	}
+
+	var customer RenameCustomer
+	if assert.NoError(t, db.First(&customer).Error) {
+		assert.Equal(t, RenameCustomer{ID: 1, FullName: "jinzhu", Years: 18}, customer)
+	}
+
+	// a new table is created when no former table exists
+	db.Migrator().DropTable(&RenameCustomer{})
+	if assert.NoError(t, db.AutoMigrate(&RenameCustomer{})) {
+		assert.True(t, db.Migrator().HasColumn(&RenameCustomer{}, "full_name"))
+	}
+}
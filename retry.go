@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// BusyRetry retries statements failing with SQLITE_BUSY or SQLITE_LOCKED. Statements of a transaction are not retried,
+// as SQLite may require the whole transaction to be rolled back, only beginning the transaction is, so transactions
+// begin with BEGIN IMMEDIATE unless the DSN sets _txlock. Errors returned while iterating query rows are not retried,
+// neither are single row queries like Row, a *sql.Row reports SQLITE_BUSY when it is scanned
+type BusyRetry struct {
+	// MaxAttempts attempts of a statement including the first one, retrying is disabled if it is less than 2
+	MaxAttempts int
+	// Backoff delay before the first retry, it doubles for every further retry
+	Backoff time.Duration
+}
+
+func (r BusyRetry) enabled() bool {
+	return r.MaxAttempts > 1
+}
+
+// do runs fc until it succeeds, fails with an error other than SQLITE_BUSY or SQLITE_LOCKED or MaxAttempts is reached
+func (r BusyRetry) do(ctx context.Context, fc func() error) (err error) {
+	backoff := r.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = fc(); err == nil || attempt >= r.MaxAttempts || !isBusyError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// immediateTxDSN makes transactions of dsn take the write lock when they begin, unless dsn sets _txlock
+func immediateTxDSN(dsn string) string {
+	if strings.Contains(dsn, "_txlock=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_txlock=immediate"
+	}
+	return dsn + "?_txlock=immediate"
+}
+
+// isBusyError returns err is caused by a locked database or table
+func isBusyError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// retryPool retries statements of ConnPool failing because the database is busy
+type retryPool struct {
+	gorm.ConnPool
+	retry BusyRetry
+}
+
+func (p *retryPool) ExecContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	err = p.retry.do(ctx, func() (err error) {
+		result, err = p.ConnPool.ExecContext(ctx, query, args...)
+		return err
+	})
+	return
+}
+
+func (p *retryPool) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	err = p.retry.do(ctx, func() (err error) {
+		rows, err = p.ConnPool.QueryContext(ctx, query, args...)
+		return err
+	})
+	return
+}
+
+func (p *retryPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	beginner, ok := p.ConnPool.(gorm.TxBeginner)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+
+	err = p.retry.do(ctx, func() (err error) {
+		tx, err = beginner.BeginTx(ctx, opts)
+		return err
+	})
+	return
+}
+
+func (p *retryPool) GetDBConn() (*sql.DB, error) {
+	if connector, ok := p.ConnPool.(gorm.GetDBConnector); ok && connector != nil {
+		return connector.GetDBConn()
+	}
+
+	if sqlDB, ok := p.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	return nil, gorm.ErrInvalidDB
+}
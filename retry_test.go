@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestBusyRetry(t *testing.T) {
+	type User struct {
+		ID   uint
+		Name string
+	}
+
+	dir, err := ioutil.TempDir("", "gorm-sqlite-retry")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// disable the driver's busy timeout, so a locked database fails right away
+	dsn := filepath.Join(dir, "gorm.db") + "?_busy_timeout=0"
+	retryDB, err := gorm.Open(New(dsn, Config{BusyRetry: BusyRetry{MaxAttempts: 20, Backoff: 10 * time.Millisecond}}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	noRetryDB, err := gorm.Open(Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := retryDB.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// hold the write lock with another connection
+	lockDB, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer lockDB.Close()
+
+	lock := func() *sql.Conn {
+		conn, err := lockDB.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get connection: %v", err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+			t.Fatalf("failed to lock database: %v", err)
+		}
+		return conn
+	}
+	unlock := func(conn *sql.Conn) {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		conn.Close()
+	}
+
+	conn := lock()
+	if err := noRetryDB.Create(&User{Name: "no-retry"}).Error; !isBusyError(err) {
+		t.Errorf("Expected busy error without retry, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock(conn)
+	}()
+	if err := retryDB.Create(&User{Name: "retry"}).Error; err != nil {
+		t.Errorf("Expected create to be retried until the lock is released, got %v", err)
+	}
+
+	conn = lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock(conn)
+	}()
+	if err := retryDB.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&User{Name: "tx"}).Error
+	}, &sql.TxOptions{}); err != nil {
+		t.Errorf("Expected transaction to succeed, got %v", err)
+	}
+
+	if _, err := retryDB.DB(); err != nil {
+		t.Errorf("Expected DB to return the underlying sql.DB, got %v", err)
+	}
+
+	var count int64
+	retryDB.Model(&User{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 users, got %v", count)
+	}
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RTreeConfig describes an R*Tree index table, https://www.sqlite.org/rtree.html
+//
+// The model's primary key is the integer id, the following fields are the min/max coordinate pairs
+// of each dimension in declaration order, fields tagged with `gorm:"rtree:aux"` are auxiliary columns.
+type RTreeConfig struct {
+	// Integer stores coordinates as 32-bit integers using rtree_i32
+	Integer bool
+}
+
+// RTreeTabler is implemented by models that are stored as rtree virtual tables
+type RTreeTabler interface {
+	RTreeTable() RTreeConfig
+}
+
+// RTreeRange a dimension of a bounding box, MinColumn and MaxColumn are the rtree columns holding the dimension
+type RTreeRange struct {
+	MinColumn string
+	MaxColumn string
+	Min       interface{}
+	Max       interface{}
+}
+
+// RTreeWithin matches entries whose bounding box is inside the given box
+func RTreeWithin(ranges ...RTreeRange) clause.Expression {
+	exprs := make([]clause.Expression, 0, len(ranges)*2)
+	for _, r := range ranges {
+		exprs = append(exprs,
+			clause.Gte{Column: clause.Column{Name: r.MinColumn}, Value: r.Min},
+			clause.Lte{Column: clause.Column{Name: r.MaxColumn}, Value: r.Max},
+		)
+	}
+	return clause.And(exprs...)
+}
+
+// RTreeOverlaps matches entries whose bounding box intersects the given box
+func RTreeOverlaps(ranges ...RTreeRange) clause.Expression {
+	exprs := make([]clause.Expression, 0, len(ranges)*2)
+	for _, r := range ranges {
+		exprs = append(exprs,
+			clause.Gte{Column: clause.Column{Name: r.MaxColumn}, Value: r.Min},
+			clause.Lte{Column: clause.Column{Name: r.MinColumn}, Value: r.Max},
+		)
+	}
+	return clause.And(exprs...)
+}
+
+func rtreeTableSQLs(stmt *gorm.Statement, config RTreeConfig) ([]string, error) {
+	var (
+		columns, auxColumns []string
+		module              = "rtree"
+	)
+	if config.Integer {
+		module = "rtree_i32"
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("rtree table %v requires an integer primary key", stmt.Table)
+	}
+	columns = append(columns, stmt.Quote(stmt.Schema.PrioritizedPrimaryField.DBName))
+
+	for _, dbName := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[dbName]
+		if field.PrimaryKey || field.IgnoreMigration {
+			continue
+		}
+
+		if strings.EqualFold(field.TagSettings["RTREE"], "AUX") {
+			auxColumns = append(auxColumns, "+"+stmt.Quote(dbName))
+		} else {
+			columns = append(columns, stmt.Quote(dbName))
+		}
+	}
+
+	if dimensions := len(columns) - 1; dimensions == 0 || dimensions%2 != 0 || dimensions > 10 {
+		return nil, fmt.Errorf("rtree table %v requires 1 to 5 pairs of min/max columns, got %d columns", stmt.Table, dimensions)
+	}
+
+	schema, name := splitQualifiedTable(statementTable(stmt))
+	return []string{fmt.Sprintf("CREATE VIRTUAL TABLE %s USING %s(%s)", qualifyName(schema, name), module, strings.Join(append(columns, auxColumns...), ","))}, nil
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rtreePlace struct {
+	ID   int64
+	MinX float64
+	MaxX float64
+	MinY float64
+	MaxY float64
+	Name string `gorm:"rtree:aux"`
+}
+
+func (rtreePlace) RTreeTable() RTreeConfig {
+	return RTreeConfig{}
+}
+
+type rtreeInvalid struct {
+	ID   int64
+	MinX float64
+}
+
+func (rtreeInvalid) RTreeTable() RTreeConfig {
+	return RTreeConfig{Integer: true}
+}
+
+func TestRTreeTable(t *testing.T) {
+	db := openTestDB(t, "rtree")
+
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&rtreePlace{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+	if err := db.AutoMigrate(&rtreeInvalid{}); err == nil {
+		t.Errorf("Expected rtree table with an odd number of coordinates to fail")
+	}
+
+	places := []rtreePlace{
+		{ID: 1, MinX: 0, MaxX: 1, MinY: 0, MaxY: 1, Name: "inside"},
+		{ID: 2, MinX: 4, MaxX: 6, MinY: 4, MaxY: 6, Name: "crossing"},
+		{ID: 3, MinX: 10, MaxX: 11, MinY: 10, MaxY: 11, Name: "outside"},
+	}
+	if err := db.Create(&places).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	box := []RTreeRange{
+		{MinColumn: "min_x", MaxColumn: "max_x", Min: -1, Max: 5},
+		{MinColumn: "min_y", MaxColumn: "max_y", Min: -1, Max: 5},
+	}
+
+	var names []string
+	db.Model(&rtreePlace{}).Where(RTreeWithin(box...)).Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"inside"}, names)
+
+	names = nil
+	db.Model(&rtreePlace{}).Where(RTreeOverlaps(box...)).Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"inside", "crossing"}, names)
+}
+
+type rtreeTenantPlace struct {
+	ID   int64
+	MinX float64
+	MaxX float64
+}
+
+func (rtreeTenantPlace) TableName() string {
+	return "tenant.places"
+}
+
+func (rtreeTenantPlace) RTreeTable() RTreeConfig {
+	return RTreeConfig{}
+}
+
+func TestRTreeTableAttached(t *testing.T) {
+	db := openTestDB(t, "rtree_attached")
+	if err := Attach(db, filepath.Join(tempDir(t), "tenant.db"), "tenant"); err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&rtreeTenantPlace{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+	var tables []string
+	db.Raw("SELECT name FROM tenant.sqlite_master WHERE type = 'table' AND name = 'places'").Scan(&tables)
+	assert.Equal(t, []string{"places"}, tables)
+	assert.False(t, db.Migrator().HasTable("places"), "table should be created in the attached database")
+
+	if err := db.Create(&rtreeTenantPlace{ID: 1, MinX: 0, MaxX: 1}).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	var ids []int64
+	db.Model(&rtreeTenantPlace{}).Where(RTreeOverlaps(RTreeRange{MinColumn: "min_x", MaxColumn: "max_x", Min: 0.5, Max: 2})).Pluck("id", &ids)
+	assert.Equal(t, []int64{1}, ids)
+}
@@ -3,8 +3,12 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/gorm/callbacks"
 
@@ -23,12 +27,119 @@ type Dialector struct {
 	DriverName string
 	DSN        string
 	Conn       gorm.ConnPool
+	Config
+
+	// keepAlive pins a connection outside of the pool, see OpenInMemory
+	keepAlive bool
+	// version SQLite version of the database, detected by Initialize
+	version string
+}
+
+// Config optional settings of the dialector, connection level settings are applied to every pooled connection
+// and are ignored when Conn is provided
+type Config struct {
+	// ForeignKeys enables foreign key enforcement with PRAGMA foreign_keys = ON
+	ForeignKeys bool
+	// Key encryption key applied with PRAGMA key before any other statement, requires a driver built with
+	// SQLCipher or SQLite3 Multiple Ciphers, a _pragma_key or _key DSN parameter is used when empty
+	Key string
+	// BusyRetry retries statements failing because the database is locked, on top of the driver's busy timeout
+	BusyRetry BusyRetry
+	// SingleWriter serializes writes and write transactions of the pool, so concurrent goroutines queue up instead
+	// of failing with database is locked, reads stay concurrent. A goroutine must not write outside of a transaction
+	// it holds open, as it would wait for itself
+	SingleWriter bool
+	// AutoCheckpoint WAL size in pages that triggers an automatic checkpoint, 0 keeps SQLite's default of 1000,
+	// a negative value disables automatic checkpoints
+	AutoCheckpoint int
+	// Comments keeps comment tags as -- comments after column definitions of CREATE TABLE statements,
+	// SQLite has no COMMENT syntax, ColumnTypes reads them back so AutoMigrate picks up changed comments
+	Comments bool
+	// DisableDropColumnRebuild makes DropColumn fail instead of rebuilding the table when ALTER TABLE ... DROP COLUMN
+	// can't drop the column, e.g. it is indexed or referenced by a view
+	DisableDropColumnRebuild bool
+	// Autoincrement adds AUTOINCREMENT to auto incremented integer primary keys, so ids of deleted rows are never reused,
+	// at the cost of keeping track of the largest id in sqlite_sequence
+	Autoincrement bool
+	// TimeFormat storage format of time.Time values, times are written in it and columns are declared for it,
+	// values of columns declared for numeric formats are read back as time.Time in UTC.
+	// Existing values are not converted when it changes
+	TimeFormat TimeFormat
+	// DecimalAsText declares decimal and numeric columns as decimal_text, giving them TEXT affinity so SQLite keeps
+	// their exact digits, NUMERIC affinity stores decimals as 64 bit floats, see Decimal
+	DecimalAsText bool
+	// DecimalPrecision and DecimalScale of decimal_text columns declared without precision
+	DecimalPrecision int
+	DecimalScale     int
+	// StatementCacheSize prepared statements cached by each connection, the least recently used ones are closed when
+	// it is full, 0 disables the cache. Unlike gorm's PrepareStmt it needs no per statement bookkeeping in the pool,
+	// see GetStatementCacheStats
+	StatementCacheSize int
+	// Functions application-defined SQL functions registered on every connection, e.g. regexp, uuid_v7 or levenshtein
+	Functions []Function
+	// FunctionRegistry registers Functions process-wide for drivers whose connections can't register them, like
+	// modernc.org/sqlite, see FunctionRegistry
+	FunctionRegistry *FunctionRegistry
+	// Regexp registers a REGEXP function matching Go regular expressions, so X REGEXP Y works, see Regexp
+	Regexp bool
+	// Collations custom collations registered on every connection, e.g. CollationUnicodeNoCase or CollationNatural
+	Collations []Collation
+	// Extensions paths of SQLite extensions loaded on every connection, e.g. spellfix or a vector search extension,
+	// the entry point is derived from the file name. The driver must be built with extension loading
+	Extensions []string
+	// ExtensionAllowlist paths or names of the extensions that may be loaded, e.g. spellfix for /usr/lib/spellfix.so,
+	// Initialize fails with ErrExtensionNotAllowed for other extensions. Every extension is allowed when empty
+	ExtensionAllowlist []string
+	// ReadOnly opens the database with mode=ro and rejects write statements with ErrReadOnly before they reach SQLite,
+	// e.g. to serve a prebuilt dataset, see OpenReadOnly
+	ReadOnly bool
+	// Immutable opens the database with immutable=1, SQLite never locks it or checks it for changes then, so it must not
+	// change while open, e.g. a file on a read-only file system. Implies ReadOnly
+	Immutable bool
+	// Hooks notifies subscribers of commits, rollbacks, schema changes and checkpoints, e.g. for replication tools,
+	// the driver must register hooks like mattn/go-sqlite3
+	Hooks *Hooks
+	// CaptureChanges reports the row level changes of commits in HookCommit events of Hooks, with the values of the rows,
+	// e.g. for syncing or audit trails, see RecordChanges. It keeps the changes of each transaction in memory until it ends
+	CaptureChanges bool
+	// MaxQueryDuration interrupts statements running longer with sqlite3_interrupt, they fail with ErrQueryTimeout,
+	// queries run until their rows are closed. An interrupted write rolls back its whole transaction, 0 disables it.
+	// The driver interrupts statements whose context is canceled regardless
+	MaxQueryDuration time.Duration
+	// MaxRows aborts queries returning more rows with a QueryLimitError, e.g. to contain accidental full table scans,
+	// 0 disables it. Pragmas and queries of sqlite_master aren't limited, so the migrator keeps working
+	MaxRows int64
+	// MaxSteps aborts statements running more virtual machine instructions with a QueryLimitError, e.g. full table
+	// scans returning few rows, 0 disables it. Every statement is limited, migrations and VACUUM of large tables too.
+	// It uses a progress handler, see ProgressHandlerRegisterer
+	MaxSteps int64
 }
 
 func Open(dsn string) gorm.Dialector {
 	return &Dialector{DSN: dsn}
 }
 
+func New(dsn string, config Config) gorm.Dialector {
+	return &Dialector{DSN: dsn, Config: config}
+}
+
+var memoryDatabases uint64
+
+// OpenInMemory opens the shared cache in-memory database name, all pooled connections share it and a connection
+// outside of the pool keeps it alive while the pool is idle, until the sql.DB is closed.
+// An empty name opens a new database with a unique name, e.g. an isolated database for each test
+func OpenInMemory(name string, config ...Config) gorm.Dialector {
+	if name == "" {
+		name = fmt.Sprintf("gorm_memory_%d", atomic.AddUint64(&memoryDatabases, 1))
+	}
+
+	dialector := &Dialector{DSN: "file:" + url.PathEscape(name) + "?mode=memory&cache=shared", keepAlive: true}
+	if len(config) > 0 {
+		dialector.Config = config[0]
+	}
+	return dialector
+}
+
 func (dialector Dialector) Name() string {
 	return "sqlite"
 }
@@ -41,37 +152,126 @@ func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
 	if dialector.Conn != nil {
 		db.ConnPool = dialector.Conn
 	} else {
-		conn, err := sql.Open(dialector.DriverName, dialector.DSN)
+		if err := dialector.checkExtensions(); err != nil {
+			return err
+		}
+
+		dsn, key := extractDSNKey(dialector.DSN)
+		if dialector.Key != "" {
+			key = dialector.Key
+		}
+		if dialector.ReadOnly || dialector.Immutable {
+			dsn = readOnlyDSN(dsn, dialector.Immutable)
+		} else if dialector.BusyRetry.enabled() {
+			// read only databases can't begin immediate transactions
+			dsn = immediateTxDSN(dsn)
+		}
+
+		// process-wide functions are registered before the first connection is opened
+		if functions := dialector.functions(); dialector.FunctionRegistry != nil && len(functions) > 0 {
+			if err := addFunctions(dialector.FunctionRegistry, functions); err != nil {
+				return err
+			}
+		}
+
+		conn, err := sql.Open(dialector.DriverName, dsn)
 		if err != nil {
 			return err
 		}
-		db.ConnPool = conn
+
+		c := &connector{driver: conn.Driver(), dsn: dsn, key: key, timeFormat: dialector.TimeFormat, events: dialector.Hooks, captureChanges: dialector.CaptureChanges, readOnly: dialector.ReadOnly || dialector.Immutable, maxQueryDuration: dialector.MaxQueryDuration,
+			maxRows: dialector.MaxRows, maxSteps: dialector.MaxSteps}
+		if dialector.StatementCacheSize > 0 {
+			c.stmtCacheSize, c.stmtCacheStats = dialector.StatementCacheSize, &stmtCacheStats{}
+		}
+		if dialector.SingleWriter {
+			c.writeLock = make(writeLock, 1)
+		}
+		if key != "" {
+			c.hooks = append(c.hooks, applyKey(c))
+		}
+		c.hooks = append(c.hooks, dialector.connectHooks()...)
+
+		conn.Close()
+		if dialector.keepAlive {
+			if err := c.pin(context.Background()); err != nil {
+				return err
+			}
+		}
+		db.ConnPool = sql.OpenDB(c)
+	}
+
+	if dialector.BusyRetry.enabled() {
+		db.ConnPool = &retryPool{ConnPool: db.ConnPool, retry: dialector.BusyRetry}
 	}
 
 	var version string
 	if err := db.ConnPool.QueryRowContext(context.Background(), "select sqlite_version()").Scan(&version); err != nil {
 		return err
 	}
+	config := &callbacks.Config{
+		CreateClauses:        []string{"WITH", "INSERT", "VALUES", "ON CONFLICT"},
+		QueryClauses:         []string{"WITH", "SELECT", "FROM", "WHERE", "GROUP BY", "ORDER BY", "LIMIT", "FOR"},
+		UpdateClauses:        []string{"WITH", "UPDATE", "SET", "WHERE"},
+		DeleteClauses:        []string{"WITH", "DELETE", "FROM", "WHERE"},
+		LastInsertIDReversed: true,
+	}
+	// https://www.sqlite.org/releaselog/3_33_0.html
+	if compareVersion(version, "3.33.0") >= 0 {
+		config.UpdateClauses = []string{"WITH", "UPDATE", "SET", "FROM", "WHERE"}
+	}
 	// https://www.sqlite.org/releaselog/3_35_0.html
 	if compareVersion(version, "3.35.0") >= 0 {
-		callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{
-			CreateClauses:        []string{"INSERT", "VALUES", "ON CONFLICT", "RETURNING"},
-			UpdateClauses:        []string{"UPDATE", "SET", "WHERE", "RETURNING"},
-			DeleteClauses:        []string{"DELETE", "FROM", "WHERE", "RETURNING"},
-			LastInsertIDReversed: true,
-		})
-	} else {
-		callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{
-			LastInsertIDReversed: true,
-		})
+		config.CreateClauses = append(config.CreateClauses, "RETURNING")
+		config.UpdateClauses = append(config.UpdateClauses, "RETURNING")
+		config.DeleteClauses = append(config.DeleteClauses, "RETURNING")
 	}
+	callbacks.RegisterDefaultCallbacks(db, config)
 
 	for k, v := range dialector.ClauseBuilders() {
 		db.ClauseBuilders[k] = v
 	}
+
+	// the receiver is a copy, the migrator reads the version from the dialector of db
+	switch d := db.Dialector.(type) {
+	case *Dialector:
+		d.version = version
+	case Dialector:
+		d.version = version
+		db.Dialector = d
+	}
+	return
+}
+
+func (dialector Dialector) connectHooks() (hooks []connectHook) {
+	if dialector.ForeignKeys {
+		hooks = append(hooks, execPragma("PRAGMA foreign_keys = ON"))
+	}
+	if dialector.AutoCheckpoint != 0 {
+		hooks = append(hooks, execPragma(fmt.Sprintf("PRAGMA wal_autocheckpoint = %d", dialector.AutoCheckpoint)))
+	}
+	// extensions are loaded first, so the config's functions and collations replace theirs
+	if len(dialector.Extensions) > 0 {
+		hooks = append(hooks, loadExtensions(dialector.Extensions))
+	}
+	// functions of the config are registered last, so they replace built-in ones of the same name
+	if functions := dialector.functions(); dialector.FunctionRegistry == nil && len(functions) > 0 {
+		hooks = append(hooks, registerFunctions(functions))
+	}
+	if len(dialector.Collations) > 0 {
+		hooks = append(hooks, registerCollations(dialector.Collations))
+	}
 	return
 }
 
+// functions returns the application-defined functions of the config
+func (dialector Dialector) functions() []Function {
+	if dialector.Regexp {
+		return append([]Function{regexpFunction}, dialector.Functions...)
+	}
+	return dialector.Functions
+}
+
 func (dialector Dialector) ClauseBuilders() map[string]clause.ClauseBuilder {
 	return map[string]clause.ClauseBuilder{
 		"INSERT": func(c clause.Clause, builder clause.Builder) {
@@ -110,6 +310,7 @@ func (dialector Dialector) ClauseBuilders() map[string]clause.ClauseBuilder {
 				}
 			}
 		},
+		"FROM": buildFrom,
 		"FOR": func(c clause.Clause, builder clause.Builder) {
 			if _, ok := c.Expression.(clause.Locking); ok {
 				// SQLite3 does not support row-level locking.
@@ -141,19 +342,14 @@ func (dialector Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement,
 	writer.WriteByte('?')
 }
 
+// QuoteTo writes str as a double quoted identifier, schema qualified names like main.users are quoted per part,
+// parts already quoted with double quotes, backticks or brackets, e.g. by other tools, are unquoted first
 func (dialector Dialector) QuoteTo(writer clause.Writer, str string) {
-	writer.WriteByte('`')
-	if strings.Contains(str, ".") {
-		for idx, str := range strings.Split(str, ".") {
-			if idx > 0 {
-				writer.WriteString(".`")
-			}
-			writer.WriteString(str)
-			writer.WriteByte('`')
+	for idx, part := range splitQualifiedName(str) {
+		if idx > 0 {
+			writer.WriteByte('.')
 		}
-	} else {
-		writer.WriteString(str)
-		writer.WriteByte('`')
+		writer.WriteString(quoteIdentifier(unquoteIdentifier(part)))
 	}
 }
 
@@ -161,38 +357,95 @@ func (dialector Dialector) Explain(sql string, vars ...interface{}) string {
 	return logger.ExplainSQL(sql, nil, `"`, vars...)
 }
 
+// DataTypeOf returns the type of field, with the COLLATE clause of its collate tag
 func (dialector Dialector) DataTypeOf(field *schema.Field) string {
+	dataType := dialector.dataTypeOf(field)
+	if collation := field.TagSettings["COLLATE"]; collation != "" {
+		dataType += " COLLATE " + quoteIdentifier(collation)
+	}
+	return dataType
+}
+
+func (dialector Dialector) dataTypeOf(field *schema.Field) string {
 	switch field.DataType {
 	case schema.Bool:
 		return "numeric"
 	case schema.Int, schema.Uint:
-		if field.AutoIncrement && !field.PrimaryKey {
-			// https://www.sqlite.org/autoinc.html
+		// https://www.sqlite.org/autoinc.html
+		if field.AutoIncrement && (!field.PrimaryKey || dialector.Autoincrement && len(field.Schema.PrimaryFields) == 1) {
 			return "integer PRIMARY KEY AUTOINCREMENT"
-		} else {
-			return "integer"
 		}
+		return "integer"
 	case schema.Float:
 		return "real"
 	case schema.String:
 		return "text"
 	case schema.Time:
-		return "datetime"
+		return dialector.TimeFormat.dataType()
 	case schema.Bytes:
 		return "blob"
 	}
 
+	if dataType, ok := dialector.decimalDataType(field); ok {
+		return dataType
+	}
 	return string(field.DataType)
 }
 
-func (dialectopr Dialector) SavePoint(tx *gorm.DB, name string) error {
-	tx.Exec("SAVEPOINT " + name)
-	return nil
+func (dialector Dialector) SavePoint(tx *gorm.DB, name string) error {
+	return tx.Exec("SAVEPOINT " + quoteIdentifier(name)).Error
+}
+
+func (dialector Dialector) RollbackTo(tx *gorm.DB, name string) error {
+	return tx.Exec("ROLLBACK TO SAVEPOINT " + quoteIdentifier(name)).Error
+}
+
+// quoteIdentifier quotes name as an SQLite identifier, doubling any embedded quotes
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// splitQualifiedName splits name on the dots outside of quoted parts, e.g. main."user.name" into main and "user.name"
+func splitQualifiedName(name string) (parts []string) {
+	var quote byte
+	start := 0
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '.':
+			parts = append(parts, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, name[start:])
+}
+
+// splitQualifiedTable splits a schema qualified table name like alias.table into its unquoted schema and name,
+// schema is empty for unqualified names, quoted names like "user.name" may contain dots
+func splitQualifiedTable(table string) (schema, name string) {
+	parts := splitQualifiedName(table)
+	if len(parts) == 1 {
+		return "", unquoteIdentifier(table)
+	}
+	return unquoteIdentifier(parts[0]), unquoteIdentifier(strings.Join(parts[1:], "."))
 }
 
-func (dialectopr Dialector) RollbackTo(tx *gorm.DB, name string) error {
-	tx.Exec("ROLLBACK TO SAVEPOINT " + name)
-	return nil
+// asDialector returns the dialector of d, gorm.Open accepts both a Dialector and a *Dialector
+func asDialector(d gorm.Dialector) (*Dialector, bool) {
+	switch dialector := d.(type) {
+	case *Dialector:
+		return dialector, true
+	case Dialector:
+		return &dialector, true
+	}
+	return nil, false
 }
 
 func compareVersion(version1, version2 string) int {
@@ -2,7 +2,9 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/mattn/go-sqlite3"
@@ -130,3 +132,292 @@ func TestDialector(t *testing.T) {
 		})
 	}
 }
+
+func TestDialectorValue(t *testing.T) {
+	type ValueItem struct {
+		ID   uint
+		Name string
+		Age  int
+	}
+
+	// a Dialector value implements gorm.Dialector as well as a pointer
+	db, err := gorm.Open(Dialector{DSN: "file:dialector_value?mode=memory&cache=shared"}, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&ValueItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := db.Migrator().DropColumn(&ValueItem{}, "Age"); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+	if db.Migrator().HasColumn(&ValueItem{}, "Age") {
+		t.Errorf("Expected column age to be dropped")
+	}
+
+	// Initialize detects the version once, for values and pointers alike
+	if version := db.Dialector.(Dialector).version; version == "" {
+		t.Errorf("Expected the dialector to keep the detected version")
+	}
+	pointerDB, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if version := pointerDB.Dialector.(*Dialector).version; version == "" {
+		t.Errorf("Expected the dialector to keep the detected version")
+	}
+
+	m := db.Migrator().(Migrator)
+	m.Dialector = Dialector{}
+	if err := m.DropColumn(&ValueItem{}, "Name"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected an uninitialized dialector to fail with ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestSavePoint(t *testing.T) {
+	type User struct {
+		ID   uint
+		Name string
+	}
+
+	db, err := gorm.Open(Open("file:savepoint?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err = db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&User{Name: "outer"}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Transaction(func(tx2 *gorm.DB) error {
+			if err := tx2.Create(&User{Name: "rolled-back"}).Error; err != nil {
+				return err
+			}
+			return errors.New("rollback nested")
+		}); err == nil {
+			t.Errorf("Expected nested transaction to return its error")
+		}
+
+		return tx.Transaction(func(tx2 *gorm.DB) error {
+			return tx2.Create(&User{Name: "inner"}).Error
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected transaction to succeed; got error: %v", err)
+	}
+
+	var names []string
+	db.Model(&User{}).Order("id").Pluck("name", &names)
+	if len(names) != 2 || names[0] != "outer" || names[1] != "inner" {
+		t.Errorf("Expected nested rollback to discard only its own rows; got %v", names)
+	}
+
+	tx := db.Begin()
+	if err := tx.SavePoint(`weird "name"`).Error; err != nil {
+		t.Errorf("Expected savepoint with quoted name to succeed; got error: %v", err)
+	}
+	tx.Create(&User{Name: "discarded"})
+	if err := tx.RollbackTo(`weird "name"`).Error; err != nil {
+		t.Errorf("Expected rollback to savepoint to succeed; got error: %v", err)
+	}
+	if err := tx.RollbackTo("missing").Error; err == nil {
+		t.Errorf("Expected rollback to unknown savepoint to fail")
+	}
+	tx.Rollback()
+}
+
+func TestCipherKey(t *testing.T) {
+	params := []struct {
+		dsn, expectDSN, expectKey string
+	}{
+		{"gorm.db", "gorm.db", ""},
+		{"file:gorm.db?_pragma_key=secret", "file:gorm.db", "secret"},
+		{"file:gorm.db?cache=shared&_key=secret&mode=rwc", "file:gorm.db?cache=shared&mode=rwc", "secret"},
+		{"file:gorm.db?cache=shared", "file:gorm.db?cache=shared", ""},
+	}
+	for _, p := range params {
+		dsn, key := extractDSNKey(p.dsn)
+		if dsn != p.expectDSN || key != p.expectKey {
+			t.Errorf("Expected %v to be split into %v and %v, got %v and %v", p.dsn, p.expectDSN, p.expectKey, dsn, key)
+		}
+	}
+
+	_, err := gorm.Open(New("file:cipher?mode=memory&cache=shared", Config{Key: "secret"}), &gorm.Config{})
+	if !errors.Is(err, ErrCipherNotSupported) {
+		t.Errorf("Expected ErrCipherNotSupported without SQLCipher; got %v", err)
+	}
+
+	db, err := gorm.Open(Open("file:cipher?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := Rekey(db, "secret"); err == nil {
+		t.Errorf("Expected Rekey to fail for a database opened without key")
+	}
+}
+
+func TestOpenInMemory(t *testing.T) {
+	type User struct {
+		ID   uint
+		Name string
+	}
+
+	db, err := gorm.Open(OpenInMemory("in_memory"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err = db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&User{Name: "jinzhu"})
+
+	// without a pinned connection the database is dropped with the last pooled connection
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(2)
+
+	var count int64
+	if err := db.Model(&User{}).Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("Expected database to survive an idle pool, got %v, %v", count, err)
+	}
+
+	isolated, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if isolated.Migrator().HasTable(&User{}) {
+		t.Errorf("Expected databases without name to be isolated")
+	}
+
+	sqlDB.Close()
+	db, err = gorm.Open(OpenInMemory("in_memory"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if db.Migrator().HasTable(&User{}) {
+		t.Errorf("Expected database to be dropped once closed")
+	}
+}
+
+func TestQuoteTo(t *testing.T) {
+	params := []struct {
+		name, expect string
+	}{
+		{"users", `"users"`},
+		{"main.users", `"main"."users"`},
+		{"order items", `"order items"`},
+		{"select", `"select"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{"[order items]", `"order items"`},
+		{"`users`.[first name]", `"users"."first name"`},
+		{`main."user.name"`, `"main"."user.name"`},
+	}
+	for _, p := range params {
+		var builder strings.Builder
+		Dialector{}.QuoteTo(&builder, p.name)
+		if builder.String() != p.expect {
+			t.Errorf("Expected %v to be quoted as %v, got %v", p.name, p.expect, builder.String())
+		}
+	}
+}
+
+type OrderItem struct {
+	ID        uint
+	UnitPrice float64 `gorm:"column:unit price"`
+	Select    string  `gorm:"not null;default:''"`
+}
+
+func (OrderItem) TableName() string {
+	return "order items"
+}
+
+func TestQuotedIdentifiers(t *testing.T) {
+	db, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	// a table created by another tool quoting with brackets
+	if err := db.Exec("CREATE TABLE [order items] ([id] integer PRIMARY KEY, [unit price] real, [select] text)").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	db.Exec("INSERT INTO [order items] ([unit price], [select]) VALUES (9.5, 'all')")
+
+	if err := db.AutoMigrate(&OrderItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := db.Migrator().RenameColumn(&OrderItem{}, "unit price", "net price"); err != nil {
+		t.Fatalf("failed to rename column: %v", err)
+	}
+	if err := db.Migrator().RenameColumn(&OrderItem{}, "net price", "unit price"); err != nil {
+		t.Fatalf("failed to rename column: %v", err)
+	}
+
+	var item OrderItem
+	if err := db.First(&item).Error; err != nil || item.UnitPrice != 9.5 || item.Select != "all" {
+		t.Errorf("Expected rebuilt table to keep its rows, got %+v, %v", item, err)
+	}
+
+	var ddl string
+	db.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "order items").Row().Scan(&ddl)
+	if !strings.Contains(ddl, `"select" text NOT NULL DEFAULT ''`) {
+		t.Errorf("Expected rebuilt table to quote keywords with double quotes, got %v", ddl)
+	}
+}
+
+type OrderLine struct {
+	ID  uint
+	SKU string `gorm:"index"`
+	Qty int
+}
+
+func (OrderLine) TableName() string {
+	return `"order.lines"`
+}
+
+func TestQuotedDottedTableName(t *testing.T) {
+	db, err := gorm.Open(OpenInMemory(""), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := db.AutoMigrate(&OrderLine{}); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+	}
+	if !db.Migrator().HasTable(&OrderLine{}) || !db.Migrator().HasTable(`main."order.lines"`) {
+		t.Fatalf("Expected table order.lines to exist")
+	}
+	// gorm splits the table of its queries on the dot, the migrator has to handle it on its own
+	if err := db.Exec(`INSERT INTO "order.lines" (sku, qty) VALUES ('a-1', 2)`).Error; err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	// rebuilds the table
+	if err := db.Migrator().AlterColumn(&OrderLine{}, "Qty"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	if !db.Migrator().HasIndex(&OrderLine{}, "SKU") {
+		t.Errorf("Expected rebuilt table to keep its index")
+	}
+	indexes, err := db.Migrator().(Migrator).GetIndexes(&OrderLine{})
+	if err != nil || len(indexes) != 1 {
+		t.Errorf("Expected one index, got %v, %v", indexes, err)
+	}
+
+	var line OrderLine
+	if err := db.Raw(`SELECT * FROM "order.lines"`).Scan(&line).Error; err != nil || line.SKU != "a-1" || line.Qty != 2 {
+		t.Errorf("Expected rebuilt table to keep its rows, got %+v, %v", line, err)
+	}
+
+	var count int
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE name IN (?, ?)", "order", "lines").Row().Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no table named after a part of order.lines")
+	}
+}
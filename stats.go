@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DatabaseStats statistics of a database schema, see GetDatabaseStats
+type DatabaseStats struct {
+	Schema string
+	// File path of the database file, empty for in-memory and temporary databases
+	File        string
+	JournalMode string
+	PageSize    int64
+	// PageCount pages of the database file, including free pages
+	PageCount int64
+	// FreelistCount unused pages, VACUUM returns them to the file system
+	FreelistCount int64
+	// Size bytes of the database file, PageSize * PageCount
+	Size int64
+	// WALSize bytes of the WAL file, 0 if the database is not in WAL mode
+	WALSize int64
+	// Objects tables and indexes by decreasing size, nil if SQLite is built without the dbstat virtual table,
+	// https://www.sqlite.org/dbstat.html
+	Objects []ObjectStats
+}
+
+// ObjectStats size of a table or an index
+type ObjectStats struct {
+	Name string
+	// Table the table of indexes, Name for tables
+	Table string
+	// Type table or index
+	Type  string
+	Pages int64
+	// Size bytes of the pages
+	Size int64
+	// Payload bytes of the stored rows or index entries
+	Payload int64
+	// Unused bytes of the pages
+	Unused int64
+	// Cells rows or index entries, and child page pointers of interior pages
+	Cells int64
+}
+
+// GetDatabaseStats returns the page counts and sizes of schema, main if it's empty, and of its tables and indexes
+func GetDatabaseStats(db *gorm.DB, schema string) (stats DatabaseStats, err error) {
+	stats.Schema = schemaName(schema)
+	quotedSchema := quoteIdentifier(stats.Schema)
+	for pragma, value := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreelistCount,
+	} {
+		if err = db.Raw(fmt.Sprintf("PRAGMA %s.%s", quotedSchema, pragma)).Row().Scan(value); err != nil {
+			return
+		}
+	}
+	stats.Size = stats.PageSize * stats.PageCount
+
+	if err = db.Raw(fmt.Sprintf("PRAGMA %s.journal_mode", quotedSchema)).Row().Scan(&stats.JournalMode); err != nil {
+		return
+	}
+	stats.JournalMode = strings.ToLower(stats.JournalMode)
+	if err = db.Raw("SELECT file FROM pragma_database_list WHERE name = ?", stats.Schema).Row().Scan(&stats.File); err != nil {
+		return
+	}
+	if stats.JournalMode == "wal" && stats.File != "" {
+		if info, err := os.Stat(stats.File + "-wal"); err == nil {
+			stats.WALSize = info.Size()
+		}
+	}
+
+	rows, err := db.Raw(fmt.Sprintf(`SELECT s.name, coalesce(m.tbl_name, s.name), coalesce(m.type, 'table'), count(*), sum(s.pgsize),
+		sum(s.payload), sum(s.unused), sum(s.ncell) FROM dbstat(?) AS s LEFT JOIN %s AS m ON m.name = s.name
+		GROUP BY s.name ORDER BY sum(s.pgsize) DESC, s.name`, masterTable(schema)), stats.Schema).Rows()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: dbstat") {
+			err = nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object ObjectStats
+		if err = rows.Scan(&object.Name, &object.Table, &object.Type, &object.Pages, &object.Size, &object.Payload, &object.Unused, &object.Cells); err != nil {
+			return
+		}
+		stats.Objects = append(stats.Objects, object)
+	}
+	err = rows.Err()
+	return
+}
+
+// StatsVar returns an expvar.Var publishing the DatabaseStats of schema as JSON, they are read each time the
+// variable is, e.g. expvar.Publish("sqlite", StatsVar(db, "main"))
+func StatsVar(db *gorm.DB, schema string) expvar.Var {
+	return expvar.Func(func() interface{} {
+		stats, err := GetDatabaseStats(db, schema)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		return stats
+	})
+}
+
+// WritePrometheus writes the stats as gauges in the Prometheus text exposition format, labeled by schema and for
+// objects by name, table and type, https://prometheus.io/docs/instrumenting/exposition_formats/
+func (stats DatabaseStats) WritePrometheus(w io.Writer) error {
+	labels := fmt.Sprintf(`schema="%s"`, escapeLabel(stats.Schema))
+	metrics := []struct {
+		name, help string
+		value      int64
+	}{
+		{"sqlite_page_size_bytes", "Bytes of a database page.", stats.PageSize},
+		{"sqlite_pages", "Pages of the database file.", stats.PageCount},
+		{"sqlite_freelist_pages", "Unused pages of the database file.", stats.FreelistCount},
+		{"sqlite_size_bytes", "Bytes of the database file.", stats.Size},
+		{"sqlite_wal_size_bytes", "Bytes of the WAL file.", stats.WALSize},
+	}
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{%s} %d\n", metric.name, metric.help, metric.name, metric.name, labels, metric.value); err != nil {
+			return err
+		}
+	}
+
+	if len(stats.Objects) == 0 {
+		return nil
+	}
+	for _, metric := range []struct {
+		name, help string
+		value      func(ObjectStats) int64
+	}{
+		{"sqlite_object_pages", "Pages of a table or an index.", func(object ObjectStats) int64 { return object.Pages }},
+		{"sqlite_object_size_bytes", "Bytes of the pages of a table or an index.", func(object ObjectStats) int64 { return object.Size }},
+		{"sqlite_object_payload_bytes", "Bytes of the rows of a table or the entries of an index.", func(object ObjectStats) int64 { return object.Payload }},
+	} {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name); err != nil {
+			return err
+		}
+		for _, object := range stats.Objects {
+			if _, err := fmt.Fprintf(w, `%s{%s,name="%s",table="%s",type="%s"} %d`+"\n", metric.name, labels,
+				escapeLabel(object.Name), escapeLabel(object.Table), escapeLabel(object.Type), metric.value(object)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
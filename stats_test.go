@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type StatsItem struct {
+	ID   uint
+	Name string `gorm:"index"`
+}
+
+func TestGetDatabaseStats(t *testing.T) {
+	path := filepath.Join(tempDir(t), "stats.db")
+	db, err := gorm.Open(New("file:"+path+"?_journal_mode=WAL", Config{}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&StatsItem{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		db.Create(&StatsItem{Name: strings.Repeat("x", 100)})
+	}
+
+	stats, err := GetDatabaseStats(db, "")
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	assert.Equal(t, "main", stats.Schema)
+	assert.Equal(t, path, stats.File)
+	assert.Equal(t, "wal", stats.JournalMode)
+	assert.Greater(t, stats.PageSize, int64(0))
+	assert.Greater(t, stats.PageCount, int64(1))
+	assert.Equal(t, stats.PageSize*stats.PageCount, stats.Size)
+	assert.Greater(t, stats.WALSize, int64(0))
+
+	var dbstat int
+	if db.Raw("SELECT count(*) FROM dbstat").Row().Scan(&dbstat) == nil {
+		names := map[string]string{}
+		for _, object := range stats.Objects {
+			names[object.Name] = object.Type
+		}
+		assert.Equal(t, "table", names["stats_items"])
+		assert.Equal(t, "index", names["idx_stats_items_name"])
+	} else {
+		assert.Nil(t, stats.Objects)
+	}
+
+	db.Delete(&StatsItem{}, "id > ?", 0)
+	Checkpoint(db, CheckpointTruncate)
+	stats, err = GetDatabaseStats(db, "main")
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	assert.Greater(t, stats.FreelistCount, int64(0))
+	assert.Equal(t, int64(0), stats.WALSize)
+
+	memory, err := GetDatabaseStats(openTestDB(t, "stats"), "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", memory.File)
+		assert.Equal(t, int64(0), memory.WALSize)
+	}
+
+	// exports
+	var exported map[string]interface{}
+	if assert.NoError(t, json.Unmarshal([]byte(StatsVar(db, "").String()), &exported)) {
+		assert.Equal(t, "main", exported["Schema"])
+	}
+
+	stats.Objects = []ObjectStats{{Name: `odd"name`, Table: `odd"name`, Type: "table", Pages: 2, Size: 8192, Payload: 100}}
+	var buf bytes.Buffer
+	if assert.NoError(t, stats.WritePrometheus(&buf)) {
+		assert.Contains(t, buf.String(), "# TYPE sqlite_pages gauge\n")
+		assert.Contains(t, buf.String(), `sqlite_freelist_pages{schema="main"} `)
+		assert.Contains(t, buf.String(), `sqlite_object_size_bytes{schema="main",name="odd\"name",table="odd\"name",type="table"} 8192`+"\n")
+	}
+}
@@ -0,0 +1,271 @@
+package sqlite
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+var (
+	cacheableExecRegexp = regexp.MustCompile(`(?i)^\s*(?:INSERT|UPDATE|DELETE|REPLACE)\b`)
+	returningRegexp     = regexp.MustCompile(`(?i)\bRETURNING\b`)
+)
+
+// StatementCacheStats metrics of the statement cache of all connections, see Config.StatementCacheSize
+type StatementCacheStats struct {
+	// Hits statements run with a cached prepared statement
+	Hits uint64
+	// Misses statements prepared because they weren't cached, or their cached statement was still reading rows
+	Misses uint64
+	// Evictions least recently used statements closed to make room for new ones
+	Evictions uint64
+	// Cached prepared statements held by the caches of open connections
+	Cached int64
+}
+
+type stmtCacheStats struct {
+	hits, misses, evictions uint64
+	cached                  int64
+}
+
+// GetStatementCacheStats returns the statement cache metrics of db, they are zero when the cache is disabled
+func GetStatementCacheStats(db *gorm.DB) (StatementCacheStats, error) {
+	_, c, _, err := getConnector(db, "GetStatementCacheStats")
+	if err != nil || c.stmtCacheStats == nil {
+		return StatementCacheStats{}, err
+	}
+
+	return StatementCacheStats{
+		Hits:      atomic.LoadUint64(&c.stmtCacheStats.hits),
+		Misses:    atomic.LoadUint64(&c.stmtCacheStats.misses),
+		Evictions: atomic.LoadUint64(&c.stmtCacheStats.evictions),
+		Cached:    atomic.LoadInt64(&c.stmtCacheStats.cached),
+	}, nil
+}
+
+// isCacheableQuery reports whether query is a single statement, a prepared statement only runs the first one
+func isCacheableQuery(query string) bool {
+	query = strings.TrimRight(query, "; \t\r\n")
+	return strings.TrimSpace(query) != "" && !strings.Contains(query, ";")
+}
+
+// isCacheableExec reports whether the exec statement query can be cached, statements returning rows would stay
+// active after ExecContext as only closing rows resets them
+func isCacheableExec(query string) bool {
+	return isCacheableQuery(query) && cacheableExecRegexp.MatchString(query) && !returningRegexp.MatchString(query)
+}
+
+// stmtCacheConn runs queries with prepared statements cached per connection, evicting the least recently used ones
+type stmtCacheConn struct {
+	driver.Conn
+	size  int
+	stats *stmtCacheStats
+	// lru cached statements, the most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query string
+	stmt  driver.Stmt
+	// inUse is set while rows of the statement are open
+	inUse bool
+}
+
+func newStmtCacheConn(conn driver.Conn, size int, stats *stmtCacheStats) *stmtCacheConn {
+	return &stmtCacheConn{Conn: conn, size: size, stats: stats, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+// unwrap returns the driver connection
+func (c *stmtCacheConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// get returns the cached statement of query, preparing it on a miss, it returns nil if the cached statement is in use
+func (c *stmtCacheConn) get(ctx context.Context, query string) (*cachedStmt, error) {
+	if elem, ok := c.entries[query]; ok {
+		entry := elem.Value.(*cachedStmt)
+		if entry.inUse {
+			atomic.AddUint64(&c.stats.misses, 1)
+			return nil, nil
+		}
+
+		c.lru.MoveToFront(elem)
+		atomic.AddUint64(&c.stats.hits, 1)
+		return entry, nil
+	}
+	atomic.AddUint64(&c.stats.misses, 1)
+
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cachedStmt{query: query, stmt: stmt}
+	c.entries[query] = c.lru.PushFront(entry)
+	atomic.AddInt64(&c.stats.cached, 1)
+
+	// statements reading rows can't be closed, the cache grows beyond size until they are done
+	for elem := c.lru.Back(); c.lru.Len() > c.size && elem != nil; {
+		prev := elem.Prev()
+		if evicted := elem.Value.(*cachedStmt); !evicted.inUse && evicted != entry {
+			c.remove(elem)
+			atomic.AddUint64(&c.stats.evictions, 1)
+		}
+		elem = prev
+	}
+	return entry, nil
+}
+
+func (c *stmtCacheConn) remove(elem *list.Element) {
+	entry := elem.Value.(*cachedStmt)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.query)
+	entry.stmt.Close()
+	atomic.AddInt64(&c.stats.cached, -1)
+}
+
+func (c *stmtCacheConn) Close() error {
+	for elem := c.lru.Front(); elem != nil; elem = c.lru.Front() {
+		c.remove(elem)
+	}
+	return c.Conn.Close()
+}
+
+func (c *stmtCacheConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *stmtCacheConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, errNotImplemented
+}
+
+func (c *stmtCacheConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if isCacheableExec(query) {
+		entry, err := c.get(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if execer, ok := entry.stmtExecer(); ok {
+			entry.inUse = true
+			defer func() { entry.inUse = false }()
+			return execer.ExecContext(ctx, args)
+		}
+	}
+
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *stmtCacheConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if isCacheableQuery(query) {
+		entry, err := c.get(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if queryer, ok := entry.stmtQueryer(); ok {
+			entry.inUse = true
+			rows, err := queryer.QueryContext(ctx, args)
+			if err != nil {
+				entry.inUse = false
+				return nil, err
+			}
+			return &stmtCacheRows{Rows: rows, entry: entry}, nil
+		}
+	}
+
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// Prepare and PrepareContext return statements outside of the cache, database/sql closes them
+func (c *stmtCacheConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *stmtCacheConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return nil, errNotImplemented
+}
+
+func (s *cachedStmt) stmtExecer() (driver.StmtExecContext, bool) {
+	if s == nil {
+		return nil, false
+	}
+	execer, ok := s.stmt.(driver.StmtExecContext)
+	return execer, ok
+}
+
+func (s *cachedStmt) stmtQueryer() (driver.StmtQueryContext, bool) {
+	if s == nil {
+		return nil, false
+	}
+	queryer, ok := s.stmt.(driver.StmtQueryContext)
+	return queryer, ok
+}
+
+// stmtCacheRows releases its cached statement when closed, closing the rows resets the statement
+type stmtCacheRows struct {
+	driver.Rows
+	entry *cachedStmt
+}
+
+func (r *stmtCacheRows) Close() error {
+	err := r.Rows.Close()
+	r.entry.inUse = false
+	return err
+}
+
+func (r *stmtCacheRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *stmtCacheRows) ColumnTypeLength(index int) (int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rows.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *stmtCacheRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *stmtCacheRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rows.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r *stmtCacheRows) ColumnTypeScanType(index int) reflect.Type {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rows.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
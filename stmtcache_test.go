@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStatementCache(t *testing.T) {
+	type CachedUser struct {
+		ID        uint
+		Name      string
+		CreatedAt time.Time
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{StatementCacheSize: 3, TimeFormat: TimeFormatUnix}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&CachedUser{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+	for i := 0; i < 5; i++ {
+		if err := db.Create(&CachedUser{Name: "user", CreatedAt: now}).Error; err != nil {
+			t.Fatalf("failed to create: %v", err)
+		}
+	}
+
+	before, _ := GetStatementCacheStats(db)
+	for i := 1; i <= 5; i++ {
+		var user CachedUser
+		if err := db.First(&user, i).Error; err != nil {
+			t.Fatalf("failed to find: %v", err)
+		}
+		assert.Equal(t, now, user.CreatedAt)
+	}
+	stats, _ := GetStatementCacheStats(db)
+	assert.Equal(t, uint64(4), stats.Hits-before.Hits)
+	assert.LessOrEqual(t, stats.Cached, int64(3))
+	assert.Greater(t, stats.Evictions, uint64(0))
+
+	// a cached statement reading rows isn't reused by the same query on its connection
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rows, err := tx.Raw("SELECT id FROM cached_users ORDER BY id").Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			rows.Scan(&id)
+
+			var ids []int
+			if err := tx.Raw("SELECT id FROM cached_users ORDER BY id").Scan(&ids).Error; err != nil {
+				return err
+			}
+			assert.Len(t, ids, 5)
+		}
+		return rows.Err()
+	})
+	assert.NoError(t, err)
+
+	// the column types of cached statements are kept
+	columnTypes, err := db.Migrator().ColumnTypes(&CachedUser{})
+	assert.NoError(t, err)
+	assert.Len(t, columnTypes, 3)
+
+	db, _ = gorm.Open(OpenInMemory(""), &gorm.Config{})
+	stats, err = GetStatementCacheStats(db)
+	assert.NoError(t, err)
+	assert.Equal(t, StatementCacheStats{}, stats)
+}
+
+func TestIsCacheableExec(t *testing.T) {
+	assert.True(t, isCacheableExec("INSERT INTO users (name) VALUES (?)"))
+	assert.True(t, isCacheableExec("UPDATE users SET name = ? WHERE id = ?;"))
+	assert.False(t, isCacheableExec("INSERT INTO users (name) VALUES (?) RETURNING id"))
+	assert.False(t, isCacheableExec("PRAGMA foreign_keys = ON"))
+	assert.False(t, isCacheableExec("DELETE FROM users; DELETE FROM pets"))
+	assert.False(t, isCacheableQuery(" ; "))
+}
@@ -0,0 +1,247 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TimeFormat storage format of time.Time values, see Config.TimeFormat
+type TimeFormat string
+
+const (
+	// TimeFormatDefault stores times as text in the driver's layout, e.g. 2006-01-02 15:04:05.999999999-07:00
+	TimeFormatDefault TimeFormat = ""
+	// TimeFormatRFC3339 stores times as RFC3339 text, e.g. 2006-01-02T15:04:05.999999999Z07:00
+	TimeFormatRFC3339 TimeFormat = "rfc3339"
+	// TimeFormatUnix stores times as integer unix seconds, columns are declared as unixepoch
+	TimeFormatUnix TimeFormat = "unix"
+	// TimeFormatUnixMilli stores times as integer unix milliseconds, columns are declared as unixepoch_ms
+	TimeFormatUnixMilli TimeFormat = "unixmilli"
+	// TimeFormatJulian stores times as real julian day numbers, columns are declared as julianday
+	TimeFormatJulian TimeFormat = "julian"
+)
+
+// julian day number of the unix epoch
+const unixEpochJulianDay = 2440587.5
+
+// dataType returns the declared column type of times stored in format f,
+// the declared types of numeric formats tell times apart from numbers when they are read
+func (f TimeFormat) dataType() string {
+	switch f {
+	case TimeFormatUnix:
+		return "unixepoch"
+	case TimeFormatUnixMilli:
+		return "unixepoch_ms"
+	case TimeFormatJulian:
+		return "julianday"
+	}
+	return "datetime"
+}
+
+// value returns t in format f
+func (f TimeFormat) value(t time.Time) driver.Value {
+	switch f {
+	case TimeFormatRFC3339:
+		return t.Format(time.RFC3339Nano)
+	case TimeFormatUnix:
+		return t.Unix()
+	case TimeFormatUnixMilli:
+		return t.Unix()*1e3 + int64(t.Nanosecond())/1e6
+	case TimeFormatJulian:
+		return float64(t.Unix())/86400 + float64(t.Nanosecond())/86400e9 + unixEpochJulianDay
+	}
+	return t
+}
+
+// timeFormatOf returns the numeric time format of a declared column type, ok is false for other types
+func timeFormatOf(dataType string) (format TimeFormat, ok bool) {
+	for _, f := range []TimeFormat{TimeFormatUnix, TimeFormatUnixMilli, TimeFormatJulian} {
+		if strings.EqualFold(dataType, f.dataType()) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// parse returns the time of value stored in format f, times are read back in UTC
+func (f TimeFormat) parse(value driver.Value) driver.Value {
+	var number float64
+	switch v := value.(type) {
+	case int64:
+		switch f {
+		case TimeFormatUnix:
+			return time.Unix(v, 0).UTC()
+		case TimeFormatUnixMilli:
+			return time.Unix(v/1e3, v%1e3*1e6).UTC()
+		}
+		number = float64(v)
+	case float64:
+		number = v
+	default:
+		return value
+	}
+
+	switch f {
+	case TimeFormatUnix:
+		return time.Unix(0, int64(math.Round(number*1e9))).UTC()
+	case TimeFormatUnixMilli:
+		return time.Unix(0, int64(math.Round(number*1e6))).UTC()
+	}
+	// julian days carry about millisecond precision, like SQLite's date functions
+	millis := math.Round((number - unixEpochJulianDay) * 86400e3)
+	return time.Unix(0, int64(millis)*1e6).UTC()
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// timeConn stores time.Time arguments in its format and reads times of numeric time columns back as time.Time
+type timeConn struct {
+	driver.Conn
+	format TimeFormat
+}
+
+// unwrap returns the driver connection
+func (c *timeConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// CheckNamedValue converts time.Time arguments, including those returned by a driver.Valuer like gorm.DeletedAt
+func (c *timeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if valuer, ok := nv.Value.(driver.Valuer); ok {
+		// like database/sql, a nil pointer of a type implementing Valuer on its value is NULL
+		if rv := reflect.ValueOf(valuer); rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+			nv.Value = nil
+			return nil
+		}
+
+		value, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		nv.Value = value
+	}
+
+	if t, ok := nv.Value.(time.Time); ok {
+		nv.Value = c.format.value(t)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (c *timeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *timeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, errNotImplemented
+}
+
+func (c *timeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *timeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &timeRows{Rows: rows}, nil
+}
+
+func (c *timeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *timeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timeStmt{Stmt: stmt}, nil
+}
+
+type timeStmt struct {
+	driver.Stmt
+}
+
+func (s *timeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return nil, errNotImplemented
+}
+
+func (s *timeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &timeRows{Rows: rows}, nil
+}
+
+// timeRows converts values of columns declared with a numeric time format to time.Time
+type timeRows struct {
+	driver.Rows
+	formats []TimeFormat
+}
+
+func (r *timeRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+
+	if r.formats == nil {
+		r.formats = make([]TimeFormat, len(dest))
+		for i := range dest {
+			if format, ok := timeFormatOf(r.ColumnTypeDatabaseTypeName(i)); ok {
+				r.formats[i] = format
+			}
+		}
+	}
+
+	for i, format := range r.formats {
+		if format != TimeFormatDefault {
+			dest[i] = format.parse(dest[i])
+		}
+	}
+	return nil
+}
+
+func (r *timeRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *timeRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
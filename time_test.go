@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestTimeFormat(t *testing.T) {
+	type Event struct {
+		ID        uint
+		Name      string
+		StartsAt  time.Time
+		DeletedAt gorm.DeletedAt
+	}
+
+	startsAt := time.Date(2022, 5, 1, 10, 20, 30, 0, time.UTC)
+	params := []struct {
+		format   TimeFormat
+		dataType string
+		// datetime expression of the CLI reading the stored value
+		datetime string
+	}{
+		{TimeFormatDefault, "datetime", "datetime(starts_at)"},
+		{TimeFormatRFC3339, "datetime", "datetime(starts_at)"},
+		{TimeFormatUnix, "unixepoch", "datetime(starts_at, 'unixepoch')"},
+		{TimeFormatUnixMilli, "unixepoch_ms", "datetime(starts_at / 1000, 'unixepoch')"},
+		{TimeFormatJulian, "julianday", "datetime(starts_at)"},
+	}
+
+	for _, p := range params {
+		t.Run(string(p.format), func(t *testing.T) {
+			db, err := gorm.Open(OpenInMemory("", Config{TimeFormat: p.format}), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			if err := db.AutoMigrate(&Event{}); err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+			// a second migration must not see any changes
+			if err := db.AutoMigrate(&Event{}); err != nil {
+				t.Fatalf("failed to migrate again: %v", err)
+			}
+
+			columnTypes, err := db.Migrator().ColumnTypes(&Event{})
+			if err != nil {
+				t.Fatalf("failed to get column types: %v", err)
+			}
+			for _, columnType := range columnTypes {
+				if columnType.Name() == "starts_at" && columnType.DatabaseTypeName() != p.dataType {
+					t.Errorf("Expected starts_at to be declared as %v, got %v", p.dataType, columnType.DatabaseTypeName())
+				}
+			}
+
+			if err := db.Create(&Event{Name: "launch", StartsAt: startsAt}).Error; err != nil {
+				t.Fatalf("failed to create: %v", err)
+			}
+
+			var datetime string
+			if err := db.Raw("SELECT " + p.datetime + " FROM events").Scan(&datetime).Error; err != nil || datetime != "2022-05-01 10:20:30" {
+				t.Errorf("Expected the stored time to be readable by SQLite's date functions, got %v, %v", datetime, err)
+			}
+
+			var event Event
+			if err := db.Where("starts_at = ?", startsAt).First(&event).Error; err != nil {
+				t.Fatalf("failed to find by time: %v", err)
+			}
+			if !event.StartsAt.Equal(startsAt) {
+				t.Errorf("Expected starts at %v, got %v", startsAt, event.StartsAt)
+			}
+
+			if err := db.Delete(&event).Error; err != nil {
+				t.Fatalf("failed to delete: %v", err)
+			}
+			var count int64
+			db.Model(&Event{}).Count(&count)
+			if count != 0 {
+				t.Errorf("Expected soft deleted event to be hidden, got %v events", count)
+			}
+			db.Unscoped().Model(&Event{}).Where("deleted_at < ?", time.Now().Add(time.Minute)).Count(&count)
+			if count != 1 {
+				t.Errorf("Expected soft deleted event to be found by its deletion time, got %v events", count)
+			}
+		})
+	}
+}
+
+func TestTimeFormatParse(t *testing.T) {
+	instant := time.Date(2022, 5, 1, 10, 20, 30, 250e6, time.UTC)
+
+	for _, format := range []TimeFormat{TimeFormatUnixMilli, TimeFormatJulian} {
+		if parsed, ok := format.parse(format.value(instant)).(time.Time); !ok || !parsed.Equal(instant) {
+			t.Errorf("Expected %v to round trip %v, got %v", format, instant, parsed)
+		}
+	}
+
+	if parsed := TimeFormatUnix.parse(int64(1651400430)); !parsed.(time.Time).Equal(instant.Truncate(time.Second)) {
+		t.Errorf("Expected unix seconds to be parsed, got %v", parsed)
+	}
+	if parsed := TimeFormatUnix.parse("text"); parsed != "text" {
+		t.Errorf("Expected values other than numbers to be kept, got %v", parsed)
+	}
+}
@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var triggerRegexp = regexp.MustCompile(fmt.Sprintf("(?is)^\\s*CREATE\\s+(?:TEMP\\s+|TEMPORARY\\s+)?TRIGGER\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(?:(%[1]v)\\s*\\.\\s*)?(%[1]v)", identifierPattern))
+
+// TriggerTiming when a trigger runs relative to its event
+type TriggerTiming string
+
+const (
+	TriggerBefore    TriggerTiming = "BEFORE"
+	TriggerAfter     TriggerTiming = "AFTER"
+	TriggerInsteadOf TriggerTiming = "INSTEAD OF"
+)
+
+// TriggerEvent statement firing a trigger
+type TriggerEvent string
+
+const (
+	TriggerInsert TriggerEvent = "INSERT"
+	TriggerUpdate TriggerEvent = "UPDATE"
+	TriggerDelete TriggerEvent = "DELETE"
+)
+
+// Trigger a FOR EACH ROW trigger of a table, https://www.sqlite.org/lang_createtrigger.html
+type Trigger struct {
+	Name   string
+	Timing TriggerTiming
+	Event  TriggerEvent
+	// Columns restricts an update trigger to updates of these columns
+	Columns []string
+	// When optional condition of the trigger, it can refer to NEW and OLD rows
+	When string
+	// Body statements run by the trigger, each terminated by a semicolon
+	Body string
+}
+
+// CreateTrigger creates trigger on the table of value, in the same database as the table
+func (m Migrator) CreateTrigger(value interface{}, trigger Trigger) error {
+	if trigger.Name == "" || trigger.Timing == "" || trigger.Event == "" || strings.TrimSpace(trigger.Body) == "" {
+		return errors.New("sqlite: trigger requires a name, timing, event and body")
+	}
+
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		schema, table := splitQualifiedTable(statementTable(stmt))
+
+		sql := "CREATE TRIGGER " + m.DB.Statement.Quote(clause.Table{Name: qualifyName(schema, trigger.Name)}) +
+			" " + string(trigger.Timing) + " " + string(trigger.Event)
+		if len(trigger.Columns) > 0 {
+			columns := make([]string, 0, len(trigger.Columns))
+			for _, column := range trigger.Columns {
+				if stmt.Schema != nil {
+					if field := stmt.Schema.LookUpField(column); field != nil {
+						column = field.DBName
+					}
+				}
+				columns = append(columns, m.DB.Statement.Quote(clause.Column{Name: column}))
+			}
+			sql += " OF " + strings.Join(columns, ",")
+		}
+
+		// the table of a trigger can't be schema qualified, it is in the database of the trigger
+		sql += " ON " + quoteIdentifier(table) + " FOR EACH ROW"
+		if trigger.When != "" {
+			sql += " WHEN " + trigger.When
+		}
+
+		body := strings.TrimSpace(trigger.Body)
+		if !strings.HasSuffix(body, ";") {
+			body += ";"
+		}
+		return m.DB.Exec(sql + " BEGIN " + body + " END").Error
+	})
+}
+
+// DropTrigger drops trigger name if it exists, a trigger of an attached database is named schema.name
+func (m Migrator) DropTrigger(name string) error {
+	return m.DB.Exec("DROP TRIGGER IF EXISTS ?", clause.Table{Name: name}).Error
+}
+
+// HasTrigger returns trigger name exists or not, a trigger of an attached database is named schema.name
+func (m Migrator) HasTrigger(name string) bool {
+	var count int
+	schema, trigger := splitQualifiedTable(name)
+	m.DB.Raw("SELECT count(*) FROM "+masterTable(schema)+" WHERE type = ? AND name = ?", "trigger", trigger).Row().Scan(&count)
+	return count > 0
+}
+
+// renameTriggerColumn renames column oldName of the trigger's table in trigger DDL str, only NEW and OLD references
+// and the UPDATE OF column list are known to refer to the trigger's table
+func renameTriggerColumn(str, oldName, newName string) string {
+	quoted := "(?:\"" + regexp.QuoteMeta(oldName) + "\"|`" + regexp.QuoteMeta(oldName) + "`|\\[" + regexp.QuoteMeta(oldName) + "\\]|\\b" + regexp.QuoteMeta(oldName) + "\\b)"
+	str = regexp.MustCompile("(?i)\\b(NEW|OLD)\\s*\\.\\s*"+quoted).ReplaceAllString(str, "$1."+strings.ReplaceAll(quoteIdentifier(newName), "$", "$$"))
+
+	updateOfReg := regexp.MustCompile("(?is)\\bUPDATE\\s+OF\\s+(.+?)\\s+ON\\s")
+	if loc := updateOfReg.FindStringSubmatchIndex(str); loc != nil {
+		columns := regexp.MustCompile("(?i)^" + quoted + "$")
+		var results []string
+		for _, column := range strings.Split(str[loc[2]:loc[3]], ",") {
+			if columns.MatchString(strings.TrimSpace(column)) {
+				column = quoteIdentifier(newName)
+			}
+			results = append(results, column)
+		}
+		str = str[:loc[2]] + strings.Join(results, ",") + str[loc[3]:]
+	}
+	return str
+}
+
+// getTriggerSQLs returns the DDL of table's triggers, dropping a table drops its triggers, so a table rebuild re-creates them
+func (m Migrator) getTriggerSQLs(table string) ([]string, error) {
+	var sqls []string
+	schema, table := splitQualifiedTable(table)
+	if err := m.DB.Raw("SELECT sql FROM "+masterTable(schema)+" WHERE type = ? AND tbl_name = ? COLLATE NOCASE AND sql IS NOT NULL", "trigger", table).Scan(&sqls).Error; err != nil {
+		return nil, err
+	}
+
+	if schema != "" {
+		for i, sql := range sqls {
+			loc := triggerRegexp.FindStringSubmatchIndex(sql)
+			if loc == nil {
+				return nil, errors.New("invalid trigger DDL")
+			}
+
+			start := loc[4]
+			if loc[2] >= 0 {
+				start = loc[2]
+			}
+			sqls[i] = sql[:start] + quoteIdentifier(schema) + "." + quoteIdentifier(unquoteIdentifier(sql[loc[4]:loc[5]])) + sql[loc[5]:]
+		}
+	}
+	return sqls, nil
+}
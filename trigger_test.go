@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggers(t *testing.T) {
+	type Account struct {
+		ID      uint
+		Name    string
+		Balance int
+	}
+
+	type AuditLog struct {
+		ID      uint
+		Action  string
+		Balance int
+	}
+
+	db := openTestDB(t, "triggers")
+	if err := db.AutoMigrate(&Account{}, &AuditLog{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	m := db.Migrator().(Migrator)
+	if err := m.CreateTrigger(&Account{}, Trigger{
+		Name:    "trg_accounts_balance",
+		Timing:  TriggerAfter,
+		Event:   TriggerUpdate,
+		Columns: []string{"Balance"},
+		When:    "NEW.`balance` <> OLD.`balance`",
+		Body:    "INSERT INTO `audit_logs` (`action`, `balance`) VALUES ('balance', NEW.`balance`)",
+	}); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+	assert.True(t, m.HasTrigger("trg_accounts_balance"))
+	if err := m.CreateTrigger(&Account{}, Trigger{Name: "trg_invalid"}); err == nil {
+		t.Errorf("Expected trigger without timing, event and body to fail")
+	}
+
+	account := Account{Name: "jinzhu", Balance: 10}
+	db.Create(&account)
+	db.Model(&account).Update("balance", 20)
+	db.Model(&account).Update("name", "jinzhu2")
+
+	var count int64
+	db.Model(&AuditLog{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// rebuilding the table keeps its triggers
+	if err := m.AlterColumn(&Account{}, "Name"); err != nil {
+		t.Fatalf("failed to alter column: %v", err)
+	}
+	m.Dialector = Dialector{version: "3.24.0"}
+	if err := m.RenameColumn(&Account{}, "balance", "amount"); err != nil {
+		t.Fatalf("failed to rename column: %v", err)
+	}
+	assert.True(t, m.HasTrigger("trg_accounts_balance"))
+
+	db.Exec("UPDATE `accounts` SET `amount` = 30")
+	var balance int
+	db.Raw("SELECT `balance` FROM `audit_logs` ORDER BY `id` DESC LIMIT 1").Scan(&balance)
+	assert.Equal(t, 30, balance)
+
+	if err := m.DropTrigger("trg_accounts_balance"); err != nil {
+		t.Fatalf("failed to drop trigger: %v", err)
+	}
+	assert.False(t, m.HasTrigger("trg_accounts_balance"))
+}
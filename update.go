@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpdateFrom returns the FROM clause of an UPDATE statement, rows of the updated table are joined with the rows of
+// source by the conditions of Where and updated from them, https://www.sqlite.org/lang_update.html#update_from.
+// source is a table name, a subquery like *gorm.DB, a clause.Expression, or clause.Values for rows of values
+// whose columns are named by Columns, e.g.
+//
+//	db.Model(&User{}).Clauses(UpdateFrom(db.Model(&Order{}).Select("user_id, sum(amount) AS amount").Group("user_id"), "totals")).
+//		Where("users.id = totals.user_id").Update("total", gorm.Expr("totals.amount"))
+//
+// requires SQLite 3.33.0 or later
+func UpdateFrom(source interface{}, alias string) clause.From {
+	switch source := source.(type) {
+	case string:
+		return clause.From{Tables: []clause.Table{{Name: source, Alias: alias}}}
+	case clause.Values:
+		return clause.From{Joins: []clause.Join{{Expression: valuesSource{values: source, alias: alias}}}}
+	}
+
+	if alias == "" {
+		return clause.From{Joins: []clause.Join{{Expression: clause.Expr{SQL: "(?)", Vars: []interface{}{source}}}}}
+	}
+	return clause.From{Joins: []clause.Join{{Expression: clause.Expr{SQL: "(?) AS ?", Vars: []interface{}{source, clause.Table{Name: alias}}}}}}
+}
+
+// valuesSource rows of values with named columns, built as a common table expression as a compound SELECT is limited
+// to 500 terms
+type valuesSource struct {
+	values clause.Values
+	alias  string
+}
+
+func (source valuesSource) Build(builder clause.Builder) {
+	alias := source.alias
+	if alias == "" {
+		alias = "values"
+	}
+
+	builder.WriteString("(WITH ")
+	builder.WriteQuoted(alias)
+	builder.WriteByte('(')
+	for idx, column := range source.values.Columns {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteQuoted(column.Name)
+	}
+	builder.WriteString(") AS (VALUES ")
+	for idx, row := range source.values.Values {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteByte('(')
+		builder.AddVar(builder, row...)
+		builder.WriteByte(')')
+	}
+	builder.WriteString(") SELECT * FROM ")
+	builder.WriteQuoted(alias)
+	builder.WriteString(") AS ")
+	builder.WriteQuoted(alias)
+}
+
+// buildFrom builds FROM clauses, the FROM clause of an UPDATE statement only contains the sources of UpdateFrom or
+// clause.From, while other statements select from the current table by default
+func buildFrom(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		if _, ok := stmt.Clauses["UPDATE"]; ok {
+			if from, ok := c.Expression.(clause.From); ok {
+				if len(from.Tables) == 0 && len(from.Joins) == 0 {
+					return
+				}
+
+				stmt.WriteString("FROM ")
+				for idx, table := range from.Tables {
+					if idx > 0 {
+						stmt.WriteByte(',')
+					}
+					stmt.WriteQuoted(table)
+				}
+				for idx, join := range from.Joins {
+					if idx > 0 || len(from.Tables) > 0 {
+						stmt.WriteByte(' ')
+					}
+					join.Build(stmt)
+				}
+				return
+			}
+		}
+	}
+
+	c.Build(builder)
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UpdateCustomer struct {
+	ID    uint
+	Name  string
+	Total int
+}
+
+type UpdateOrder struct {
+	ID               uint
+	UpdateCustomerID uint
+	Amount           int
+}
+
+func TestUpdateFrom(t *testing.T) {
+	db := openTestDB(t, "update_from")
+	if err := db.AutoMigrate(&UpdateCustomer{}, &UpdateOrder{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	customers := []UpdateCustomer{{Name: "jinzhu"}, {Name: "gorm"}, {Name: "sqlite"}}
+	db.Create(&customers)
+	db.Create(&[]UpdateOrder{
+		{UpdateCustomerID: customers[0].ID, Amount: 10},
+		{UpdateCustomerID: customers[0].ID, Amount: 5},
+		{UpdateCustomerID: customers[1].ID, Amount: 7},
+	})
+
+	totals := db.Model(&UpdateOrder{}).Select("update_customer_id, sum(amount) AS amount").Group("update_customer_id")
+	result := db.Model(&UpdateCustomer{}).Clauses(UpdateFrom(totals, "totals")).
+		Where("update_customers.id = totals.update_customer_id").Update("total", gorm.Expr("totals.amount"))
+	if result.Error != nil {
+		t.Fatalf("failed to update from subquery: %v", result.Error)
+	}
+	assert.Equal(t, int64(2), result.RowsAffected)
+
+	var got []UpdateCustomer
+	db.Order("id").Find(&got)
+	assert.Equal(t, []int{15, 7, 0}, []int{got[0].Total, got[1].Total, got[2].Total})
+
+	// rows of values update many rows with one statement
+	values := clause.Values{
+		Columns: []clause.Column{{Name: "id"}, {Name: "name"}},
+		Values:  [][]interface{}{{customers[0].ID, "jinzhu2"}, {customers[2].ID, "sqlite2"}},
+	}
+	result = db.Model(&UpdateCustomer{}).Clauses(UpdateFrom(values, "renamed")).
+		Where("update_customers.id = renamed.id").Update("name", gorm.Expr("renamed.name"))
+	if result.Error != nil {
+		t.Fatalf("failed to update from values: %v", result.Error)
+	}
+	db.Order("id").Find(&got)
+	assert.Equal(t, []string{"jinzhu2", "gorm", "sqlite2"}, []string{got[0].Name, got[1].Name, got[2].Name})
+
+	// tables and joins of clause.From
+	result = db.Model(&UpdateCustomer{}).Clauses(clause.From{Tables: []clause.Table{{Name: "update_orders"}}}).
+		Where("update_customers.id = update_orders.update_customer_id AND update_orders.amount = ?", 7).
+		Update("total", gorm.Expr("update_customers.total + update_orders.amount"))
+	if result.Error != nil {
+		t.Fatalf("failed to update from table: %v", result.Error)
+	}
+	db.First(&got[1], customers[1].ID)
+	assert.Equal(t, 14, got[1].Total)
+
+	stmt := db.Session(&gorm.Session{DryRun: true}).Model(&UpdateCustomer{}).Clauses(UpdateFrom("update_orders", "o")).
+		Where("update_customers.id = o.update_customer_id").Update("total", gorm.Expr("o.amount")).Statement
+	assert.Equal(t, `UPDATE "update_customers" SET "total"=o.amount FROM "update_orders" "o" WHERE update_customers.id = o.update_customer_id`, stmt.SQL.String())
+
+	// queries still select from the current table
+	var count int64
+	db.Model(&UpdateCustomer{}).Clauses(clause.From{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
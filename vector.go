@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Vector an embedding stored in the float32 blob format of the sqlite-vec extension, https://github.com/asg017/sqlite-vec,
+// load the extension with Config.Extensions to search vectors with VectorMatch or the VectorDistance functions
+type Vector []float32
+
+// Value returns the little-endian float32 blob of v, implements driver.Valuer interface
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	blob := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(f))
+	}
+	return blob, nil
+}
+
+// Scan scans a float32 blob or a JSON array, e.g. the result of vec_to_json, implements sql.Scanner interface
+func (v *Vector) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*v = nil
+	case []byte:
+		if len(data)%4 != 0 {
+			return fmt.Errorf("sqlite: invalid vector blob of %d bytes", len(data))
+		}
+		vector := make(Vector, len(data)/4)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		*v = vector
+	case string:
+		var vector Vector
+		if err := json.Unmarshal([]byte(data), &vector); err != nil {
+			return fmt.Errorf("sqlite: invalid vector %q: %w", data, err)
+		}
+		*v = vector
+	default:
+		return fmt.Errorf("sqlite: failed to scan vector from %T", value)
+	}
+	return nil
+}
+
+// GormDataType gorm common data type
+func (Vector) GormDataType() string {
+	return "vector"
+}
+
+// GormDBDataType gorm db data type, columns of vec0 tables are declared by VectorTabler
+func (Vector) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "blob"
+}
+
+// VectorMatch returns the KNN condition of a vec0 table, matching the k rows nearest to v,
+// e.g. db.Where(VectorMatch("embedding", query, 10)).Order("distance").Find(&documents)
+func VectorMatch(column string, v Vector, k int) clause.Expression {
+	return clause.Expr{SQL: "? MATCH ? AND k = ?", Vars: []interface{}{clause.Column{Name: column}, v, k}}
+}
+
+// VectorDistanceCosine returns the cosine distance between column and v, e.g. to sort rows of regular tables,
+// db.Clauses(clause.OrderBy{Expression: VectorDistanceCosine("embedding", query)}).Limit(10).Find(&documents)
+func VectorDistanceCosine(column string, v Vector) clause.Expression {
+	return clause.Expr{SQL: "vec_distance_cosine(?, ?)", Vars: []interface{}{clause.Column{Name: column}, v}}
+}
+
+// VectorDistanceL2 returns the euclidean distance between column and v
+func VectorDistanceL2(column string, v Vector) clause.Expression {
+	return clause.Expr{SQL: "vec_distance_l2(?, ?)", Vars: []interface{}{clause.Column{Name: column}, v}}
+}
+
+// VectorConfig describes a vec0 virtual table of the sqlite-vec extension
+//
+// Vector fields require a dimensions tag, e.g. `gorm:"dimensions:768"`, other fields are metadata columns that
+// KNN queries can filter on, fields tagged with `gorm:"vec:aux"` are auxiliary columns that are only stored.
+type VectorConfig struct {
+	// Metric distance metric of the vector columns, l2 (default), l1 or cosine
+	Metric string
+}
+
+// VectorTabler is implemented by models that are stored as vec0 virtual tables, AutoMigrate creates them once
+// and never alters them
+type VectorTabler interface {
+	VectorTable() VectorConfig
+}
+
+// vectorShadowTableSuffixes suffixes of vec0 shadow tables, chunks are numbered per vector and metadata column
+func vectorShadowTableSuffixes() []string {
+	suffixes := []string{"info", "chunks", "rowids", "auxiliary"}
+	for i := 0; i < 16; i++ {
+		suffixes = append(suffixes, fmt.Sprintf("vector_chunks%02d", i), fmt.Sprintf("metadatachunks%02d", i), fmt.Sprintf("metadatatext%02d", i))
+	}
+	return suffixes
+}
+
+func vectorTableSQLs(stmt *gorm.Statement, config VectorConfig) ([]string, error) {
+	var (
+		definitions []string
+		vectors     int
+	)
+	if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+		definitions = append(definitions, stmt.Quote(field.DBName)+" integer primary key")
+	}
+
+	for _, dbName := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[dbName]
+		if field.PrimaryKey || field.IgnoreMigration {
+			continue
+		}
+
+		definition := stmt.Quote(dbName)
+		switch {
+		case field.DataType == "vector":
+			dimensions := field.TagSettings["DIMENSIONS"]
+			if dimensions == "" {
+				return nil, fmt.Errorf("vec0 table %v requires the dimensions of vector column %v", stmt.Table, dbName)
+			}
+			definition += fmt.Sprintf(" float[%v]", dimensions)
+			if config.Metric != "" {
+				definition += " distance_metric=" + config.Metric
+			}
+			vectors++
+		case strings.EqualFold(field.TagSettings["VEC"], "AUX"):
+			definition = "+" + definition + " " + vectorColumnType(field)
+		default:
+			definition += " " + vectorColumnType(field)
+		}
+		definitions = append(definitions, definition)
+	}
+
+	if vectors == 0 {
+		return nil, fmt.Errorf("vec0 table %v requires a vector column", stmt.Table)
+	}
+	schema, name := splitQualifiedTable(statementTable(stmt))
+	return []string{fmt.Sprintf("CREATE VIRTUAL TABLE %s USING vec0(%s)", qualifyName(schema, name), strings.Join(definitions, ","))}, nil
+}
+
+// vectorColumnType returns the type of a metadata or auxiliary column, vec0 only knows these four types
+func vectorColumnType(field *schema.Field) string {
+	switch field.DataType {
+	case schema.Bool:
+		return "boolean"
+	case schema.Int, schema.Uint:
+		return "integer"
+	case schema.Float:
+		return "float"
+	default:
+		return "text"
+	}
+}
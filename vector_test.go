@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type vectorDocument struct {
+	ID        int64
+	Embedding Vector `gorm:"dimensions:3"`
+	Category  string
+	Score     float64
+	Body      string `gorm:"vec:aux"`
+}
+
+func (vectorDocument) VectorTable() VectorConfig {
+	return VectorConfig{Metric: "cosine"}
+}
+
+type vectorTenantDocument struct {
+	ID        int64
+	Embedding Vector `gorm:"dimensions:2"`
+}
+
+func (vectorTenantDocument) TableName() string {
+	return "tenant.documents"
+}
+
+// distanceCosine stands in for the function of the sqlite-vec extension
+func distanceCosine(a, b []byte) (float64, error) {
+	var x, y Vector
+	if err := x.Scan(a); err != nil {
+		return 0, err
+	}
+	if err := y.Scan(b); err != nil {
+		return 0, err
+	}
+
+	var dot, normX, normY float64
+	for i := range x {
+		dot += float64(x[i]) * float64(y[i])
+		normX += float64(x[i]) * float64(x[i])
+		normY += float64(y[i]) * float64(y[i])
+	}
+	return 1 - dot/math.Sqrt(normX*normY), nil
+}
+
+func TestVector(t *testing.T) {
+	type Embedding struct {
+		ID     uint
+		Name   string
+		Vector Vector
+	}
+
+	db, err := gorm.Open(OpenInMemory("", Config{Functions: []Function{{Name: "vec_distance_cosine", Impl: distanceCosine, Pure: true}}}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&Embedding{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	embeddings := []Embedding{
+		{Name: "x", Vector: Vector{1, 0, 0}},
+		{Name: "y", Vector: Vector{0, 1, 0}},
+		{Name: "xy", Vector: Vector{1, 1, 0}},
+		{Name: "empty"},
+	}
+	if err := db.Create(&embeddings).Error; err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	var result Embedding
+	db.First(&result, embeddings[0].ID)
+	assert.Equal(t, Vector{1, 0, 0}, result.Vector)
+	var empty Embedding
+	db.First(&empty, embeddings[3].ID)
+	assert.Nil(t, empty.Vector)
+
+	var names []string
+	db.Model(&Embedding{}).Where("vector IS NOT NULL").
+		Clauses(clause.OrderBy{Expression: VectorDistanceCosine("vector", Vector{0.9, 0.1, 0})}).Pluck("name", &names)
+	assert.Equal(t, []string{"x", "xy", "y"}, names)
+
+	var vector Vector
+	assert.NoError(t, vector.Scan("[0.5,-1,2]"))
+	assert.Equal(t, Vector{0.5, -1, 2}, vector)
+	assert.Error(t, vector.Scan([]byte{1, 2, 3}))
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&vectorDocument{}).Where(VectorMatch("embedding", Vector{1, 0, 0}, 2)).Where("category = ?", "news").Order("distance").Find(&[]vectorDocument{})
+	})
+	assert.Contains(t, sql, `WHERE ("embedding" MATCH`)
+	assert.Contains(t, sql, "AND k = 2) AND category = \"news\" ORDER BY distance")
+}
+
+func TestVectorTableSQLs(t *testing.T) {
+	db := openTestDB(t, "vector_table")
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&vectorDocument{}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	sqls, err := vectorTableSQLs(stmt, vectorDocument{}.VectorTable())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`CREATE VIRTUAL TABLE "vector_documents" USING vec0("id" integer primary key,"embedding" float[3] distance_metric=cosine,"category" text,"score" float,+"body" text)`}, sqls)
+
+	// tables of attached databases are created there
+	stmt = &gorm.Statement{DB: db}
+	if err := stmt.Parse(&vectorTenantDocument{}); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	sqls, err = vectorTableSQLs(stmt, VectorConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`CREATE VIRTUAL TABLE "tenant"."documents" USING vec0("id" integer primary key,"embedding" float[2])`}, sqls)
+
+	// vec0 isn't compiled into the driver, AutoMigrate fails without the extension
+	assert.Error(t, db.AutoMigrate(&vectorDocument{}))
+}
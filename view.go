@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateView creates view name selecting option.Query, with CREATE VIEW IF NOT EXISTS an existing view is kept,
+// unless option.Replace drops it first in the same transaction, SQLite has no CREATE OR REPLACE VIEW.
+// Views can't have bound parameters, so variables of the query are written as SQL literals
+func (m Migrator) CreateView(name string, option gorm.ViewOption) error {
+	if option.Query == nil {
+		return errors.New("sqlite: CreateView requires a query")
+	}
+	if option.CheckOption != "" {
+		return fmt.Errorf("sqlite: views don't support %v", option.CheckOption)
+	}
+
+	var (
+		query strings.Builder
+		stmt  = &gorm.Statement{DB: m.DB}
+	)
+	stmt.AddVar(&query, option.Query)
+	sql, err := inlineVars(query.String(), stmt.Vars, m.config().TimeFormat)
+	if err != nil {
+		return err
+	}
+
+	view := m.DB.Statement.Quote(clause.Table{Name: name})
+	if !option.Replace {
+		return m.DB.Exec("CREATE VIEW IF NOT EXISTS " + view + " AS " + sql).Error
+	}
+
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP VIEW IF EXISTS " + view).Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE VIEW " + view + " AS " + sql).Error
+	})
+}
+
+// DropView drops view name if it exists
+func (m Migrator) DropView(name string) error {
+	return m.DB.Exec("DROP VIEW IF EXISTS ?", clause.Table{Name: name}).Error
+}
+
+// HasView returns view name exists or not
+func (m Migrator) HasView(name string) bool {
+	var count int
+	schema, view := splitQualifiedTable(name)
+	m.DB.Raw("SELECT count(*) FROM "+masterTable(schema)+" WHERE type = ? AND name = ?", "view", view).Row().Scan(&count)
+	return count > 0
+}
+
+// inlineVars replaces the ? placeholders of sql outside of quotes with vars written as SQL literals
+func inlineVars(sql string, vars []interface{}, format TimeFormat) (string, error) {
+	var (
+		result strings.Builder
+		quote  rune
+		idx    int
+	)
+
+	for _, c := range sql {
+		switch {
+		case quote > 0:
+			if c == quote || (quote == '[' && c == ']') {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			quote = c
+		case c == '?':
+			if idx >= len(vars) {
+				return "", errors.New("sqlite: more placeholders than variables")
+			}
+
+			literal, err := sqlLiteral(vars[idx], format)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(literal)
+			idx++
+			continue
+		}
+		result.WriteRune(c)
+	}
+
+	if idx != len(vars) {
+		return "", errors.New("sqlite: more variables than placeholders")
+	}
+	return result.String(), nil
+}
+
+// sqlLiteral returns value as an SQL literal, times are written in format
+func sqlLiteral(value interface{}, format TimeFormat) (string, error) {
+	value, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return "", err
+	}
+	if t, ok := value.(time.Time); ok {
+		if value = format.value(t); format == TimeFormatDefault {
+			value = t.Format(sqlite3.SQLiteTimestampFormats[0])
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'", nil
+	}
+	return "", fmt.Errorf("sqlite: can't write %T as an SQL literal", value)
+}
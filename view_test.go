@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestViews(t *testing.T) {
+	type Member struct {
+		ID   uint
+		Name string
+		Age  int
+	}
+
+	db := openTestDB(t, "views")
+	if err := db.AutoMigrate(&Member{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&[]Member{{Name: "jinzhu", Age: 18}, {Name: "o'brien", Age: 30}, {Name: "kid", Age: 8}})
+
+	m := db.Migrator().(Migrator)
+	adults := db.Model(&Member{}).Select("name").Where("age >= ? AND name <> ?", 18, "it's ?")
+	if err := m.CreateView("adults", gorm.ViewOption{Query: adults}); err != nil {
+		t.Fatalf("failed to create view: %v", err)
+	}
+	assert.True(t, m.HasView("adults"))
+	assert.False(t, m.HasTable("adults"))
+
+	var names []string
+	db.Table("adults").Order("name").Pluck("name", &names)
+	assert.Equal(t, []string{"jinzhu", "o'brien"}, names)
+
+	// an existing view is kept without Replace
+	seniors := db.Model(&Member{}).Select("name").Where("age >= ?", 30)
+	if err := m.CreateView("adults", gorm.ViewOption{Query: seniors}); err != nil {
+		t.Fatalf("failed to create existing view: %v", err)
+	}
+	db.Table("adults").Pluck("name", &names)
+	assert.Len(t, names, 2)
+
+	if err := m.CreateView("adults", gorm.ViewOption{Query: seniors, Replace: true}); err != nil {
+		t.Fatalf("failed to replace view: %v", err)
+	}
+	db.Table("adults").Pluck("name", &names)
+	assert.Equal(t, []string{"o'brien"}, names)
+
+	if err := m.CreateView("kids", gorm.ViewOption{Query: db.Raw("SELECT * FROM members WHERE age < ?", 18)}); err != nil {
+		t.Fatalf("failed to create view of raw query: %v", err)
+	}
+	var count int64
+	db.Table("kids").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	if err := m.CreateView("checked", gorm.ViewOption{Query: seniors, CheckOption: "WITH CHECK OPTION"}); err == nil {
+		t.Errorf("Expected check options to fail")
+	}
+	if err := m.CreateView("empty", gorm.ViewOption{}); err == nil {
+		t.Errorf("Expected view without query to fail")
+	}
+
+	if err := m.DropView("adults"); err != nil {
+		t.Fatalf("failed to drop view: %v", err)
+	}
+	assert.False(t, m.HasView("adults"))
+	if err := m.DropView("adults"); err != nil {
+		t.Errorf("Expected dropping a missing view to succeed, got %v", err)
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	instant := time.Date(2022, 5, 1, 10, 20, 30, 0, time.UTC)
+	params := []struct {
+		value    interface{}
+		format   TimeFormat
+		expected string
+	}{
+		{nil, TimeFormatDefault, "NULL"},
+		{true, TimeFormatDefault, "1"},
+		{uint8(7), TimeFormatDefault, "7"},
+		{1.5, TimeFormatDefault, "1.5"},
+		{"it's", TimeFormatDefault, "'it''s'"},
+		{[]byte{0xca, 0xfe}, TimeFormatDefault, "X'cafe'"},
+		{instant, TimeFormatDefault, "'2022-05-01 10:20:30+00:00'"},
+		{instant, TimeFormatUnix, "1651400430"},
+		{Decimal("1.10"), TimeFormatDefault, "'1.10'"},
+	}
+
+	for _, p := range params {
+		if literal, err := sqlLiteral(p.value, p.format); err != nil || literal != p.expected {
+			t.Errorf("Expected %#v to be written as %v, got %v, %v", p.value, p.expected, literal, err)
+		}
+	}
+}
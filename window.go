@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// WindowExpression a window function call, implements clause.Expression interface to select it, e.g.
+//
+//	db.Model(&Employee{}).Select("name, ?", RowNumber().PartitionBy("department").OrderBy("salary DESC").As("rank"))
+//
+// https://www.sqlite.org/windowfunctions.html
+type WindowExpression struct {
+	function    clause.Expression
+	filter      clause.Expression
+	partitionBy []string
+	orderBy     []string
+	frame       string
+	alias       string
+}
+
+// Over returns the window function call of function, e.g. Over(clause.Expr{SQL: "sum(amount)"}) for running totals
+func Over(function clause.Expression) *WindowExpression {
+	return &WindowExpression{function: function}
+}
+
+// RowNumber row_number(), the number of the row within its partition starting from 1
+func RowNumber() *WindowExpression {
+	return Over(clause.Expr{SQL: "row_number()"})
+}
+
+// Rank rank(), the row number of the first peer of the row, with gaps
+func Rank() *WindowExpression {
+	return Over(clause.Expr{SQL: "rank()"})
+}
+
+// DenseRank dense_rank(), the number of the peer group of the row, without gaps
+func DenseRank() *WindowExpression {
+	return Over(clause.Expr{SQL: "dense_rank()"})
+}
+
+// PercentRank percent_rank(), (rank - 1) / (partition rows - 1)
+func PercentRank() *WindowExpression {
+	return Over(clause.Expr{SQL: "percent_rank()"})
+}
+
+// CumeDist cume_dist(), the cumulative distribution of the row within its partition
+func CumeDist() *WindowExpression {
+	return Over(clause.Expr{SQL: "cume_dist()"})
+}
+
+// NTile ntile(n), the number of the group of the row when the partition is divided into n groups
+func NTile(n int) *WindowExpression {
+	return Over(clause.Expr{SQL: "ntile(?)", Vars: []interface{}{n}})
+}
+
+// Lag lag(column, offset, defaultValue), the value of column of the row offset rows before the row, or defaultValue
+func Lag(column string, offset int, defaultValue interface{}) *WindowExpression {
+	return Over(clause.Expr{SQL: "lag(?,?,?)", Vars: []interface{}{clause.Column{Name: column}, offset, defaultValue}})
+}
+
+// Lead lead(column, offset, defaultValue), the value of column of the row offset rows after the row, or defaultValue
+func Lead(column string, offset int, defaultValue interface{}) *WindowExpression {
+	return Over(clause.Expr{SQL: "lead(?,?,?)", Vars: []interface{}{clause.Column{Name: column}, offset, defaultValue}})
+}
+
+// FirstValue first_value(column), the value of column of the first row of the window frame
+func FirstValue(column string) *WindowExpression {
+	return Over(clause.Expr{SQL: "first_value(?)", Vars: []interface{}{clause.Column{Name: column}}})
+}
+
+// LastValue last_value(column), the value of column of the last row of the window frame
+func LastValue(column string) *WindowExpression {
+	return Over(clause.Expr{SQL: "last_value(?)", Vars: []interface{}{clause.Column{Name: column}}})
+}
+
+// NthValue nth_value(column, n), the value of column of the nth row of the window frame
+func NthValue(column string, n int) *WindowExpression {
+	return Over(clause.Expr{SQL: "nth_value(?,?)", Vars: []interface{}{clause.Column{Name: column}, n}})
+}
+
+// Filter only passes the rows matching query to aggregate window functions, e.g. Over(clause.Expr{SQL: "count(*)"}).Filter("status = ?", "paid")
+func (window *WindowExpression) Filter(query string, args ...interface{}) *WindowExpression {
+	window.filter = clause.Expr{SQL: query, Vars: args}
+	return window
+}
+
+// PartitionBy partitions the rows by the expressions, e.g. PartitionBy("department")
+func (window *WindowExpression) PartitionBy(expressions ...string) *WindowExpression {
+	window.partitionBy = append(window.partitionBy, expressions...)
+	return window
+}
+
+// OrderBy orders the rows of partitions by the expressions, e.g. OrderBy("salary DESC", "id")
+func (window *WindowExpression) OrderBy(expressions ...string) *WindowExpression {
+	window.orderBy = append(window.orderBy, expressions...)
+	return window
+}
+
+// Frame sets the frame specification, e.g. Frame("ROWS BETWEEN 2 PRECEDING AND CURRENT ROW")
+func (window *WindowExpression) Frame(frame string) *WindowExpression {
+	window.frame = frame
+	return window
+}
+
+// As names the selected column
+func (window *WindowExpression) As(alias string) *WindowExpression {
+	window.alias = alias
+	return window
+}
+
+// Build implements clause.Expression
+func (window *WindowExpression) Build(builder clause.Builder) {
+	window.function.Build(builder)
+	if window.filter != nil {
+		builder.WriteString(" FILTER (WHERE ")
+		window.filter.Build(builder)
+		builder.WriteByte(')')
+	}
+
+	var spec []string
+	if len(window.partitionBy) > 0 {
+		spec = append(spec, "PARTITION BY "+strings.Join(window.partitionBy, ","))
+	}
+	if len(window.orderBy) > 0 {
+		spec = append(spec, "ORDER BY "+strings.Join(window.orderBy, ","))
+	}
+	if window.frame != "" {
+		spec = append(spec, window.frame)
+	}
+	builder.WriteString(" OVER (")
+	builder.WriteString(strings.Join(spec, " "))
+	builder.WriteByte(')')
+
+	if window.alias != "" {
+		builder.WriteString(" AS ")
+		builder.WriteQuoted(window.alias)
+	}
+}
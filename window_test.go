@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WindowEmployee struct {
+	ID         uint
+	Name       string
+	Department string
+	Salary     int
+}
+
+func TestWindowFunctions(t *testing.T) {
+	db := openTestDB(t, "window_functions")
+	if err := db.AutoMigrate(&WindowEmployee{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&[]WindowEmployee{
+		{Name: "a", Department: "dev", Salary: 300},
+		{Name: "b", Department: "dev", Salary: 200},
+		{Name: "c", Department: "dev", Salary: 200},
+		{Name: "d", Department: "ops", Salary: 100},
+	})
+
+	type Result struct {
+		Name      string
+		Rank      int
+		DenseRank int
+		Previous  int
+		Total     int
+		High      int
+	}
+	var results []Result
+	err := db.Model(&WindowEmployee{}).Select("name, ?, ?, ?, ?, ?",
+		Rank().PartitionBy("department").OrderBy("salary DESC").As("rank"),
+		DenseRank().PartitionBy("department").OrderBy("salary DESC").As("dense_rank"),
+		Lag("salary", 1, 0).OrderBy("id").As("previous"),
+		Over(clause.Expr{SQL: "sum(salary)"}).OrderBy("id").Frame("ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW").As("total"),
+		Over(clause.Expr{SQL: "count(*)"}).Filter("salary > ?", 150).PartitionBy("department").As("high"),
+	).Order("id").Scan(&results).Error
+	if err != nil {
+		t.Fatalf("failed to select window functions: %v", err)
+	}
+	assert.Equal(t, []Result{
+		{Name: "a", Rank: 1, DenseRank: 1, Previous: 0, Total: 300, High: 3},
+		{Name: "b", Rank: 2, DenseRank: 2, Previous: 300, Total: 500, High: 3},
+		{Name: "c", Rank: 2, DenseRank: 2, Previous: 200, Total: 700, High: 3},
+		{Name: "d", Rank: 1, DenseRank: 1, Previous: 200, Total: 800, High: 0},
+	}, results)
+
+	stmt := db.Session(&gorm.Session{DryRun: true}).Model(&WindowEmployee{}).
+		Select("?", NTile(2).OrderBy("salary").As("bucket")).Find(&[]WindowEmployee{}).Statement
+	assert.Equal(t, `SELECT ntile(?) OVER (ORDER BY salary) AS "bucket" FROM "window_employees"`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{2}, stmt.Vars)
+}
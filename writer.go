@@ -0,0 +1,359 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	errNotImplemented = errors.New("sqlite: driver connection does not implement the required interface")
+	// transactionRegexp matches statements beginning or ending a transaction, not ROLLBACK TO a savepoint
+	transactionRegexp = regexp.MustCompile(`(?i)^\s*(BEGIN|COMMIT|END|ROLLBACK)\b(?:\s+TRANSACTION\b)?(\s+TO\b)?`)
+)
+
+// writeLock serializes writes of Config.SingleWriter, waiting writers are queued in a channel so they can give up
+// when their context is done
+type writeLock chan struct{}
+
+func (l writeLock) lock(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l writeLock) unlock() {
+	<-l
+}
+
+// isWriteQuery guesses whether query writes, SELECT and EXPLAIN statements and CTEs without data changes are reads,
+// a query of several statements writes if one of them does
+func isWriteQuery(query string) bool {
+	for _, tokens := range sqlStatements(query) {
+		if isWriteStatement(tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWriteStatement reports whether the statement of tokens writes, a CTE writes if its statement does, the names of
+// functions like replace() or keywords in its SELECTs don't matter
+func isWriteStatement(tokens []string) bool {
+	switch tokens[0] {
+	case "SELECT", "VALUES", "EXPLAIN":
+		return false
+	case "WITH":
+		// the statement of a CTE is the first keyword outside its parenthesized tables
+		depth := 0
+		for _, token := range tokens[1:] {
+			switch token {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case "SELECT", "VALUES":
+				if depth == 0 {
+					return false
+				}
+			case "INSERT", "UPDATE", "DELETE", "REPLACE":
+				if depth == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return true
+}
+
+// sqlStatements splits query into statements of tokens: upper-cased keywords and identifiers, punctuation, and ' for
+// string literals and " for quoted identifiers. Comments and leading parentheses are skipped
+func sqlStatements(query string) (statements [][]string) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing, token := c, `"`
+			if c == '[' {
+				closing = ']'
+			} else if c == '\'' {
+				token = "'"
+			}
+			// quotes are escaped by doubling them, which reads as two adjacent literals
+			if end := strings.IndexByte(query[i+1:], closing); end >= 0 {
+				i += end + 2
+			} else {
+				i = len(query)
+			}
+			tokens = append(tokens, token)
+		case isIdentifierChar(c):
+			start := i
+			for i < len(query) && isIdentifierChar(query[i]) {
+				i++
+			}
+			tokens = append(tokens, strings.ToUpper(query[start:i]))
+		case c == ';':
+			if len(tokens) > 0 {
+				statements = append(statements, tokens)
+			}
+			tokens = nil
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '(' && len(tokens) == 0:
+			i++
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	if len(tokens) > 0 {
+		statements = append(statements, tokens)
+	}
+	return statements
+}
+
+// writerConn takes the write lock for write statements and write transactions of its connection
+type writerConn struct {
+	driver.Conn
+	lock writeLock
+	inTx bool
+	// rawTx the transaction was begun by a BEGIN statement, e.g. BEGIN EXCLUSIVE of table rebuilds
+	rawTx bool
+}
+
+// unwrap returns the driver connection
+func (c *writerConn) unwrap() driver.Conn {
+	return c.Conn
+}
+
+// acquire takes the write lock for a write statement outside a transaction, locked reports whether it was taken
+func (c *writerConn) acquire(ctx context.Context, query string) (locked bool, err error) {
+	if c.inTx || !isWriteQuery(query) {
+		return false, nil
+	}
+	if err := c.lock.lock(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// wrapRows keeps the write lock until rows are closed, statements run while their rows are read, e.g. INSERT ... RETURNING
+func (c *writerConn) wrapRows(rows driver.Rows, locked bool, err error) (driver.Rows, error) {
+	if err != nil || !locked {
+		if locked {
+			c.lock.unlock()
+		}
+		return rows, err
+	}
+	return &writerRows{Rows: rows, lock: c.lock}, nil
+}
+
+func (c *writerConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *writerConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	// read only transactions don't take the write lock
+	if opts.ReadOnly {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	if err := c.lock.lock(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		c.lock.unlock()
+		return nil, err
+	}
+
+	c.inTx = true
+	return &writerTx{Tx: tx, conn: c}, nil
+}
+
+func (c *writerConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	if matches := transactionRegexp.FindStringSubmatch(query); matches != nil && matches[2] == "" {
+		return c.execTransaction(ctx, execer, strings.ToUpper(matches[1]), query, args)
+	}
+
+	locked, err := c.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		defer c.lock.unlock()
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+// execTransaction runs a statement beginning or ending a transaction, a transaction begun by a statement holds
+// the write lock until it ends like one of BeginTx
+func (c *writerConn) execTransaction(ctx context.Context, execer driver.ExecerContext, keyword string, query string, args []driver.NamedValue) (driver.Result, error) {
+	if keyword != "BEGIN" {
+		result, err := execer.ExecContext(ctx, query, args)
+		if err == nil && c.rawTx {
+			c.inTx, c.rawTx = false, false
+			c.lock.unlock()
+		}
+		return result, err
+	}
+
+	if c.inTx {
+		return execer.ExecContext(ctx, query, args)
+	}
+	if err := c.lock.lock(ctx); err != nil {
+		return nil, err
+	}
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != nil {
+		c.lock.unlock()
+		return nil, err
+	}
+	c.inTx, c.rawTx = true, true
+	return result, nil
+}
+
+func (c *writerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	locked, err := c.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	return c.wrapRows(rows, locked, err)
+}
+
+func (c *writerConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *writerConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &writerStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+type writerTx struct {
+	driver.Tx
+	conn *writerConn
+}
+
+func (tx *writerTx) Commit() error {
+	defer tx.done()
+	return tx.Tx.Commit()
+}
+
+func (tx *writerTx) Rollback() error {
+	defer tx.done()
+	return tx.Tx.Rollback()
+}
+
+func (tx *writerTx) done() {
+	if tx.conn.inTx {
+		tx.conn.inTx = false
+		tx.conn.lock.unlock()
+	}
+}
+
+type writerStmt struct {
+	driver.Stmt
+	conn  *writerConn
+	query string
+}
+
+func (s *writerStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	locked, err := s.conn.acquire(ctx, s.query)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		defer s.conn.lock.unlock()
+	}
+	return execer.ExecContext(ctx, args)
+}
+
+func (s *writerStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errNotImplemented
+	}
+
+	locked, err := s.conn.acquire(ctx, s.query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := queryer.QueryContext(ctx, args)
+	return s.conn.wrapRows(rows, locked, err)
+}
+
+// writerRows releases the write lock once closed
+type writerRows struct {
+	driver.Rows
+	lock writeLock
+	once sync.Once
+}
+
+func (r *writerRows) Close() error {
+	defer r.once.Do(r.lock.unlock)
+	return r.Rows.Close()
+}
+
+func (r *writerRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *writerRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
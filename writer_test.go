@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestSingleWriter(t *testing.T) {
+	type User struct {
+		ID   uint
+		Name string
+		Age  int
+	}
+
+	dir, err := ioutil.TempDir("", "gorm-sqlite-writer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// transactions reading before they write fail with database is locked when they run concurrently,
+	// as SQLite can't upgrade their read locks, no matter the busy timeout
+	db, err := gorm.Open(New(filepath.Join(dir, "gorm.db"), Config{SingleWriter: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				errs <- db.Transaction(func(tx *gorm.DB) error {
+					var count int64
+					if err := tx.Model(&User{}).Count(&count).Error; err != nil {
+						return err
+					}
+					return tx.Create(&User{Name: "writer", Age: int(count)}).Error
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected concurrent writes to be serialized, got %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&User{}).Count(&count)
+	if count != 40 {
+		t.Errorf("Expected 40 users, got %v", count)
+	}
+
+	// reads don't wait for writers
+	tx := db.Begin()
+	tx.Create(&User{Name: "pending"})
+	if err := db.Model(&User{}).Count(&count).Error; err != nil {
+		t.Errorf("Expected reads to run during a write transaction, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := db.WithContext(ctx).Create(&User{Name: "queued"}).Error; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected queued writer to give up with its context, got %v", err)
+	}
+	tx.Commit()
+
+	if err := db.Create(&User{Name: "after"}).Error; err != nil {
+		t.Errorf("Expected write after commit to succeed, got %v", err)
+	}
+
+	// transactions begun by statements hold the write lock as well, e.g. table rebuilds
+	err = db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("BEGIN IMMEDIATE").Error; err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := db.WithContext(ctx).Create(&User{Name: "queued"}).Error; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected writer to queue behind BEGIN, got %v", err)
+		}
+		return conn.Exec("COMMIT").Error
+	})
+	if err != nil {
+		t.Errorf("Expected raw transaction to succeed, got %v", err)
+	}
+	if err := db.Migrator().AlterColumn(&User{}, "Name"); err != nil {
+		t.Errorf("Expected table rebuild to succeed, got %v", err)
+	}
+	if err := db.Create(&User{Name: "after rebuild"}).Error; err != nil {
+		t.Errorf("Expected write after rebuild to succeed, got %v", err)
+	}
+}
+
+func TestIsWriteQuery(t *testing.T) {
+	params := []struct {
+		query string
+		write bool
+	}{
+		{"SELECT * FROM users", false},
+		{"  select 1", false},
+		{"EXPLAIN QUERY PLAN SELECT 1", false},
+		{"WITH t AS (SELECT 1) SELECT * FROM t", false},
+		{"WITH t AS (SELECT 1) INSERT INTO users SELECT * FROM t", true},
+		{"INSERT INTO users (name) VALUES (?) RETURNING id", true},
+		{"UPDATE users SET name = ?", true},
+		{"PRAGMA foreign_keys = ON", true},
+		{"WITH t AS (SELECT replace(name, 'a', 'b') AS name FROM users) SELECT * FROM t", false},
+		{"WITH t AS (SELECT 'delete' AS word, \"update\" FROM users) SELECT * FROM t", false},
+		{"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t) REPLACE INTO numbers SELECT n FROM t", true},
+		{"(SELECT 1)", false},
+		{"/* report */ SELECT 1", false},
+		{"-- report\nSELECT 1", false},
+		{"SELECT 1; DELETE FROM users", true},
+		{"", false},
+	}
+
+	for _, p := range params {
+		if isWriteQuery(p.query) != p.write {
+			t.Errorf("Expected isWriteQuery(%q) to be %v", p.query, p.write)
+		}
+	}
+}